@@ -0,0 +1,144 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	cfg "sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
+)
+
+//+kubebuilder:object:root=true
+
+// ProviderOperatorConfig is the configuration file of the operator, loaded with the --config flag.
+// The flags set on the command line take precedence over the file.
+type ProviderOperatorConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ControllerManagerConfigurationSpec configures the health, metrics, webhook and leader election of the manager
+	cfg.ControllerManagerConfigurationSpec `json:",inline"`
+
+	// ProviderSyncPeriod is the period of the reconciles that sync the objects with the provider.
+	// The SYNC_PERIOD_MIN environment variable is used if it is not set.
+	// +optional
+	ProviderSyncPeriod *metav1.Duration `json:"providerSyncPeriod,omitempty"`
+
+	// Provider configures the provider API backend.
+	// +optional
+	Provider ProviderBackend `json:"provider,omitempty"`
+
+	// Controllers configures the concurrency and the rate limiter of the controllers, by controller name:
+	// inventory, instance or connection.
+	// +optional
+	Controllers map[string]ControllerConfig `json:"controllers,omitempty"`
+
+	// WatchNamespaces are the namespaces the operator watches, with its install namespace.
+	// All the namespaces are watched if it is empty. The --watch-namespaces flag overrides it.
+	// +optional
+	WatchNamespaces []string `json:"watchNamespaces,omitempty"`
+
+	// Registration configures the registration of the provider with the DBaaS operator.
+	// +optional
+	Registration Registration `json:"registration,omitempty"`
+
+	// Tracing configures the export of the OpenTelemetry spans of the operator.
+	// +optional
+	Tracing Tracing `json:"tracing,omitempty"`
+}
+
+// ProviderBackend configures the provider API backend.
+type ProviderBackend struct {
+	// ServerURL is the URL of the provider API. The in-memory fake provider is used if it is empty.
+	// +optional
+	ServerURL string `json:"serverURL,omitempty"`
+
+	// APIQPS is the number of provider API calls per second of all the controllers.
+	// The calls are not limited if it is not set.
+	// +optional
+	APIQPS float64 `json:"apiQPS,omitempty"`
+
+	// APIBurst is the number of provider API calls above the rate allowed at once.
+	// +optional
+	APIBurst int `json:"apiBurst,omitempty"`
+
+	// FixturesFile is the file of the initial clusters of the fake provider tenants.
+	// +optional
+	FixturesFile string `json:"fixturesFile,omitempty"`
+
+	// UnreachableTimeout is how long the provider API can be unreachable before the operator is not ready.
+	// +optional
+	UnreachableTimeout *metav1.Duration `json:"unreachableTimeout,omitempty"`
+
+	// FaultInjection injects the faults set by the dbaas.redhat.com/fake-faults annotation of the inventories
+	// into the provider API calls made with their credentials. It is meant for dev clusters only.
+	// +optional
+	FaultInjection bool `json:"faultInjection,omitempty"`
+}
+
+// ControllerConfig configures the concurrency and the rate limiter of a controller.
+type ControllerConfig struct {
+	// MaxConcurrentReconciles is the number of objects reconciled at the same time.
+	// +optional
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles,omitempty"`
+
+	// RateLimitBaseDelay is the requeue delay of an object after its first failed reconcile.
+	// +optional
+	RateLimitBaseDelay *metav1.Duration `json:"rateLimitBaseDelay,omitempty"`
+
+	// RateLimitMaxDelay is the maximum requeue delay of an object after failed reconciles.
+	// +optional
+	RateLimitMaxDelay *metav1.Duration `json:"rateLimitMaxDelay,omitempty"`
+
+	// RateLimitQPS is the number of reconciles per second.
+	// +optional
+	RateLimitQPS float64 `json:"rateLimitQPS,omitempty"`
+
+	// RateLimitBurst is the number of reconciles above the rate allowed at once.
+	// +optional
+	RateLimitBurst int `json:"rateLimitBurst,omitempty"`
+}
+
+// Registration configures the registration of the provider with the DBaaS operator.
+type Registration struct {
+	// InstallNamespace is the namespace of the operator deployment.
+	// The INSTALL_NAMESPACE environment variable is used if it is not set.
+	// +optional
+	InstallNamespace string `json:"installNamespace,omitempty"`
+
+	// OperatorConditionName is the name of the OLM operator condition, the name and version of the operator.
+	// The OPERATOR_CONDITION_NAME environment variable set by OLM is used if it is not set.
+	// +optional
+	OperatorConditionName string `json:"operatorConditionName,omitempty"`
+}
+
+// Tracing configures the export of the OpenTelemetry spans of the operator.
+type Tracing struct {
+	// Endpoint is the host and port of the OTLP HTTP collector. The spans are not exported if it is empty.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Insecure sends the spans to the collector without TLS.
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+
+	// SamplingRatio is the ratio of the traces that are sampled, all the traces if it is not set.
+	// +optional
+	SamplingRatio float64 `json:"samplingRatio,omitempty"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProviderOperatorConfig{})
+}
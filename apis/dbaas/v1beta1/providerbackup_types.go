@@ -0,0 +1,99 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Defines the phases of a backup.
+type BackupPhase string
+
+// Constants for the backup phases.
+const (
+	BackupPhasePending    BackupPhase = "Pending"
+	BackupPhaseInProgress BackupPhase = "InProgress"
+	BackupPhaseCompleted  BackupPhase = "Completed"
+	BackupPhaseFailed     BackupPhase = "Failed"
+	BackupPhaseDeleting   BackupPhase = "Deleting"
+)
+
+// ProviderBackupSpec defines the desired state of ProviderBackup
+type ProviderBackupSpec struct {
+	// A reference to the ProviderInstance to back up.
+	// The namespace defaults to the namespace of the backup, and cannot be another namespace.
+	InstanceRef v1beta1.NamespacedName `json:"instanceRef"`
+}
+
+// ProviderBackupStatus defines the observed state of ProviderBackup
+type ProviderBackupStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// The provider-specific identifier of the backup.
+	BackupID string `json:"backupID,omitempty"`
+
+	// The provider-specific identifier of the backed up instance.
+	InstanceID string `json:"instanceID,omitempty"`
+
+	// +kubebuilder:validation:Enum=Pending;InProgress;Completed;Failed;Deleting
+	// +kubebuilder:default=Pending
+	// Represents the following backup phases.
+	// Pending: Waiting for the instance to be ready.
+	// InProgress: The provider is taking the backup.
+	// Completed: The backup is available for restore.
+	// Failed: The provider failed to take the backup.
+	// Deleting: The backup is being deleted from the provider.
+	Phase BackupPhase `json:"phase,omitempty"`
+
+	// Completion percentage of the backup, as reported by the provider.
+	Progress int32 `json:"progress,omitempty"`
+
+	// Size of the backup in bytes, as reported by the provider.
+	SizeBytes int64 `json:"sizeBytes,omitempty"`
+
+	// The time the provider started the backup.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// The time the provider completed the backup.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// ProviderBackup is the Schema for the providerbackups API
+type ProviderBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProviderBackupSpec   `json:"spec,omitempty"`
+	Status ProviderBackupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ProviderBackupList contains a list of ProviderBackup
+type ProviderBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProviderBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProviderBackup{}, &ProviderBackupList{})
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProviderBackupScheduleSpec defines the desired state of ProviderBackupSchedule
+type ProviderBackupScheduleSpec struct {
+	// A reference to the ProviderInstance to back up.
+	// The namespace defaults to the namespace of the schedule, and cannot be another namespace.
+	InstanceRef v1beta1.NamespacedName `json:"instanceRef"`
+
+	// +kubebuilder:validation:MinLength=1
+	// The schedule in Cron format, see https://en.wikipedia.org/wiki/Cron.
+	// A time zone can be set with the CRON_TZ= prefix, the default is UTC.
+	Schedule string `json:"schedule"`
+
+	// Defines which completed backups are kept, all backups are kept if not set.
+	Retention BackupRetentionPolicy `json:"retention,omitempty"`
+}
+
+// BackupRetentionPolicy defines which completed backups of a schedule are kept.
+// A backup is kept if any of the rules selects it.
+type BackupRetentionPolicy struct {
+	// +kubebuilder:validation:Minimum=0
+	// The number of most recent backups to keep.
+	KeepLast int32 `json:"keepLast,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// The number of days for which the most recent backup of the day is kept.
+	KeepDaily int32 `json:"keepDaily,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// The number of weeks for which the most recent backup of the week is kept.
+	KeepWeekly int32 `json:"keepWeekly,omitempty"`
+}
+
+// ProviderBackupScheduleStatus defines the observed state of ProviderBackupSchedule
+type ProviderBackupScheduleStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// The name of the ProviderBackup created by the last run of the schedule.
+	LastBackupName string `json:"lastBackupName,omitempty"`
+
+	// The scheduled time of the last run of the schedule.
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// The completion time of the most recent successful backup.
+	LastSuccessfulBackupTime *metav1.Time `json:"lastSuccessfulBackupTime,omitempty"`
+
+	// The number of runs that failed since the last successful backup. A run fails if its backup failed,
+	// if its backup did not finish before the next run, or if it was missed or its backup could not be created.
+	FailureCount int32 `json:"failureCount,omitempty"`
+
+	// The number of runs since the last successful backup that were missed or whose backup could not be created.
+	MissedRuns int32 `json:"missedRuns,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// ProviderBackupSchedule is the Schema for the providerbackupschedules API
+type ProviderBackupSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProviderBackupScheduleSpec   `json:"spec,omitempty"`
+	Status ProviderBackupScheduleStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ProviderBackupScheduleList contains a list of ProviderBackupSchedule
+type ProviderBackupScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProviderBackupSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProviderBackupSchedule{}, &ProviderBackupScheduleList{})
+}
@@ -26,6 +26,14 @@ import (
 
 // ProviderConnectionSpec defines the desired state of ProviderConnection
 
+// ProviderConnectionStatus defines the observed state of ProviderConnection
+type ProviderConnectionStatus struct {
+	v1beta1.DBaaSConnectionStatus `json:",inline"`
+
+	// The time the password of the database user of the connection was last set.
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+}
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 
@@ -34,8 +42,8 @@ type ProviderConnection struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec   v1beta1.DBaaSConnectionSpec   `json:"spec,omitempty"`
-	Status v1beta1.DBaaSConnectionStatus `json:"status,omitempty"`
+	Spec   v1beta1.DBaaSConnectionSpec `json:"spec,omitempty"`
+	Status ProviderConnectionStatus    `json:"status,omitempty"`
 }
 
 //+kubebuilder:object:root=true
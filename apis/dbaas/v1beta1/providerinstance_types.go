@@ -21,18 +21,27 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
-
 // ProviderInstanceSpec defines the desired state of ProviderInstance
 type ProviderInstanceSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	v1beta1.DBaaSInstanceSpec `json:",inline"`
 
-	// Foo is an example field of ProviderInstance. Edit providerinstance_types.go to remove/update
-	Foo string `json:"foo,omitempty"`
+	// Suspends the cluster of the instance while set, unsetting it resumes the cluster.
+	// The cluster is suspended in the maintenance window of the instance if one is set.
+	Suspend bool `json:"suspend,omitempty"`
 }
 
+// Provider specific parameters used for provisioning, in addition to the ones defined by the DBaaS API.
+const (
+	// The ID of an instance in the same inventory to clone the new instance from.
+	ProvisioningSourceInstanceID v1beta1.ProvisioningParameterType = "sourceInstanceID"
+	// The ID of a backup of the source instance to clone the new instance from.
+	ProvisioningSourceBackupID v1beta1.ProvisioningParameterType = "sourceBackupID"
+	// An RFC 3339 timestamp to clone the source instance at, cannot be combined with a backup ID.
+	ProvisioningSourcePointInTime v1beta1.ProvisioningParameterType = "sourcePointInTime"
+	// The database version of the instance, changing it upgrades the instance.
+	ProvisioningVersion v1beta1.ProvisioningParameterType = "version"
+)
+
 // ProviderInstanceStatus defines the observed state of ProviderInstance
 type ProviderInstanceStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
@@ -47,7 +56,7 @@ type ProviderInstance struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec   v1beta1.DBaaSInstanceSpec   `json:"spec,omitempty"`
+	Spec   ProviderInstanceSpec        `json:"spec,omitempty"`
 	Status v1beta1.DBaaSInstanceStatus `json:"status,omitempty"`
 }
 
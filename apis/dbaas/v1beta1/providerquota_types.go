@@ -0,0 +1,83 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProviderQuotaSpec defines the desired state of ProviderQuota
+type ProviderQuotaSpec struct {
+	// The limits for the ProviderInstances and ProviderConnections in the namespace of the quota.
+	Hard ProviderQuotaResources `json:"hard"`
+}
+
+// ProviderQuotaResources is a set of amounts counted by a ProviderQuota. An amount that is not set is not limited.
+type ProviderQuotaResources struct {
+	// +kubebuilder:validation:Minimum=0
+	// The number of ProviderInstances that are provisioned, or accepted and not provisioned yet.
+	Instances *int64 `json:"instances,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// The number of ready ProviderConnections.
+	Connections *int64 `json:"connections,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// The total of the nodes provisioning parameters of the counted ProviderInstances.
+	Nodes *int64 `json:"nodes,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// The total of the storageGib provisioning parameters of the counted ProviderInstances.
+	StorageGib *int64 `json:"storageGib,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// The total of the spendLimit provisioning parameters of the counted ProviderInstances.
+	SpendLimit *int64 `json:"spendLimit,omitempty"`
+}
+
+// ProviderQuotaStatus defines the observed state of ProviderQuota
+type ProviderQuotaStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// The current usage in the namespace of the quota.
+	Used ProviderQuotaResources `json:"used,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// ProviderQuota is the Schema for the providerquotas API
+type ProviderQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProviderQuotaSpec   `json:"spec,omitempty"`
+	Status ProviderQuotaStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ProviderQuotaList contains a list of ProviderQuota
+type ProviderQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProviderQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProviderQuota{}, &ProviderQuotaList{})
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Defines the phases of a restore.
+type RestorePhase string
+
+// Constants for the restore phases.
+const (
+	RestorePhasePending    RestorePhase = "Pending"
+	RestorePhaseInProgress RestorePhase = "InProgress"
+	RestorePhaseCompleted  RestorePhase = "Completed"
+	RestorePhaseFailed     RestorePhase = "Failed"
+)
+
+// ProviderRestoreSpec defines the desired state of ProviderRestore
+type ProviderRestoreSpec struct {
+	// A reference to the completed ProviderBackup to restore from.
+	// The namespace defaults to the namespace of the restore, and cannot be another namespace.
+	BackupRef v1beta1.NamespacedName `json:"backupRef"`
+
+	// The name of the new cluster created from the backup.
+	ClusterName string `json:"clusterName"`
+}
+
+// ProviderRestoreStatus defines the observed state of ProviderRestore
+type ProviderRestoreStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// The provider-specific identifier of the cluster created from the backup.
+	// The cluster is listed by the inventory and is not deleted with the restore.
+	InstanceID string `json:"instanceID,omitempty"`
+
+	// +kubebuilder:validation:Enum=Pending;InProgress;Completed;Failed
+	// +kubebuilder:default=Pending
+	// Represents the following restore phases.
+	// Pending: Waiting for the backup to complete.
+	// InProgress: The provider is creating the cluster from the backup.
+	// Completed: The cluster has been created from the backup.
+	// Failed: The provider failed to restore the backup.
+	Phase RestorePhase `json:"phase,omitempty"`
+
+	// The time the provider started the restore.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// The time the provider completed the restore.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// ProviderRestore is the Schema for the providerrestores API
+type ProviderRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProviderRestoreSpec   `json:"spec,omitempty"`
+	Status ProviderRestoreStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ProviderRestoreList contains a list of ProviderRestore
+type ProviderRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProviderRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProviderRestore{}, &ProviderRestoreList{})
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// mock-provider serves the provider REST API from the fake cluster store, for demos and
+// end-to-end tests without a cloud account. Run the operator with --provider-url to use it.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+)
+
+var setupLog = ctrl.Log.WithName("mock-provider")
+
+func main() {
+	var bindAddr string
+	var stateFile string
+	var fixturesFile string
+	var provisioningDelay time.Duration
+	flag.StringVar(&bindAddr, "bind-address", ":8090", "The address the provider API binds to.")
+	flag.StringVar(&stateFile, "state-file", "mock-provider-state.json",
+		"The file the clusters are saved to and loaded from. The clusters are kept only in memory if it is empty.")
+	flag.StringVar(&fixturesFile, "fixtures", os.Getenv(testutil.FixturesEnvVar),
+		"The file of the initial clusters of the tenants, used when the state file does not exist yet.")
+	flag.DurationVar(&provisioningDelay, "provisioning-delay", 30*time.Second,
+		"The time a new cluster stays in the CREATING state.")
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	if len(fixturesFile) != 0 {
+		if err := testutil.LoadFixtures(fixturesFile); err != nil {
+			setupLog.Error(err, "unable to load the fixtures")
+			os.Exit(1)
+		}
+	}
+	testutil.SetProvisioningDelay(provisioningDelay)
+	provider, err := testutil.NewMockProvider(stateFile)
+	if err != nil {
+		setupLog.Error(err, "unable to load the state file", "file", stateFile)
+		os.Exit(1)
+	}
+
+	server := &http.Server{
+		Addr:              bindAddr,
+		Handler:           provider,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx := ctrl.SetupSignalHandler()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			setupLog.Error(err, "unable to stop the server")
+		}
+	}()
+
+	setupLog.Info("serving the provider API", "address", bindAddr, "stateFile", stateFile)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		setupLog.Error(err, "problem running the server")
+		os.Exit(1)
+	}
+	if err := provider.Save(); err != nil {
+		setupLog.Error(err, "unable to save the state file", "file", stateFile)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+const (
+	// managedByLabel is set on the Secrets and ConfigMaps the operator creates for the connections
+	managedByLabel = "managed-by"
+	managedByValue = "dbaas-operator"
+)
+
+// NewCacheFunc returns the cache of the manager. The cache watches the namespaces, or all the namespaces if
+// there are none, and the install namespace. It only keeps the Secrets and ConfigMaps created by the operator,
+// and the Deployments of the install namespace, so the credentials secrets must be read with the API reader.
+func NewCacheFunc(namespaces []string, installNamespace string) cache.NewCacheFunc {
+	selectors := cache.SelectorsByObject{
+		&corev1.Secret{}:    {Label: labels.SelectorFromSet(labels.Set{managedByLabel: managedByValue})},
+		&corev1.ConfigMap{}: {Label: labels.SelectorFromSet(labels.Set{managedByLabel: managedByValue})},
+	}
+	if len(installNamespace) != 0 {
+		selectors[&appsv1.Deployment{}] = cache.ObjectSelector{
+			Field: fields.OneTermEqualSelector("metadata.namespace", installNamespace),
+		}
+	}
+
+	newCache := cache.New
+	if len(namespaces) != 0 {
+		watched := append([]string{}, namespaces...)
+		if len(installNamespace) != 0 {
+			watched = appendMissing(watched, installNamespace)
+		}
+		newCache = cache.MultiNamespacedCacheBuilder(watched)
+	}
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		opts.SelectorsByObject = selectors
+		return newCache(config, opts)
+	}
+}
+
+func appendMissing(namespaces []string, namespace string) []string {
+	for _, ns := range namespaces {
+		if ns == namespace {
+			return namespaces
+		}
+	}
+	return append(namespaces, namespace)
+}
@@ -1,9 +1,21 @@
 package dbaas
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"strconv"
 	"time"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type ConditionReason string
@@ -13,7 +25,24 @@ const (
 	DefaultRetryDelay      = time.Second * 5
 	DefaultSyncPeriod      = time.Minute * 180
 	InstallNamespaceEnvVar = "INSTALL_NAMESPACE"
-	instanceFinalizer      = "providerdbaasinstance.dbaas.redhat.com/cluster"
+	backupFinalizer        = "providerbackup.dbaas.redhat.com/backup"
+	backupScheduleLabel    = "dbaas.redhat.com/backup-schedule"
+
+	// PauseReconcileAnnotation stops the operator from reconciling the annotated resource while set to "true"
+	PauseReconcileAnnotation = "dbaas.redhat.com/pause-reconcile"
+	// MaintenanceWindowAnnotation holds the weekly window disruptive operations of a ProviderInstance wait for,
+	// it can be set on a ProviderInstance or on its ProviderInventory
+	MaintenanceWindowAnnotation = "dbaas.redhat.com/maintenance-window"
+	// AllowedNamespacesAnnotation on an inventory lists the namespaces allowed to reference it, "*" allows all namespaces
+	AllowedNamespacesAnnotation = "dbaas.redhat.com/allowed-namespaces"
+	// AllowedNamespaceSelectorAnnotation on an inventory is a label selector of the namespaces allowed to reference it
+	AllowedNamespaceSelectorAnnotation = "dbaas.redhat.com/allowed-namespace-selector"
+	// CredentialRotationIntervalAnnotation holds the interval, as a duration like "720h", after which the password of
+	// the database user of a ProviderConnection is rotated, it can be set on a ProviderConnection or on its ProviderInventory
+	CredentialRotationIntervalAnnotation = "dbaas.redhat.com/credential-rotation-interval"
+	// RotateCredentialsAnnotation rotates the password of the database user of the annotated ProviderConnection once
+	// when set to "true", the annotation is removed after the rotation
+	RotateCredentialsAnnotation = "dbaas.redhat.com/rotate-credentials"
 
 	databaseType     = "providerdb"
 	databaseProvider = "provider Cloud"
@@ -27,6 +56,16 @@ const (
 	connectionConditionReadyType string = "ReadyForBinding"
 	instanceConditionReadyType   string = "ProvisionReady"
 	providerConditionReadyType   string = "ProviderReady"
+	backupConditionReadyType     string = "BackupReady"
+	restoreConditionReadyType    string = "RestoreReady"
+	backupScheduleConditionType  string = "BackupScheduleReady"
+	instanceConditionSuspended   string = "Suspended"
+	instanceConditionUpgraded    string = "Upgraded"
+	instanceConditionMaintenance string = "MaintenancePending"
+	// instance conditions reported next to instanceConditionReadyType
+	instanceConditionCredentialsValid string = "CredentialsValid"
+	instanceConditionInventoryReady   string = "InventoryReady"
+	quotaConditionType                string = "WithinLimits"
 
 	SuccessConnection string = "Successfully retrieved the connection detail\n"
 
@@ -40,6 +79,31 @@ const (
 	ConnectionNotReady        ConditionReason = "ConnectionNotReady"
 	ProviderReady             ConditionReason = "Ready"
 	ProviderProcessingPending ConditionReason = "ProcessingPending"
+	InstanceNotFound          ConditionReason = "InstanceNotFound"
+	InstanceNotReady          ConditionReason = "InstanceNotReady"
+	BackupInProgress          ConditionReason = "BackupInProgress"
+	BackupCompleted           ConditionReason = "BackupCompleted"
+	BackupFailed              ConditionReason = "BackupFailed"
+	BackupNotFound            ConditionReason = "BackupNotFound"
+	BackupNotReady            ConditionReason = "BackupNotReady"
+	RestoreInProgress         ConditionReason = "RestoreInProgress"
+	RestoreCompleted          ConditionReason = "RestoreCompleted"
+	RestoreFailed             ConditionReason = "RestoreFailed"
+	ScheduleOK                ConditionReason = "ScheduleOK"
+	InstanceSuspended         ConditionReason = "Suspended"
+	InstanceRunning           ConditionReason = "Running"
+	UpgradeInProgress         ConditionReason = "UpgradeInProgress"
+	UpgradeCompleted          ConditionReason = "UpgradeCompleted"
+	UpgradeRollbackInProgress ConditionReason = "UpgradeRollbackInProgress"
+	UpgradeRolledBack         ConditionReason = "UpgradeRolledBack"
+	MaintenanceWindowClosed   ConditionReason = "MaintenanceWindowClosed"
+	NoPendingMaintenance      ConditionReason = "NoPendingOperation"
+	InventoryReady            ConditionReason = "InventoryReady"
+	InventoryNotReady         ConditionReason = "InventoryNotReady"
+	CredentialsOK             ConditionReason = "CredentialsOK"
+	NamespaceNotAllowed       ConditionReason = "NamespaceNotAllowed"
+	QuotaExceeded             ConditionReason = "QuotaExceeded"
+	QuotaOK                   ConditionReason = "QuotaOK"
 
 	InputError          ConditionReason = "InputError"
 	BackendError        ConditionReason = "BackendError"
@@ -47,8 +111,19 @@ const (
 	AuthenticationError ConditionReason = "AuthenticationError"
 )
 
+// syncPeriod is the sync period set by the config file
+var syncPeriod time.Duration
+
+// SetSyncPeriod sets the sync period for next reconciliation, overriding SYNC_PERIOD_MIN
+func SetSyncPeriod(period time.Duration) {
+	syncPeriod = period
+}
+
 // GetSyncPeriod get the sync period for next reconciliation
 func GetSyncPeriod() time.Duration {
+	if syncPeriod > 0 {
+		return syncPeriod
+	}
 	if sp, ok := os.LookupEnv("SYNC_PERIOD_MIN"); ok {
 		spInt, err := strconv.Atoi(sp)
 		if err == nil {
@@ -57,3 +132,65 @@ func GetSyncPeriod() time.Duration {
 	}
 	return DefaultSyncPeriod
 }
+
+// errCrossNamespaceRef is returned for a reference to an object in another namespace.
+var errCrossNamespaceRef = errors.New("references to other namespaces are not allowed")
+
+// localObjectKey returns the key of the object referenced from the namespace. The namespace of the reference
+// defaults to that namespace and cannot be another one, so that the objects of a namespace cannot act on the
+// instances and backups of other namespaces.
+func localObjectKey(ref dbaasv1beta1.NamespacedName, namespace string) (client.ObjectKey, error) {
+	if len(ref.Namespace) > 0 && ref.Namespace != namespace {
+		return client.ObjectKey{}, fmt.Errorf("%w: %v/%v is not in namespace %v", errCrossNamespaceRef, ref.Namespace, ref.Name, namespace)
+	}
+	return client.ObjectKey{Namespace: namespace, Name: ref.Name}, nil
+}
+
+// isReconcilePaused checks whether the reconciliation of the resource is paused by annotation
+func isReconcilePaused(obj client.Object) bool {
+	return obj.GetAnnotations()[PauseReconcileAnnotation] == "true"
+}
+
+// setStatusCondition sets a condition observed for the current generation of obj
+func setStatusCondition(conditions *[]metav1.Condition, obj client.Object, conditionType string,
+	status metav1.ConditionStatus, reason ConditionReason, msg string) {
+	apimeta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             string(reason),
+		Message:            msg,
+		ObservedGeneration: obj.GetGeneration(),
+	})
+}
+
+// patchStatus writes the status changes the reconcile made to obj since it read original, with a merge
+// patch of the status fields only. The patch does not carry the resource version of obj, so a change of
+// the object since it was read does not make the status write fail: the fields the reconcile changed are
+// written and the other fields keep their stored values. Nothing is written when the status is unchanged.
+func patchStatus(ctx context.Context, c client.Client, original, obj client.Object) error {
+	originalJSON, err := statusJSON(original)
+	if err != nil {
+		return err
+	}
+	desiredJSON, err := statusJSON(obj)
+	if err != nil {
+		return err
+	}
+	patch, err := jsonpatch.CreateMergePatch(originalJSON, desiredJSON)
+	if err != nil {
+		return err
+	}
+	if string(patch) == "{}" {
+		return nil
+	}
+	return client.IgnoreNotFound(c.Status().Patch(ctx, obj, client.RawPatch(types.MergePatchType, patch)))
+}
+
+// statusJSON returns the status of obj as a JSON object with the status as its only field
+func statusJSON(obj client.Object) ([]byte, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]interface{}{"status": content["status"]})
+}
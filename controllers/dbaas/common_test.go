@@ -0,0 +1,205 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+)
+
+func newTestScheme(g *WithT) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	g.Expect(v1beta1.AddToScheme(scheme)).To(Succeed())
+	return scheme
+}
+
+// racingClient modifies the object before every status write, as another writer would between
+// the read and the status write of a reconcile, and records the last written object.
+type racingClient struct {
+	client.Client
+	written client.Object
+}
+
+func (c *racingClient) Status() client.StatusWriter {
+	return &racingStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+func (c *racingClient) touch(ctx context.Context, obj client.Object) error {
+	latest := obj.DeepCopyObject().(client.Object)
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), latest); err != nil {
+		return err
+	}
+	annotations := latest.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	touched, _ := strconv.Atoi(annotations["test/touched"])
+	annotations["test/touched"] = strconv.Itoa(touched + 1)
+	latest.SetAnnotations(annotations)
+	return c.Client.Update(ctx, latest)
+}
+
+type racingStatusWriter struct {
+	client.StatusWriter
+	client *racingClient
+}
+
+func (w *racingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := w.client.touch(ctx, obj); err != nil {
+		return err
+	}
+	if err := w.StatusWriter.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	w.client.written = obj.DeepCopyObject().(client.Object)
+	return nil
+}
+
+func (w *racingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := w.client.touch(ctx, obj); err != nil {
+		return err
+	}
+	if err := w.StatusWriter.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+	w.client.written = obj.DeepCopyObject().(client.Object)
+	return nil
+}
+
+func TestPatchStatusAfterConcurrentChange(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	stored := &v1beta1.ProviderInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: "instance", Namespace: "default"},
+	}
+	c := &racingClient{Client: fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(stored).Build()}
+
+	observed := &v1beta1.ProviderInstance{}
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(stored), observed)).To(Succeed())
+	original := observed.DeepCopy()
+	observed.Status.Phase = dbaasv1beta1.InstancePhaseReady
+	observed.Status.InstanceID = "instance-id"
+	setStatusCondition(&observed.Status.Conditions, observed, instanceConditionReadyType, metav1.ConditionTrue, InstanceReady, "Cluster is ready")
+
+	g.Expect(patchStatus(ctx, c, original, observed)).To(Succeed())
+
+	latest := &v1beta1.ProviderInstance{}
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(stored), latest)).To(Succeed())
+	g.Expect(latest.Annotations).To(HaveKeyWithValue("test/touched", "1"))
+	g.Expect(latest.Status).To(Equal(observed.Status))
+}
+
+func TestPatchStatusRemovesClearedFields(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	stored := &v1beta1.ProviderInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: "instance", Namespace: "default"},
+		Status: dbaasv1beta1.DBaaSInstanceStatus{
+			InstanceID:   "instance-id",
+			InstanceInfo: map[string]string{"targetVersion": "v16.1", "state": "CREATED"},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(stored).Build()
+
+	observed := &v1beta1.ProviderInstance{}
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(stored), observed)).To(Succeed())
+	original := observed.DeepCopy()
+	observed.Status.InstanceInfo = map[string]string{"state": "SUSPENDED"}
+
+	g.Expect(patchStatus(ctx, c, original, observed)).To(Succeed())
+
+	latest := &v1beta1.ProviderInstance{}
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(stored), latest)).To(Succeed())
+	g.Expect(latest.Status.InstanceInfo).To(Equal(map[string]string{"state": "SUSPENDED"}))
+	g.Expect(latest.Status.InstanceID).To(Equal("instance-id"))
+}
+
+func TestPatchStatusIgnoresDeletedObject(t *testing.T) {
+	g := NewWithT(t)
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).Build()
+
+	original := &v1beta1.ProviderInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: "instance", Namespace: "default"},
+	}
+	instance := original.DeepCopy()
+	instance.Status.Phase = dbaasv1beta1.InstancePhaseDeleted
+	g.Expect(patchStatus(context.Background(), c, original, instance)).To(Succeed())
+}
+
+func TestPatchStatusAfterStaleRead(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	stored := &v1beta1.ProviderInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: "instance", Namespace: "default"},
+		Status: dbaasv1beta1.DBaaSInstanceStatus{
+			Phase:        dbaasv1beta1.InstancePhaseFailed,
+			InstanceID:   "instance-id",
+			InstanceInfo: map[string]string{"state": "SUSPENDED", "targetVersion": "v16.1"},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(stored).Build()
+
+	// the reconcile read an older copy of the object than the stored one, as from a lagging cache
+	observed := stored.DeepCopy()
+	observed.Status.Phase = dbaasv1beta1.InstancePhaseCreating
+	observed.Status.InstanceInfo = map[string]string{"state": "CREATING"}
+	original := observed.DeepCopy()
+	observed.Status.Phase = dbaasv1beta1.InstancePhaseReady
+	observed.Status.InstanceInfo = map[string]string{"state": "CREATED"}
+
+	g.Expect(patchStatus(ctx, c, original, observed)).To(Succeed())
+
+	latest := &v1beta1.ProviderInstance{}
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(stored), latest)).To(Succeed())
+	g.Expect(latest.Status.Phase).To(Equal(dbaasv1beta1.InstancePhaseReady))
+	g.Expect(latest.Status.InstanceInfo).To(Equal(map[string]string{"state": "CREATED", "targetVersion": "v16.1"}))
+	g.Expect(latest.Status.InstanceID).To(Equal("instance-id"))
+}
+
+func TestPatchStatusSkipsUnchangedStatus(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	stored := &v1beta1.ProviderInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: "instance", Namespace: "default"},
+		Status:     dbaasv1beta1.DBaaSInstanceStatus{Phase: dbaasv1beta1.InstancePhaseReady},
+	}
+	c := &racingClient{Client: fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(stored).Build()}
+
+	observed := &v1beta1.ProviderInstance{}
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(stored), observed)).To(Succeed())
+	original := observed.DeepCopy()
+	observed.Status.Phase = dbaasv1beta1.InstancePhaseReady
+
+	g.Expect(patchStatus(ctx, c, original, observed)).To(Succeed())
+	g.Expect(c.written).To(BeNil())
+}
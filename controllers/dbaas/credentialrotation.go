@@ -0,0 +1,87 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// passwordBytes is the number of random bytes of a generated password.
+const passwordBytes = 24
+
+// getRotationInterval returns the credential rotation interval of the connection, which defaults to the one of its
+// inventory, or 0 if the credentials are only rotated on demand.
+func getRotationInterval(inventory *v1beta1.ProviderInventory, connection *v1beta1.ProviderConnection) (time.Duration, error) {
+	value, ok := connection.Annotations[CredentialRotationIntervalAnnotation]
+	if !ok {
+		value, ok = inventory.Annotations[CredentialRotationIntervalAnnotation]
+	}
+	if !ok {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v annotation: %w", CredentialRotationIntervalAnnotation, err)
+	}
+	if interval <= 0 {
+		return 0, fmt.Errorf("invalid %v annotation: %q is not a positive duration", CredentialRotationIntervalAnnotation, value)
+	}
+	return interval, nil
+}
+
+// rotationDue checks whether the password of the database user of the connection has to be set. It is set when the
+// connection has no credentials yet, when a rotation is requested by annotation, and when the interval has elapsed
+// since the last rotation.
+func rotationDue(connection *v1beta1.ProviderConnection, secret *corev1.Secret, interval time.Duration, now time.Time) bool {
+	if secret == nil || len(secret.Data["username"]) == 0 || len(secret.Data["password"]) == 0 {
+		return true
+	}
+	// the credentials were not set through the provider yet
+	if connection.Status.LastRotationTime == nil {
+		return true
+	}
+	if connection.Annotations[RotateCredentialsAnnotation] == "true" {
+		return true
+	}
+	return interval > 0 && !now.Before(nextRotation(connection, interval))
+}
+
+// nextRotation returns when the credentials of the connection are rotated next.
+func nextRotation(connection *v1beta1.ProviderConnection, interval time.Duration) time.Time {
+	return connection.Status.LastRotationTime.Add(interval)
+}
+
+// sqlUserName returns the name of the database user of the connection. The namespace and the name are
+// separated by an underscore, which they cannot contain, so the connections cannot share a user.
+func sqlUserName(connection *v1beta1.ProviderConnection) string {
+	return fmt.Sprintf("%s_%s", connection.Namespace, connection.Name)
+}
+
+// generatePassword returns a random password.
+func generatePassword() (string, error) {
+	data := make([]byte, passwordBytes)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
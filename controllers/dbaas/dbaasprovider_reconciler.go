@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
 	"github.com/go-logr/logr"
 	v1 "k8s.io/api/apps/v1"
 	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	label "k8s.io/apimachinery/pkg/labels"
@@ -37,10 +40,14 @@ const (
 type DBaaSProviderReconciler struct {
 	client.Client
 	*runtime.Scheme
-	Log                      logr.Logger
-	Clientset                *kubernetes.Clientset
-	operatorNameVersion      string
-	operatorInstallNamespace string
+	Log       logr.Logger
+	Clientset *kubernetes.Clientset
+	// CloudService is used for the provider calls that do not depend on an account, like listing the database versions
+	CloudService testutil.Service
+	// InstallNamespace is the namespace of the operator deployment, INSTALL_NAMESPACE if empty
+	InstallNamespace string
+	// OperatorConditionName is the name and version of the operator, OPERATOR_CONDITION_NAME if empty
+	OperatorConditionName string
 }
 
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
@@ -54,7 +61,9 @@ func (r *DBaaSProviderReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	// meaning we have a reconcile entry-point on operator start-up, so now we can create a cluster-scoped resource
 	// owned by the operator's ClusterRole to ensure cleanup on uninstall
 
-	dep := &v1.Deployment{}
+	// the deployments are watched with their metadata only, so fetch the metadata from the same cache
+	dep := &metav1.PartialObjectMetadata{}
+	dep.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Deployment"))
 	if err := r.Get(ctx, req.NamespacedName, dep); err != nil {
 		if errors.IsNotFound(err) {
 			// CR deleted since request queued, child objects getting GC'd, no requeue
@@ -77,6 +86,16 @@ func (r *DBaaSProviderReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{Requeue: true}, nil
 	}
 
+	var versions []testutil.ClusterVersion
+	if r.CloudService != nil {
+		versionList, _, err := r.CloudService.ListVersions(ctx)
+		if err != nil {
+			log.Error(err, "unable to list the database versions offered by the provider")
+			return ctrl.Result{}, err
+		}
+		versions = versionList.Versions
+	}
+
 	registrationCR := &dbaasv1beta1.DBaaSProvider{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: providerResourceName,
@@ -90,7 +109,7 @@ func (r *DBaaSProviderReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			// crunchy bridge registration custom resource isn't present,so create now with ClusterRole owner for GC
 			opts := &client.ListOptions{
 				LabelSelector: label.SelectorFromSet(map[string]string{
-					"olm.owner":      r.operatorNameVersion,
+					"olm.owner":      r.OperatorConditionName,
 					"olm.owner.kind": "ClusterServiceVersion",
 				}),
 			}
@@ -107,13 +126,13 @@ func (r *DBaaSProviderReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 				return ctrl.Result{}, err
 			}
 
-			registrationCR = buildProviderCR(clusterRoleList)
+			registrationCR = buildProviderCR(clusterRoleList, versions)
 			if err := r.Create(ctx, registrationCR); err != nil {
 				log.Error(err, "error while creating new cluster-scoped resource")
 				return ctrl.Result{}, err
 			} else {
 				log.Info("cluster-scoped resource created")
-				return ctrl.Result{}, nil
+				return r.versionsRefreshResult(), nil
 			}
 		}
 		// error fetching the resource, requeue and try again
@@ -121,26 +140,50 @@ func (r *DBaaSProviderReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	// the versions offered by the provider change over time, the spec is kept in sync with them
+	spec := buildProviderSpec(dbaasv1beta1.ProvisioningParameter{}, dbaasv1beta1.ProvisioningParameter{}, buildVersionParameter(versions))
+	if !equality.Semantic.DeepEqual(registrationCR.Spec, spec) {
+		registrationCR.Spec = spec
+		if err := r.Update(ctx, registrationCR); err != nil {
+			log.Error(err, "error while updating the cluster-scoped resource")
+			return ctrl.Result{}, err
+		}
+		log.Info("cluster-scoped resource updated")
+	}
+
+	return r.versionsRefreshResult(), nil
+}
+
+// versionsRefreshResult requeues the reconcile after the sync period to refresh the database versions
+// offered by the provider, the deployment events only trigger a reconcile when the operator starts.
+func (r *DBaaSProviderReconciler) versionsRefreshResult() ctrl.Result {
+	if r.CloudService == nil {
+		return ctrl.Result{}
+	}
+	return ctrl.Result{RequeueAfter: GetSyncPeriod()}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *DBaaSProviderReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
-	// envVar set in controller-manager's Deployment YAML
-	if operatorInstallNamespace, found := os.LookupEnv("INSTALL_NAMESPACE"); !found {
-		err := fmt.Errorf("INSTALL_NAMESPACE must be set")
-		return err
-	} else {
-		r.operatorInstallNamespace = operatorInstallNamespace
+	// envVar set in controller-manager's Deployment YAML, unless the config file sets the namespace
+	if len(r.InstallNamespace) == 0 {
+		if operatorInstallNamespace, found := os.LookupEnv(InstallNamespaceEnvVar); !found {
+			err := fmt.Errorf("INSTALL_NAMESPACE must be set")
+			return err
+		} else {
+			r.InstallNamespace = operatorInstallNamespace
+		}
 	}
 
-	// envVar set for all operators
-	if operatorNameEnvVar, found := os.LookupEnv("OPERATOR_CONDITION_NAME"); !found {
-		err := fmt.Errorf("OPERATOR_CONDITION_NAME must be set")
-		return err
-	} else {
-		r.operatorNameVersion = operatorNameEnvVar
+	// envVar set for all operators, unless the config file sets the operator condition name
+	if len(r.OperatorConditionName) == 0 {
+		if operatorNameEnvVar, found := os.LookupEnv("OPERATOR_CONDITION_NAME"); !found {
+			err := fmt.Errorf("OPERATOR_CONDITION_NAME must be set")
+			return err
+		} else {
+			r.OperatorConditionName = operatorNameEnvVar
+		}
 	}
 
 	customRateLimiter := workqueue.NewItemExponentialFailureRateLimiter(30*time.Second, 30*time.Minute)
@@ -152,7 +195,7 @@ func (r *DBaaSProviderReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			builder.WithPredicates(r.ignoreOtherDeployments()),
 			builder.OnlyMetadata,
 		).
-		Complete(r)
+		Complete(traceReconciles("DBaaSProvider", r))
 }
 
 // ignoreOtherDeployments  only on a 'create' event is issued for the deployment
@@ -175,11 +218,11 @@ func (r *DBaaSProviderReconciler) ignoreOtherDeployments() predicate.Predicate {
 
 func (r *DBaaSProviderReconciler) evaluatePredicateObject(obj client.Object) bool {
 	lbls := obj.GetLabels()
-	if obj.GetNamespace() == r.operatorInstallNamespace {
+	if obj.GetNamespace() == r.InstallNamespace {
 		if val, keyFound := lbls["olm.owner.kind"]; keyFound {
 			if val == "ClusterServiceVersion" {
 				if val, keyFound := lbls["olm.owner"]; keyFound {
-					return val == r.operatorNameVersion
+					return val == r.OperatorConditionName
 				}
 			}
 		}
@@ -204,7 +247,7 @@ func (r *DBaaSProviderReconciler) checkCrdInstalled(groupVersion, kind string) (
 	return false, nil
 }
 
-func buildProviderCR(clusterRoleList *rbac.ClusterRoleList) *dbaasv1beta1.DBaaSProvider {
+func buildProviderCR(clusterRoleList *rbac.ClusterRoleList, versions []testutil.ClusterVersion) *dbaasv1beta1.DBaaSProvider {
 	instance := &dbaasv1beta1.DBaaSProvider{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: providerResourceName,
@@ -220,15 +263,37 @@ func buildProviderCR(clusterRoleList *rbac.ClusterRoleList) *dbaasv1beta1.DBaaSP
 			},
 			Labels: map[string]string{"related-to": "dbaas-operator", "type": "dbaas-provider-registration"},
 		},
-		Spec: buildProviderSpec(dbaasv1beta1.ProvisioningParameter{}, dbaasv1beta1.ProvisioningParameter{}),
+		Spec: buildProviderSpec(dbaasv1beta1.ProvisioningParameter{}, dbaasv1beta1.ProvisioningParameter{}, buildVersionParameter(versions)),
 	}
 	return instance
 }
 
+// buildVersionParameter builds the version provisioning parameter from the database versions offered by the provider
+func buildVersionParameter(versions []testutil.ClusterVersion) dbaasv1beta1.ProvisioningParameter {
+	if len(versions) == 0 {
+		return dbaasv1beta1.ProvisioningParameter{}
+	}
+	data := dbaasv1beta1.ConditionalProvisioningParameterData{}
+	for _, version := range versions {
+		data.Options = append(data.Options, dbaasv1beta1.Option{
+			Value:        version.Version,
+			DisplayValue: version.Version,
+		})
+		if version.Default {
+			data.DefaultValue = version.Version
+		}
+	}
+	return dbaasv1beta1.ProvisioningParameter{
+		DisplayName:     "Database version",
+		HelpText:        "Select the database version, changing the version of an instance upgrades it.",
+		ConditionalData: []dbaasv1beta1.ConditionalProvisioningParameterData{data},
+	}
+}
+
 // providerRegistrationCR CR for crunchy bridge registration
-func buildProviderSpec(regions, nodes dbaasv1beta1.ProvisioningParameter) dbaasv1beta1.DBaaSProviderSpec {
+func buildProviderSpec(regions, nodes, version dbaasv1beta1.ProvisioningParameter) dbaasv1beta1.DBaaSProviderSpec {
 
-	return dbaasv1beta1.DBaaSProviderSpec{
+	spec := dbaasv1beta1.DBaaSProviderSpec{
 		GroupVersion: "dbaas.redhat.com/v1beta1",
 		Provider: dbaasv1beta1.DatabaseProviderInfo{
 			Name:               "Provider Example",
@@ -678,4 +743,8 @@ func buildProviderSpec(regions, nodes dbaasv1beta1.ProvisioningParameter) dbaasv
 			},
 		},
 	}
+	if len(version.ConditionalData) > 0 {
+		spec.ProvisioningParameters[v1beta1.ProvisioningVersion] = version
+	}
+	return spec
 }
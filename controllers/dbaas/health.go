@@ -0,0 +1,220 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+)
+
+const (
+	// DefaultUnreachableTimeout is how long the provider backend can be unreachable before the operator is not ready
+	DefaultUnreachableTimeout = 5 * time.Minute
+	// backendProbeTimeout is the timeout of the provider backend call of the readiness check
+	backendProbeTimeout = 5 * time.Second
+)
+
+// ProviderHealth tracks the reachability of the provider backend and the last sync of every inventory,
+// for the readiness checks and the /debug/provider endpoint. The nil value tracks nothing.
+type ProviderHealth struct {
+	// CloudService is called by the readiness check, with the calls that do not depend on an account
+	CloudService testutil.Service
+	// RESTMapper finds the DBaaSProvider CRD of the registration
+	RESTMapper meta.RESTMapper
+	// UnreachableTimeout is how long the provider backend can be unreachable before the operator is not ready,
+	// DefaultUnreachableTimeout if it is zero
+	UnreachableTimeout time.Duration
+
+	mutex            sync.Mutex
+	lastReachable    time.Time
+	unreachableSince time.Time
+	lastBackendError string
+	inventories      map[types.NamespacedName]*InventorySyncStatus
+}
+
+// InventorySyncStatus is the last sync of an inventory with the provider backend.
+type InventorySyncStatus struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// LastSyncTime is the time of the last sync, successful or not
+	LastSyncTime time.Time `json:"lastSyncTime"`
+	// LastSuccessfulSyncTime is the time of the last successful sync
+	LastSuccessfulSyncTime *time.Time `json:"lastSuccessfulSyncTime,omitempty"`
+	// Reason is the reason of the Ready condition set by the last sync
+	Reason ConditionReason `json:"reason"`
+	// Error is the error of the last sync, if it failed
+	Error string `json:"error,omitempty"`
+	// DatabaseServices is the number of database services found by the last successful sync
+	DatabaseServices int `json:"databaseServices"`
+}
+
+// ProviderStatus is the body of the /debug/provider endpoint.
+type ProviderStatus struct {
+	// Reachable tells whether the provider backend is reachable, the last call answered
+	Reachable bool `json:"reachable"`
+	// LastReachableTime is the time of the last call answered by the provider backend
+	LastReachableTime *time.Time `json:"lastReachableTime,omitempty"`
+	// UnreachableSince is the time of the first failed call since the provider backend was last reachable
+	UnreachableSince *time.Time `json:"unreachableSince,omitempty"`
+	// LastError is the error of the last failed call
+	LastError   string                `json:"lastError,omitempty"`
+	Inventories []InventorySyncStatus `json:"inventories"`
+}
+
+// RecordBackendCall records the result of a provider backend call. A call is reachable if the backend answered it,
+// even with a client error; a call refused by the operator rate limit tells nothing.
+func (h *ProviderHealth) RecordBackendCall(resp *http.Response, err error) {
+	if h == nil {
+		return
+	}
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if err == nil || (resp != nil && resp.StatusCode < http.StatusInternalServerError) {
+		h.lastReachable = time.Now()
+		h.unreachableSince = time.Time{}
+		return
+	}
+	if h.unreachableSince.IsZero() {
+		h.unreachableSince = time.Now()
+	}
+	h.lastBackendError = err.Error()
+}
+
+// RecordInventorySync records the sync of an inventory, with the reason of its Ready condition.
+// The backend errors of the sync count as unreachable backend calls.
+func (h *ProviderHealth) RecordInventorySync(inventory types.NamespacedName, reason ConditionReason, databaseServices int, err error) {
+	if h == nil {
+		return
+	}
+	if reason == BackendError || err == nil {
+		h.RecordBackendCall(nil, err)
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.inventories == nil {
+		h.inventories = map[types.NamespacedName]*InventorySyncStatus{}
+	}
+	status, ok := h.inventories[inventory]
+	if !ok {
+		status = &InventorySyncStatus{Namespace: inventory.Namespace, Name: inventory.Name}
+		h.inventories[inventory] = status
+	}
+	now := time.Now()
+	status.LastSyncTime = now
+	status.Reason = reason
+	status.Error = ""
+	if err != nil {
+		status.Error = err.Error()
+		return
+	}
+	status.LastSuccessfulSyncTime = &now
+	status.DatabaseServices = databaseServices
+}
+
+// ForgetInventory stops listing a deleted inventory.
+func (h *ProviderHealth) ForgetInventory(inventory types.NamespacedName) {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.inventories, inventory)
+}
+
+// Status returns the reachability of the provider backend and the last sync of the inventories.
+func (h *ProviderHealth) Status() ProviderStatus {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	status := ProviderStatus{
+		Reachable:   h.unreachableSince.IsZero(),
+		LastError:   h.lastBackendError,
+		Inventories: []InventorySyncStatus{},
+	}
+	if !h.lastReachable.IsZero() {
+		lastReachable := h.lastReachable
+		status.LastReachableTime = &lastReachable
+	}
+	if !h.unreachableSince.IsZero() {
+		unreachableSince := h.unreachableSince
+		status.UnreachableSince = &unreachableSince
+	}
+	for _, inventory := range h.inventories {
+		status.Inventories = append(status.Inventories, *inventory)
+	}
+	sort.Slice(status.Inventories, func(i, j int) bool {
+		if status.Inventories[i].Namespace != status.Inventories[j].Namespace {
+			return status.Inventories[i].Namespace < status.Inventories[j].Namespace
+		}
+		return status.Inventories[i].Name < status.Inventories[j].Name
+	})
+	return status
+}
+
+// RegistrationCheck is a readiness check failing while the DBaaSProvider CRD of the registration is missing.
+func (h *ProviderHealth) RegistrationCheck(_ *http.Request) error {
+	gvk := dbaasv1beta1.GroupVersion.WithKind("DBaaSProvider")
+	if _, err := h.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		return fmt.Errorf("the %v CRD of the registration is missing: %w", gvk.Kind, err)
+	}
+	return nil
+}
+
+// BackendCheck is a readiness check failing while the provider backend has been unreachable for longer
+// than the unreachable timeout. The check calls the provider backend, without an account.
+func (h *ProviderHealth) BackendCheck(req *http.Request) error {
+	if h.CloudService != nil {
+		ctx, cancel := context.WithTimeout(req.Context(), backendProbeTimeout)
+		defer cancel()
+		_, resp, err := h.CloudService.ListVersions(ctx)
+		h.RecordBackendCall(resp, err)
+	}
+
+	timeout := h.UnreachableTimeout
+	if timeout == 0 {
+		timeout = DefaultUnreachableTimeout
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if !h.unreachableSince.IsZero() && time.Since(h.unreachableSince) > timeout {
+		return errors.New("the provider backend is unreachable since " + h.unreachableSince.Format(time.RFC3339) +
+			": " + h.lastBackendError)
+	}
+	return nil
+}
+
+// ServeHTTP serves the /debug/provider endpoint, the JSON of the provider status.
+func (h *ProviderHealth) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(h.Status())
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+)
+
+func TestProviderHealthBackendCheck(t *testing.T) {
+	g := NewWithT(t)
+
+	faults := testutil.NewFaultInjector()
+	health := &ProviderHealth{
+		CloudService:       testutil.NewFaultyService(testutil.NewFakeAPIClient(), faults),
+		UnreachableTimeout: time.Hour,
+	}
+	req := httptest.NewRequest(http.MethodGet, "/readyz/provider-backend", nil)
+	g.Expect(health.BackendCheck(req)).To(Succeed())
+	g.Expect(health.Status().Reachable).To(BeTrue())
+
+	// unreachable, but not for longer than the timeout
+	faults.SetFault("ListVersions", testutil.Fault{ErrorRate: 1, StatusCode: http.StatusServiceUnavailable})
+	g.Expect(health.BackendCheck(req)).To(Succeed())
+	g.Expect(health.Status().Reachable).To(BeFalse())
+
+	health.UnreachableTimeout = time.Nanosecond
+	g.Expect(health.BackendCheck(req)).To(MatchError(ContainSubstring("the provider backend is unreachable")))
+
+	// a client error is an answer of the backend
+	faults.SetFault("ListVersions", testutil.Fault{ErrorRate: 1, StatusCode: http.StatusUnauthorized})
+	g.Expect(health.BackendCheck(req)).To(Succeed())
+	g.Expect(health.Status().Reachable).To(BeTrue())
+}
+
+func TestProviderHealthRegistrationCheck(t *testing.T) {
+	g := NewWithT(t)
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	health := &ProviderHealth{RESTMapper: mapper}
+	g.Expect(health.RegistrationCheck(nil)).To(MatchError(ContainSubstring("DBaaSProvider CRD")))
+
+	mapper.Add(dbaasv1beta1.GroupVersion.WithKind("DBaaSProvider"), meta.RESTScopeRoot)
+	g.Expect(health.RegistrationCheck(nil)).To(Succeed())
+}
+
+func TestProviderHealthDebugEndpoint(t *testing.T) {
+	g := NewWithT(t)
+
+	health := &ProviderHealth{}
+	health.RecordInventorySync(types.NamespacedName{Namespace: "ns", Name: "synced"}, InventorySyncOK, 2, nil)
+	health.RecordInventorySync(types.NamespacedName{Namespace: "ns", Name: "invalid"}, InputError, 0, errors.New("no credentials"))
+	health.RecordInventorySync(types.NamespacedName{Namespace: "ns", Name: "deleted"}, InventorySyncOK, 0, nil)
+	health.ForgetInventory(types.NamespacedName{Namespace: "ns", Name: "deleted"})
+
+	recorder := httptest.NewRecorder()
+	health.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/provider", nil))
+	g.Expect(recorder.Code).To(Equal(http.StatusOK))
+	status := ProviderStatus{}
+	g.Expect(json.Unmarshal(recorder.Body.Bytes(), &status)).To(Succeed())
+
+	g.Expect(status.Reachable).To(BeTrue())
+	g.Expect(status.Inventories).To(HaveLen(2))
+	g.Expect(status.Inventories[0].Name).To(Equal("invalid"))
+	g.Expect(status.Inventories[0].Reason).To(Equal(InputError))
+	g.Expect(status.Inventories[0].Error).To(Equal("no credentials"))
+	g.Expect(status.Inventories[0].LastSuccessfulSyncTime).To(BeNil())
+	g.Expect(status.Inventories[1].Name).To(Equal("synced"))
+	g.Expect(status.Inventories[1].DatabaseServices).To(Equal(2))
+	g.Expect(status.Inventories[1].LastSuccessfulSyncTime).NotTo(BeNil())
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+)
+
+// secretLogKeys are the keys whose logged values are replaced, whatever the values are.
+var secretLogKeys = map[string]bool{
+	"password":         true,
+	"apikey":           true,
+	"api_key":          true,
+	"token":            true,
+	"secret":           true,
+	"credentialfield1": true,
+	"credentialfield2": true,
+	"connectionstring": true,
+}
+
+// NewRedactingLogger returns a logger that redacts the secrets of the messages, the errors and the values
+// logged with logger: the values of the secret keys, and the passwords, credentials and bearer tokens found in
+// the strings. The values implementing logr.Marshaler, like the provider API clients, log themselves.
+func NewRedactingLogger(logger logr.Logger) logr.Logger {
+	sink := logger.GetSink()
+	if sink == nil {
+		return logger
+	}
+	// the redacting sink is one more frame between the caller and the sink
+	if callDepthSink, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = callDepthSink.WithCallDepth(1)
+	}
+	return logr.New(&redactingSink{LogSink: sink})
+}
+
+type redactingSink struct {
+	logr.LogSink
+}
+
+// Init does nothing, the sink of the logger was initialized by the logger.
+func (s *redactingSink) Init(logr.RuntimeInfo) {
+}
+
+func (s *redactingSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.LogSink.Info(level, testutil.Redact(msg), redactValues(keysAndValues)...)
+}
+
+func (s *redactingSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.LogSink.Error(testutil.RedactError(err), testutil.Redact(msg), redactValues(keysAndValues)...)
+}
+
+func (s *redactingSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &redactingSink{LogSink: s.LogSink.WithValues(redactValues(keysAndValues)...)}
+}
+
+func (s *redactingSink) WithName(name string) logr.LogSink {
+	return &redactingSink{LogSink: s.LogSink.WithName(name)}
+}
+
+func (s *redactingSink) WithCallDepth(depth int) logr.LogSink {
+	if callDepthSink, ok := s.LogSink.(logr.CallDepthLogSink); ok {
+		return &redactingSink{LogSink: callDepthSink.WithCallDepth(depth)}
+	}
+	return s
+}
+
+// redactValues returns a copy of the keys and values with the secrets redacted.
+func redactValues(keysAndValues []interface{}) []interface{} {
+	redacted := make([]interface{}, len(keysAndValues))
+	for i, value := range keysAndValues {
+		if i%2 == 0 {
+			redacted[i] = value
+			continue
+		}
+		if key, ok := keysAndValues[i-1].(string); ok && secretLogKeys[strings.ToLower(key)] {
+			redacted[i] = testutil.Redacted
+			continue
+		}
+		redacted[i] = redactValue(value)
+	}
+	return redacted
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case logr.Marshaler:
+		return v
+	case string:
+		return testutil.Redact(v)
+	case []byte:
+		return testutil.Redact(string(v))
+	case error:
+		return testutil.RedactError(v)
+	case fmt.Stringer:
+		if reflected := reflect.ValueOf(v); reflected.Kind() == reflect.Ptr && reflected.IsNil() {
+			return value
+		}
+		return testutil.Redact(v.String())
+	}
+	return value
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+)
+
+func TestRedactingLoggerHidesSecrets(t *testing.T) {
+	g := NewWithT(t)
+	output := &bytes.Buffer{}
+	logger := NewRedactingLogger(zap.New(zap.WriteTo(output), zap.UseDevMode(false)))
+
+	server := httptest.NewServer(&echoingProvider{})
+	defer server.Close()
+	client := testutil.NewClient(server.URL, "s3cret-api-key")
+	_, _, apiErr := client.GetCluster(context.Background(), "cluster-id")
+	g.Expect(apiErr).To(HaveOccurred())
+
+	logger.Info("Created CloudClient for cloud", "cloudService", client)
+	logger.Info("Connecting to postgresql://user1:s3cret-password@db:5432/db")
+	logger.WithValues("password", "s3cret-password").Info("Created SQL user")
+	logger.WithName("connection").Info("Connection info", "CredentialField1", []byte("s3cret-credential"),
+		"connection", "host=db password=s3cret-password")
+	logger.Error(errors.New("authentication failed for token=s3cret-token"), "Failed to create CloudClient")
+	logger.Error(apiErr, "Failed to get the cluster", "error", apiErr)
+
+	g.Expect(output.String()).To(ContainSubstring("Created CloudClient for cloud"))
+	g.Expect(output.String()).To(ContainSubstring(server.URL))
+	g.Expect(output.String()).To(ContainSubstring(testutil.Redacted))
+	g.Expect(output.String()).NotTo(ContainSubstring("s3cret"))
+}
+
+// echoingProvider answers every request with an error echoing its credentials, like a verbose provider API.
+type echoingProvider struct{}
+
+func (p *echoingProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusUnauthorized)
+	_, _ = w.Write([]byte(`{"code": 16, "message": "invalid credentials: Authorization: ` + r.Header.Get("Authorization") + `"}`))
+}
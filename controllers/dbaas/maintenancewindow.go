@@ -0,0 +1,132 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// maintenanceWindow is a weekly time range during which disruptive operations are allowed.
+// A window whose end is not after its start ends on the next day.
+type maintenanceWindow struct {
+	days     map[time.Weekday]bool
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+}
+
+// getMaintenanceWindow returns the maintenance window of the instance, which defaults to the one of its
+// inventory, or nil if disruptive operations can run at any time.
+func getMaintenanceWindow(inventory *v1beta1.ProviderInventory, instance *v1beta1.ProviderInstance) (*maintenanceWindow, error) {
+	value, ok := instance.Annotations[MaintenanceWindowAnnotation]
+	if !ok {
+		value, ok = inventory.Annotations[MaintenanceWindowAnnotation]
+	}
+	if !ok {
+		return nil, nil
+	}
+	window, err := parseMaintenanceWindow(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v annotation: %w", MaintenanceWindowAnnotation, err)
+	}
+	return window, nil
+}
+
+// parseMaintenanceWindow parses a window in the "<days> <start>-<end> [<time zone>]" format,
+// for example "Sat,Sun 22:00-02:00 Europe/Berlin". The time zone defaults to UTC.
+func parseMaintenanceWindow(value string) (*maintenanceWindow, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 && len(fields) != 3 {
+		return nil, fmt.Errorf("%q is not in the \"<days> <start>-<end> [<time zone>]\" format", value)
+	}
+
+	window := &maintenanceWindow{days: map[time.Weekday]bool{}, location: time.UTC}
+	for _, day := range strings.Split(fields[0], ",") {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", day)
+		}
+		window.days[weekday] = true
+	}
+
+	timeRange := strings.Split(fields[1], "-")
+	if len(timeRange) != 2 {
+		return nil, fmt.Errorf("%q is not a <start>-<end> time range", fields[1])
+	}
+	var err error
+	if window.start, err = parseTimeOfDay(timeRange[0]); err != nil {
+		return nil, err
+	}
+	if window.end, err = parseTimeOfDay(timeRange[1]); err != nil {
+		return nil, err
+	}
+
+	if len(fields) == 3 {
+		if window.location, err = time.LoadLocation(fields[2]); err != nil {
+			return nil, err
+		}
+	}
+	return window, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// isOpen checks whether t is inside the window.
+func (w *maintenanceWindow) isOpen(t time.Time) bool {
+	t = t.In(w.location)
+	timeOfDay := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if w.start < w.end {
+		return w.days[t.Weekday()] && timeOfDay >= w.start && timeOfDay < w.end
+	}
+	return (w.days[t.Weekday()] && timeOfDay >= w.start) || (w.days[(t.Weekday()+6)%7] && timeOfDay < w.end)
+}
+
+// nextOpen returns t if the window is open at t, or the time the window opens next.
+func (w *maintenanceWindow) nextOpen(t time.Time) time.Time {
+	if w.isOpen(t) {
+		return t
+	}
+	local := t.In(w.location)
+	for i := 0; i <= 7; i++ {
+		day := local.AddDate(0, 0, i)
+		start := time.Date(day.Year(), day.Month(), day.Day(), int(w.start/time.Hour), int(w.start%time.Hour/time.Minute), 0, 0, w.location)
+		if w.days[start.Weekday()] && start.After(t) {
+			return start
+		}
+	}
+	return t
+}
@@ -0,0 +1,285 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	tests := []struct {
+		value        string
+		wantDays     []time.Weekday
+		wantStart    time.Duration
+		wantEnd      time.Duration
+		wantLocation string
+		wantErr      bool
+	}{
+		{
+			value:        "Sat,Sun 22:00-02:00 Europe/Berlin",
+			wantDays:     []time.Weekday{time.Saturday, time.Sunday},
+			wantStart:    22 * time.Hour,
+			wantEnd:      2 * time.Hour,
+			wantLocation: "Europe/Berlin",
+		},
+		{
+			value:        "mon 01:30-03:45",
+			wantDays:     []time.Weekday{time.Monday},
+			wantStart:    90 * time.Minute,
+			wantEnd:      225 * time.Minute,
+			wantLocation: "UTC",
+		},
+		{value: "", wantErr: true},
+		{value: "Sat", wantErr: true},
+		{value: "Sat 22:00-02:00 Europe/Berlin extra", wantErr: true},
+		{value: "Someday 22:00-02:00", wantErr: true},
+		{value: "Sat,,Sun 22:00-02:00", wantErr: true},
+		{value: "Sat 22:00", wantErr: true},
+		{value: "Sat 22:00-02:00-04:00", wantErr: true},
+		{value: "Sat 25:00-02:00", wantErr: true},
+		{value: "Sat 22:00-2pm", wantErr: true},
+		{value: "Sat 22:00-02:00 Mars/Olympus", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			g := NewWithT(t)
+			window, err := parseMaintenanceWindow(tc.value)
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(window.days).To(HaveLen(len(tc.wantDays)))
+			for _, day := range tc.wantDays {
+				g.Expect(window.days).To(HaveKeyWithValue(day, true))
+			}
+			g.Expect(window.start).To(Equal(tc.wantStart))
+			g.Expect(window.end).To(Equal(tc.wantEnd))
+			g.Expect(window.location.String()).To(Equal(tc.wantLocation))
+		})
+	}
+}
+
+func TestMaintenanceWindowIsOpen(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	tests := []struct {
+		name   string
+		window string
+		t      time.Time
+		want   bool
+	}{
+		{
+			name:   "inside",
+			window: "Wed 10:00-12:00",
+			t:      time.Date(2023, time.March, 15, 11, 0, 0, 0, time.UTC),
+			want:   true,
+		},
+		{
+			name:   "at the start",
+			window: "Wed 10:00-12:00",
+			t:      time.Date(2023, time.March, 15, 10, 0, 0, 0, time.UTC),
+			want:   true,
+		},
+		{
+			name:   "at the end",
+			window: "Wed 10:00-12:00",
+			t:      time.Date(2023, time.March, 15, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "other day",
+			window: "Wed 10:00-12:00",
+			t:      time.Date(2023, time.March, 16, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "crossing midnight before midnight",
+			window: "Sat 22:00-02:00",
+			t:      time.Date(2023, time.March, 18, 23, 0, 0, 0, time.UTC),
+			want:   true,
+		},
+		{
+			name:   "crossing midnight after midnight",
+			window: "Sat 22:00-02:00",
+			t:      time.Date(2023, time.March, 19, 1, 0, 0, 0, time.UTC),
+			want:   true,
+		},
+		{
+			name:   "crossing midnight after midnight of the previous week",
+			window: "Sat 22:00-02:00",
+			t:      time.Date(2023, time.March, 18, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "crossing midnight of Saturday into Sunday",
+			window: "Sat 23:00-01:00",
+			t:      time.Date(2023, time.March, 19, 0, 30, 0, 0, time.UTC),
+			want:   true,
+		},
+		{
+			name:   "crossing midnight of Sunday into Monday",
+			window: "Sun 23:00-01:00",
+			t:      time.Date(2023, time.March, 20, 0, 30, 0, 0, time.UTC),
+			want:   true,
+		},
+		{
+			name:   "whole day",
+			window: "Wed 00:00-00:00",
+			t:      time.Date(2023, time.March, 15, 23, 59, 0, 0, time.UTC),
+			want:   true,
+		},
+		{
+			name:   "time zone",
+			window: "Wed 10:00-12:00 Europe/Berlin",
+			t:      time.Date(2023, time.March, 15, 9, 30, 0, 0, time.UTC),
+			want:   true,
+		},
+		{
+			name:   "time zone outside",
+			window: "Wed 10:00-12:00 Europe/Berlin",
+			t:      time.Date(2023, time.March, 15, 11, 30, 0, 0, time.UTC),
+		},
+		{
+			name:   "time zone moving the day",
+			window: "Thu 00:00-01:00 Europe/Berlin",
+			t:      time.Date(2023, time.March, 15, 23, 30, 0, 0, time.UTC),
+			want:   true,
+		},
+		{
+			name:   "time zone of the checked time",
+			window: "Wed 10:00-12:00",
+			t:      time.Date(2023, time.March, 15, 12, 30, 0, 0, berlin),
+			want:   true,
+		},
+		{
+			name:   "daylight saving time",
+			window: "Mon 10:00-12:00 Europe/Berlin",
+			t:      time.Date(2023, time.July, 3, 8, 30, 0, 0, time.UTC),
+			want:   true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			window, err := parseMaintenanceWindow(tc.window)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(window.isOpen(tc.t)).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestMaintenanceWindowNextOpen(t *testing.T) {
+	tests := []struct {
+		name   string
+		window string
+		t      time.Time
+		want   time.Time
+	}{
+		{
+			name:   "open",
+			window: "Wed 10:00-12:00",
+			t:      time.Date(2023, time.March, 15, 11, 0, 0, 0, time.UTC),
+			want:   time.Date(2023, time.March, 15, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "later the same day",
+			window: "Wed 10:00-12:00",
+			t:      time.Date(2023, time.March, 15, 8, 0, 0, 0, time.UTC),
+			want:   time.Date(2023, time.March, 15, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "next week",
+			window: "Wed 10:00-12:00",
+			t:      time.Date(2023, time.March, 15, 13, 0, 0, 0, time.UTC),
+			want:   time.Date(2023, time.March, 22, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "next of several days",
+			window: "Mon,Fri 10:00-12:00",
+			t:      time.Date(2023, time.March, 15, 13, 0, 0, 0, time.UTC),
+			want:   time.Date(2023, time.March, 17, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "crossing midnight",
+			window: "Sat 22:00-02:00",
+			t:      time.Date(2023, time.March, 19, 3, 0, 0, 0, time.UTC),
+			want:   time.Date(2023, time.March, 25, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "time zone",
+			window: "Wed 10:00-12:00 Europe/Berlin",
+			t:      time.Date(2023, time.March, 15, 8, 0, 0, 0, time.UTC),
+			want:   time.Date(2023, time.March, 15, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "time zone moving the day",
+			window: "Thu 00:30-01:00 Europe/Berlin",
+			t:      time.Date(2023, time.March, 15, 20, 0, 0, 0, time.UTC),
+			want:   time.Date(2023, time.March, 15, 23, 30, 0, 0, time.UTC),
+		},
+		{
+			name:   "daylight saving time change",
+			window: "Sun 10:00-12:00 Europe/Berlin",
+			t:      time.Date(2023, time.March, 25, 12, 0, 0, 0, time.UTC),
+			want:   time.Date(2023, time.March, 26, 8, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			window, err := parseMaintenanceWindow(tc.window)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(window.nextOpen(tc.t).Equal(tc.want)).To(BeTrue(), "next open %v", window.nextOpen(tc.t))
+		})
+	}
+}
+
+func TestGetMaintenanceWindow(t *testing.T) {
+	g := NewWithT(t)
+	inventory := &v1beta1.ProviderInventory{}
+	instance := &v1beta1.ProviderInstance{}
+
+	window, err := getMaintenanceWindow(inventory, instance)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(window).To(BeNil())
+
+	inventory.ObjectMeta = metav1.ObjectMeta{Annotations: map[string]string{MaintenanceWindowAnnotation: "Sat 22:00-02:00"}}
+	window, err = getMaintenanceWindow(inventory, instance)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(window.days).To(HaveKey(time.Saturday))
+
+	instance.ObjectMeta = metav1.ObjectMeta{Annotations: map[string]string{MaintenanceWindowAnnotation: "Mon 01:00-02:00"}}
+	window, err = getMaintenanceWindow(inventory, instance)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(window.days).To(HaveKey(time.Monday))
+	g.Expect(window.days).NotTo(HaveKey(time.Saturday))
+
+	instance.Annotations[MaintenanceWindowAnnotation] = "whenever"
+	_, err = getMaintenanceWindow(inventory, instance)
+	g.Expect(err).To(MatchError(ContainSubstring(MaintenanceWindowAnnotation)))
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+)
+
+// errNamespaceNotAllowed is returned when a namespace is not allowed to reference an inventory.
+var errNamespaceNotAllowed = errors.New("namespace not allowed")
+
+// checkNamespaceAllowed returns an error wrapping errNamespaceNotAllowed if objects in the namespace are not
+// allowed to reference the inventory. The allowed namespaces are set on the inventory by a comma separated list,
+// where "*" allows all namespaces, and by a namespace label selector. The namespace of the inventory is always allowed, and an inventory
+// without either annotation allows all namespaces.
+func checkNamespaceAllowed(ctx context.Context, c client.Client, inventory *v1beta1.ProviderInventory, namespace string) error {
+	namespaces, hasNamespaces := inventory.Annotations[AllowedNamespacesAnnotation]
+	selector, hasSelector := inventory.Annotations[AllowedNamespaceSelectorAnnotation]
+	if namespace == inventory.Namespace || (!hasNamespaces && !hasSelector) {
+		return nil
+	}
+
+	if hasNamespaces {
+		for _, allowed := range strings.Split(namespaces, ",") {
+			allowed = strings.TrimSpace(allowed)
+			if allowed == "*" || allowed == namespace {
+				return nil
+			}
+		}
+	}
+
+	if hasSelector {
+		nsSelector, err := labels.Parse(selector)
+		if err != nil {
+			return fmt.Errorf("%w: invalid %v annotation on ProviderInventory %v/%v: %v", errNamespaceNotAllowed,
+				AllowedNamespaceSelectorAnnotation, inventory.Namespace, inventory.Name, err)
+		}
+		ns := corev1.Namespace{}
+		if err := c.Get(ctx, client.ObjectKey{Name: namespace}, &ns); err != nil {
+			return err
+		}
+		if nsSelector.Matches(labels.Set(ns.Labels)) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: namespace %v is not allowed to use ProviderInventory %v/%v", errNamespaceNotAllowed,
+		namespace, inventory.Namespace, inventory.Name)
+}
+
+// allowedNamespacesChanged filters the inventory events to the changes of the allowed namespaces annotations.
+func allowedNamespacesChanged() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return false
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldAnnotations, newAnnotations := e.ObjectOld.GetAnnotations(), e.ObjectNew.GetAnnotations()
+			return oldAnnotations[AllowedNamespacesAnnotation] != newAnnotations[AllowedNamespacesAnnotation] ||
+				oldAnnotations[AllowedNamespaceSelectorAnnotation] != newAnnotations[AllowedNamespaceSelectorAnnotation]
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+	}
+}
+
+// inventoryInstancesMapFn maps an inventory to the requests of the instances referencing it,
+// so that they are checked again when the namespaces allowed by the inventory change.
+func inventoryInstancesMapFn(c client.Client) handler.MapFunc {
+	return func(a client.Object) []ctrl.Request {
+		var instanceList v1beta1.ProviderInstanceList
+		if err := c.List(context.Background(), &instanceList); err != nil {
+			return nil
+		}
+		var requests []ctrl.Request
+		for _, instance := range instanceList.Items {
+			if instance.Spec.InventoryRef.Name == a.GetName() && instance.Spec.InventoryRef.Namespace == a.GetNamespace() {
+				requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}})
+			}
+		}
+		return requests
+	}
+}
+
+// inventoryConnectionsMapFn maps an inventory to the requests of the connections referencing it,
+// so that they are checked again when the namespaces allowed by the inventory change.
+func inventoryConnectionsMapFn(c client.Client) handler.MapFunc {
+	return func(a client.Object) []ctrl.Request {
+		var connectionList v1beta1.ProviderConnectionList
+		if err := c.List(context.Background(), &connectionList); err != nil {
+			return nil
+		}
+		var requests []ctrl.Request
+		for _, connection := range connectionList.Items {
+			if connection.Spec.InventoryRef.Name == a.GetName() && connection.Spec.InventoryRef.Namespace == a.GetNamespace() {
+				requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Name: connection.Name, Namespace: connection.Namespace}})
+			}
+		}
+		return requests
+	}
+}
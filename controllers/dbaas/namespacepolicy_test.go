@@ -0,0 +1,299 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+)
+
+func TestCheckNamespaceAllowed(t *testing.T) {
+	namespaces := []client.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"team": "a"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b", Labels: map[string]string{"team": "b"}}},
+	}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		namespace   string
+		wantErr     bool
+		wantAllowed bool
+	}{
+		{
+			name:        "no annotations",
+			namespace:   "team-a",
+			wantAllowed: true,
+		},
+		{
+			name:        "inventory namespace",
+			annotations: map[string]string{AllowedNamespacesAnnotation: "team-b"},
+			namespace:   "default",
+			wantAllowed: true,
+		},
+		{
+			name:        "listed namespace",
+			annotations: map[string]string{AllowedNamespacesAnnotation: "team-b, team-a"},
+			namespace:   "team-a",
+			wantAllowed: true,
+		},
+		{
+			name:        "unlisted namespace",
+			annotations: map[string]string{AllowedNamespacesAnnotation: "team-b"},
+			namespace:   "team-a",
+		},
+		{
+			name:        "empty list",
+			annotations: map[string]string{AllowedNamespacesAnnotation: ""},
+			namespace:   "team-a",
+		},
+		{
+			name:        "all namespaces",
+			annotations: map[string]string{AllowedNamespacesAnnotation: "*"},
+			namespace:   "team-a",
+			wantAllowed: true,
+		},
+		{
+			name:        "selected namespace",
+			annotations: map[string]string{AllowedNamespaceSelectorAnnotation: "team in (a)"},
+			namespace:   "team-a",
+			wantAllowed: true,
+		},
+		{
+			name:        "unselected namespace",
+			annotations: map[string]string{AllowedNamespaceSelectorAnnotation: "team=a"},
+			namespace:   "team-b",
+		},
+		{
+			name:        "listed or selected namespace",
+			annotations: map[string]string{AllowedNamespacesAnnotation: "team-b", AllowedNamespaceSelectorAnnotation: "team=a"},
+			namespace:   "team-b",
+			wantAllowed: true,
+		},
+		{
+			name:        "invalid selector",
+			annotations: map[string]string{AllowedNamespaceSelectorAnnotation: "team=="},
+			namespace:   "team-a",
+		},
+		{
+			name:        "missing namespace",
+			annotations: map[string]string{AllowedNamespaceSelectorAnnotation: "team=a"},
+			namespace:   "missing",
+			wantErr:     true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(namespaces...).Build()
+			inventory := &v1beta1.ProviderInventory{
+				ObjectMeta: metav1.ObjectMeta{Name: "inventory", Namespace: "default", Annotations: tc.annotations},
+			}
+			err := checkNamespaceAllowed(context.Background(), c, inventory, tc.namespace)
+			switch {
+			case tc.wantAllowed:
+				g.Expect(err).NotTo(HaveOccurred())
+			case tc.wantErr:
+				g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			default:
+				g.Expect(errors.Is(err, errNamespaceNotAllowed)).To(BeTrue(), "unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestAllowedNamespacesChanged(t *testing.T) {
+	g := NewWithT(t)
+	inventory := &v1beta1.ProviderInventory{ObjectMeta: metav1.ObjectMeta{Name: "inventory", Namespace: "default"}}
+	changed := func(annotations map[string]string) bool {
+		updated := inventory.DeepCopy()
+		updated.Annotations = annotations
+		return allowedNamespacesChanged().Update(event.UpdateEvent{ObjectOld: inventory, ObjectNew: updated})
+	}
+
+	g.Expect(changed(nil)).To(BeFalse())
+	g.Expect(changed(map[string]string{MaintenanceWindowAnnotation: "Sat 22:00-02:00"})).To(BeFalse())
+	g.Expect(changed(map[string]string{AllowedNamespacesAnnotation: "team-a"})).To(BeTrue())
+	g.Expect(changed(map[string]string{AllowedNamespaceSelectorAnnotation: "team=a"})).To(BeTrue())
+	g.Expect(allowedNamespacesChanged().Create(event.CreateEvent{Object: inventory})).To(BeFalse())
+}
+
+func TestInventoryMapFns(t *testing.T) {
+	g := NewWithT(t)
+	inventoryRef := dbaasv1beta1.NamespacedName{Name: "inventory", Namespace: "default"}
+	otherRef := dbaasv1beta1.NamespacedName{Name: "other", Namespace: "default"}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(
+		&v1beta1.ProviderInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "instance", Namespace: "team-a"},
+			Spec:       v1beta1.ProviderInstanceSpec{DBaaSInstanceSpec: dbaasv1beta1.DBaaSInstanceSpec{InventoryRef: inventoryRef}},
+		},
+		&v1beta1.ProviderInstance{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-instance", Namespace: "team-a"},
+			Spec:       v1beta1.ProviderInstanceSpec{DBaaSInstanceSpec: dbaasv1beta1.DBaaSInstanceSpec{InventoryRef: otherRef}},
+		},
+		&v1beta1.ProviderConnection{
+			ObjectMeta: metav1.ObjectMeta{Name: "connection", Namespace: "team-b"},
+			Spec:       dbaasv1beta1.DBaaSConnectionSpec{InventoryRef: inventoryRef},
+		},
+		&v1beta1.ProviderConnection{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-connection", Namespace: "team-b"},
+			Spec:       dbaasv1beta1.DBaaSConnectionSpec{InventoryRef: otherRef},
+		},
+	).Build()
+	inventory := &v1beta1.ProviderInventory{ObjectMeta: metav1.ObjectMeta{Name: "inventory", Namespace: "default"}}
+
+	g.Expect(inventoryInstancesMapFn(c)(inventory)).To(ConsistOf(
+		ctrl.Request{NamespacedName: types.NamespacedName{Name: "instance", Namespace: "team-a"}}))
+	g.Expect(inventoryConnectionsMapFn(c)(inventory)).To(ConsistOf(
+		ctrl.Request{NamespacedName: types.NamespacedName{Name: "connection", Namespace: "team-b"}}))
+}
+
+// newRejectingInventory returns an inventory of the default namespace that only allows the namespace "allowed".
+func newRejectingInventory(t *testing.T) (*corev1.Secret, *v1beta1.ProviderInventory) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"},
+		Data: map[string][]byte{
+			"CredentialField1": []byte(t.Name()),
+			"CredentialField2": []byte("field2"),
+		},
+	}
+	inventory := &v1beta1.ProviderInventory{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "inventory",
+			Namespace:   "default",
+			Annotations: map[string]string{AllowedNamespacesAnnotation: "allowed"},
+		},
+		Spec: dbaasv1beta1.DBaaSInventorySpec{
+			CredentialsRef: &dbaasv1beta1.LocalObjectReference{Name: secret.Name},
+		},
+		Status: dbaasv1beta1.DBaaSInventoryStatus{
+			Conditions: []metav1.Condition{{
+				Type:               inventoryConditionTypeReady,
+				Status:             metav1.ConditionTrue,
+				Reason:             string(InventorySyncOK),
+				LastTransitionTime: metav1.Now(),
+			}},
+			DatabaseServices: []dbaasv1beta1.DatabaseService{{ServiceID: "a-cluster-instance-1-id"}},
+		},
+	}
+	return secret, inventory
+}
+
+// TestProviderConnectionRejectedNamespace checks that the secret and the config map of a connection
+// are deleted once its namespace is no longer allowed to use the inventory.
+func TestProviderConnectionRejectedNamespace(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	secret, inventory := newRejectingInventory(t)
+	connection := &v1beta1.ProviderConnection{
+		ObjectMeta: metav1.ObjectMeta{Name: "connection", Namespace: "rejected"},
+		Spec: dbaasv1beta1.DBaaSConnectionSpec{
+			InventoryRef:      dbaasv1beta1.NamespacedName{Name: inventory.Name, Namespace: inventory.Namespace},
+			DatabaseServiceID: "a-cluster-instance-1-id",
+		},
+		Status: v1beta1.ProviderConnectionStatus{DBaaSConnectionStatus: dbaasv1beta1.DBaaSConnectionStatus{
+			CredentialsRef:    &corev1.LocalObjectReference{Name: userSecretName(&v1beta1.ProviderConnection{ObjectMeta: metav1.ObjectMeta{Name: "connection"}})},
+			ConnectionInfoRef: &corev1.LocalObjectReference{Name: configMapName(&v1beta1.ProviderConnection{ObjectMeta: metav1.ObjectMeta{Name: "connection"}})},
+		}},
+	}
+	userSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: connection.Status.CredentialsRef.Name, Namespace: "rejected"}}
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: connection.Status.ConnectionInfoRef.Name, Namespace: "rejected"}}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(secret, inventory, connection, userSecret, configMap).Build()
+	r := &ProviderConnectionReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(connection)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{}))
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(connection), connection)).To(Succeed())
+	g.Expect(apimeta.FindStatusCondition(connection.Status.Conditions, connectionConditionReadyType).Reason).To(Equal(string(NamespaceNotAllowed)))
+	g.Expect(connection.Status.CredentialsRef).To(BeNil())
+	g.Expect(connection.Status.ConnectionInfoRef).To(BeNil())
+	g.Expect(apierrors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(userSecret), userSecret))).To(BeTrue())
+	g.Expect(apierrors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(configMap), configMap))).To(BeTrue())
+}
+
+// TestBackupsRejectedNamespace checks that backups, restores and schedules are not run for an
+// instance whose namespace is not allowed to use the inventory.
+func TestBackupsRejectedNamespace(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	secret, inventory := newRejectingInventory(t)
+	instance := &v1beta1.ProviderInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: "instance", Namespace: "rejected"},
+		Spec: v1beta1.ProviderInstanceSpec{DBaaSInstanceSpec: dbaasv1beta1.DBaaSInstanceSpec{
+			InventoryRef: dbaasv1beta1.NamespacedName{Name: inventory.Name, Namespace: inventory.Namespace},
+		}},
+		Status: dbaasv1beta1.DBaaSInstanceStatus{InstanceID: "a-cluster-instance-1-id", Phase: dbaasv1beta1.InstancePhaseReady},
+	}
+	backup := newBackup(dbaasv1beta1.NamespacedName{Name: instance.Name})
+	backup.Namespace = "rejected"
+	completed := &v1beta1.ProviderBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "completed", Namespace: "rejected"},
+		Spec:       v1beta1.ProviderBackupSpec{InstanceRef: dbaasv1beta1.NamespacedName{Name: instance.Name}},
+		Status:     v1beta1.ProviderBackupStatus{Phase: v1beta1.BackupPhaseCompleted, BackupID: "backup-id", InstanceID: instance.Status.InstanceID},
+	}
+	restore := newRestore("restored")
+	restore.Namespace = "rejected"
+	restore.Spec.BackupRef = dbaasv1beta1.NamespacedName{Name: completed.Name}
+	schedule := &v1beta1.ProviderBackupSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "schedule", Namespace: "rejected", CreationTimestamp: metav1.Unix(0, 0)},
+		Spec: v1beta1.ProviderBackupScheduleSpec{
+			InstanceRef: dbaasv1beta1.NamespacedName{Name: instance.Name},
+			Schedule:    "0 * * * *",
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).
+		WithObjects(secret, inventory, instance, backup, completed, restore, schedule).Build()
+	service := &testutil.FakeProviderService{Client: c}
+
+	backupReconciler := &ProviderBackupReconciler{DBaaSProviderService: service, Scheme: c.Scheme()}
+	result := reconcileBackup(g, c, backupReconciler, backup)
+	g.Expect(result).To(Equal(ctrl.Result{}))
+	g.Expect(backup.Status.Phase).To(Equal(v1beta1.BackupPhaseFailed))
+	g.Expect(backup.Status.BackupID).To(BeEmpty())
+	g.Expect(backupReason(backup)).To(Equal(string(NamespaceNotAllowed)))
+
+	restoreReconciler := &ProviderRestoreReconciler{DBaaSProviderService: service, Scheme: c.Scheme()}
+	result = reconcileRestore(g, c, restoreReconciler, restore)
+	g.Expect(result).To(Equal(ctrl.Result{}))
+	g.Expect(restore.Status.Phase).To(Equal(v1beta1.RestorePhaseFailed))
+	g.Expect(restore.Status.InstanceID).To(BeEmpty())
+	g.Expect(restoreReason(restore)).To(Equal(string(NamespaceNotAllowed)))
+
+	scheduleReconciler := &ProviderBackupScheduleReconciler{DBaaSProviderService: service, Scheme: c.Scheme()}
+	result, err := scheduleReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(schedule)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(schedule), schedule)).To(Succeed())
+	g.Expect(schedule.Status.LastBackupName).To(BeEmpty())
+	g.Expect(schedule.Status.Conditions[0].Reason).To(Equal(string(NamespaceNotAllowed)))
+}
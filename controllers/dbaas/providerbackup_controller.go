@@ -0,0 +1,352 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ProviderBackupReconciler reconciles a ProviderBackup object
+type ProviderBackupReconciler struct {
+	testutil.DBaaSProviderService
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerbackups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerbackups/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerbackups/finalizers,verbs=update
+
+// Reconcile takes a backup of the referenced ProviderInstance at the provider cloud,
+// reports its progress in the status, and deletes it from the provider cloud when the
+// ProviderBackup is deleted.
+func (r *ProviderBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx, "ProviderBackup", req.NamespacedName)
+
+	var backup v1beta1.ProviderBackup
+	if err := r.Get(ctx, req.NamespacedName, &backup); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("ProviderBackup resource not found, may have been deleted")
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to fetch ProviderBackup for reconcile")
+		return ctrl.Result{}, err
+	}
+	original := backup.DeepCopy()
+
+	if isReconcilePaused(&backup) {
+		logger.Info("ProviderBackup reconciliation is paused")
+		return ctrl.Result{}, nil
+	}
+
+	instanceKey, refErr := localObjectKey(backup.Spec.InstanceRef, backup.Namespace)
+	if !backup.DeletionTimestamp.IsZero() {
+		return r.deleteBackup(ctx, original, &backup, instanceKey, refErr, logger)
+	}
+
+	if !controllerutil.ContainsFinalizer(&backup, backupFinalizer) {
+		controllerutil.AddFinalizer(&backup, backupFinalizer)
+		if err := r.Update(ctx, &backup); err != nil {
+			logger.Error(err, "Failed to add finalizer to ProviderBackup")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if backup.Status.Phase == v1beta1.BackupPhaseCompleted || backup.Status.Phase == v1beta1.BackupPhaseFailed {
+		return ctrl.Result{}, nil
+	}
+
+	if refErr != nil {
+		backup.Status.Phase = v1beta1.BackupPhaseFailed
+		statusErr := r.updateStatus(ctx, original, &backup, metav1.ConditionFalse, InputError, refErr.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating backup status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Error(refErr, "Invalid ProviderInstance reference")
+		return ctrl.Result{}, nil
+	}
+
+	instance, cloudService, err := getInstanceCloudService(ctx, r.DBaaSProviderService, instanceKey)
+	if errors.Is(err, errNamespaceNotAllowed) {
+		backup.Status.Phase = v1beta1.BackupPhaseFailed
+		statusErr := r.updateStatus(ctx, original, &backup, metav1.ConditionFalse, NamespaceNotAllowed, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating backup status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Info("ProviderBackup namespace is not allowed to use the ProviderInventory of the instance", "reason", err.Error())
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		reason := BackendError
+		if apierrors.IsNotFound(err) {
+			reason = InstanceNotFound
+		}
+		backup.Status.Phase = v1beta1.BackupPhasePending
+		statusErr := r.updateStatus(ctx, original, &backup, metav1.ConditionFalse, reason, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating backup status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Error(err, "Failed to create CloudClient for the instance")
+		return ctrl.Result{}, err
+	}
+
+	if len(backup.Status.BackupID) == 0 {
+		if instance.Status.Phase != dbaasv1beta1.InstancePhaseReady || len(instance.Status.InstanceID) == 0 {
+			backup.Status.Phase = v1beta1.BackupPhasePending
+			statusErr := r.updateStatus(ctx, original, &backup, metav1.ConditionFalse, InstanceNotReady, "ProviderInstance is not yet ready")
+			if statusErr != nil {
+				logger.Error(statusErr, "Error in updating backup status")
+				return ctrl.Result{Requeue: true}, statusErr
+			}
+			logger.Info("instance is not ready, waiting before taking the backup")
+			return ctrl.Result{RequeueAfter: DefaultRetryDelay}, nil
+		}
+
+		var providerBackup *testutil.Backup
+		if backup.Status.Phase == v1beta1.BackupPhaseInProgress && backup.Status.StartTime != nil {
+			// the backup was requested, but its ID may not have been written to the status
+			providerBackup, err = r.findRequestedBackup(ctx, cloudService, &backup, instance.Status.InstanceID)
+			if err != nil {
+				statusErr := r.updateStatus(ctx, original, &backup, metav1.ConditionFalse, BackendError, err.Error())
+				if statusErr != nil {
+					logger.Error(statusErr, "Error in updating backup status")
+					return ctrl.Result{Requeue: true}, statusErr
+				}
+				logger.Error(err, "Failed to list the backups at provider cloud")
+				return ctrl.Result{}, err
+			}
+		}
+		if providerBackup == nil {
+			// the request is recorded before the backup is created, so that a backup whose ID
+			// was not written to the status is found again instead of being created twice
+			now := metav1.Now()
+			backup.Status.Phase = v1beta1.BackupPhaseInProgress
+			backup.Status.StartTime = &now
+			if statusErr := r.updateStatus(ctx, original, &backup, metav1.ConditionFalse, BackupInProgress, "Backup is requested"); statusErr != nil {
+				logger.Error(statusErr, "Error in updating backup status")
+				return ctrl.Result{Requeue: true}, statusErr
+			}
+			logger.Info("Creating backup at provider cloud")
+			providerBackup, err = r.CreateBackup(ctx, cloudService, instance.Status.InstanceID)
+		}
+		if err != nil {
+			statusErr := r.updateStatus(ctx, original, &backup, metav1.ConditionFalse, BackendError, err.Error())
+			if statusErr != nil {
+				logger.Error(statusErr, "Error in updating backup status")
+				return ctrl.Result{Requeue: true}, statusErr
+			}
+			logger.Error(err, "Failed to create a backup at provider cloud")
+			return ctrl.Result{}, err
+		}
+		backup.Status.BackupID = providerBackup.Id
+		backup.Status.InstanceID = instance.Status.InstanceID
+	}
+
+	providerBackup, err := r.GetBackup(ctx, cloudService, backup.Status.InstanceID, backup.Status.BackupID)
+	if err != nil {
+		statusErr := r.updateStatus(ctx, original, &backup, metav1.ConditionFalse, BackendError, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating backup status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Error(err, "Failed to get a backup at provider cloud")
+		return ctrl.Result{}, err
+	}
+	setBackupDetails(providerBackup, &backup.Status)
+
+	switch backup.Status.Phase {
+	case v1beta1.BackupPhaseCompleted:
+		logger.Info("backup completed")
+		if statusErr := r.updateStatus(ctx, original, &backup, metav1.ConditionTrue, BackupCompleted, "Backup is available for restore"); statusErr != nil {
+			logger.Error(statusErr, "Error in updating backup status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		return ctrl.Result{}, nil
+	case v1beta1.BackupPhaseFailed:
+		logger.Info("backup failed at provider cloud")
+		if statusErr := r.updateStatus(ctx, original, &backup, metav1.ConditionFalse, BackupFailed, "Provider failed to take the backup"); statusErr != nil {
+			logger.Error(statusErr, "Error in updating backup status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		return ctrl.Result{}, nil
+	default:
+		msg := fmt.Sprintf("Backup is %d%% complete", backup.Status.Progress)
+		if statusErr := r.updateStatus(ctx, original, &backup, metav1.ConditionFalse, BackupInProgress, msg); statusErr != nil {
+			logger.Error(statusErr, "Error in updating backup status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		return ctrl.Result{RequeueAfter: DefaultRetryDelay}, nil
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ProviderBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1beta1.ProviderBackup{}).
+		Complete(traceReconciles("ProviderBackup", r))
+}
+
+func (r *ProviderBackupReconciler) deleteBackup(ctx context.Context, original, backup *v1beta1.ProviderBackup,
+	instanceKey client.ObjectKey, refErr error, logger logr.Logger) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(backup, backupFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	if refErr != nil {
+		// the backup of an instance of another namespace was never taken
+		logger.Info("invalid ProviderInstance reference, skipping the backup deletion at provider cloud", "reason", refErr.Error())
+	} else if len(backup.Status.BackupID) > 0 {
+		backup.Status.Phase = v1beta1.BackupPhaseDeleting
+		_, cloudService, err := getInstanceCloudService(ctx, r.DBaaSProviderService, instanceKey)
+		if err != nil {
+			if !apierrors.IsNotFound(err) && !errors.Is(err, errNamespaceNotAllowed) {
+				logger.Error(err, "Failed to create CloudClient for the instance")
+				return ctrl.Result{}, err
+			}
+			logger.Info("instance or inventory not found or not allowed, skipping the backup deletion at provider cloud", "reason", err.Error())
+		} else {
+			logger.Info("Deleting backup at provider cloud")
+			if err := r.DeleteBackup(ctx, cloudService, backup.Status.InstanceID, backup.Status.BackupID); err != nil {
+				statusErr := r.updateStatus(ctx, original, backup, metav1.ConditionFalse, BackendError, err.Error())
+				if statusErr != nil {
+					logger.Error(statusErr, "Error in updating backup status")
+				}
+				logger.Error(err, "Failed to delete a backup at provider cloud")
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	controllerutil.RemoveFinalizer(backup, backupFinalizer)
+	if err := r.Update(ctx, backup); err != nil {
+		logger.Error(err, "Failed to remove finalizer from ProviderBackup")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *ProviderBackupReconciler) updateStatus(ctx context.Context, original, backup *v1beta1.ProviderBackup,
+	status metav1.ConditionStatus, reason ConditionReason, msg string) error {
+	setStatusCondition(&backup.Status.Conditions, backup, backupConditionReadyType, status, reason, msg)
+	return patchStatus(ctx, r.DBaaSProviderService, original, backup)
+}
+
+// findRequestedBackup returns the oldest backup of the cluster created since the backup was requested that
+// no other ProviderBackup of the namespace refers to, or nil if there is none.
+func (r *ProviderBackupReconciler) findRequestedBackup(ctx context.Context, cloudService testutil.Service,
+	backup *v1beta1.ProviderBackup, clusterID string) (*testutil.Backup, error) {
+	providerBackups, err := r.ListBackups(ctx, cloudService, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	var backupList v1beta1.ProviderBackupList
+	if err := r.List(ctx, &backupList, client.InNamespace(backup.Namespace)); err != nil {
+		return nil, err
+	}
+	claimed := map[string]bool{}
+	for _, other := range backupList.Items {
+		if other.Name != backup.Name {
+			claimed[other.Status.BackupID] = true
+		}
+	}
+
+	var found *testutil.Backup
+	for i := range providerBackups {
+		candidate := &providerBackups[i]
+		if claimed[candidate.Id] || candidate.CreatedAt == nil || candidate.CreatedAt.Before(backup.Status.StartTime.Time) {
+			continue
+		}
+		if found == nil || candidate.CreatedAt.Before(*found.CreatedAt) {
+			found = candidate
+		}
+	}
+	return found, nil
+}
+
+func setBackupDetails(providerBackup *testutil.Backup, backupStatus *v1beta1.ProviderBackupStatus) {
+	backupStatus.Progress = providerBackup.Progress
+	backupStatus.SizeBytes = providerBackup.SizeBytes
+	if providerBackup.CreatedAt != nil {
+		backupStatus.StartTime = &metav1.Time{Time: *providerBackup.CreatedAt}
+	}
+	if providerBackup.CompletedAt != nil {
+		backupStatus.CompletionTime = &metav1.Time{Time: *providerBackup.CompletedAt}
+	}
+	switch providerBackup.State {
+	case testutil.BACKUPSTATETYPE_COMPLETED:
+		backupStatus.Phase = v1beta1.BackupPhaseCompleted
+	case testutil.BACKUPSTATETYPE_FAILED:
+		backupStatus.Phase = v1beta1.BackupPhaseFailed
+	default:
+		backupStatus.Phase = v1beta1.BackupPhaseInProgress
+	}
+}
+
+// getInstanceCloudService fetches the ProviderInstance and creates the API client
+// from the credentials of its ProviderInventory. An error wrapping errNamespaceNotAllowed
+// is returned if the namespace of the instance is not allowed to use the inventory.
+func getInstanceCloudService(ctx context.Context, providerService testutil.DBaaSProviderService,
+	instanceKey client.ObjectKey) (*v1beta1.ProviderInstance, testutil.Service, error) {
+	instance, inventory, err := getInstanceInventory(ctx, providerService, instanceKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secretSelector := client.ObjectKey{
+		Namespace: inventory.Namespace,
+		Name:      inventory.Spec.CredentialsRef.Name,
+	}
+	cloudService, err := providerService.CreateCloudService(ctx, secretSelector)
+	if err != nil {
+		return nil, nil, err
+	}
+	return instance, cloudService, nil
+}
+
+// getInstanceInventory fetches the ProviderInstance and its ProviderInventory, and checks that the
+// namespace of the instance is allowed to use the inventory.
+func getInstanceInventory(ctx context.Context, c client.Client,
+	instanceKey client.ObjectKey) (*v1beta1.ProviderInstance, *v1beta1.ProviderInventory, error) {
+	instance := &v1beta1.ProviderInstance{}
+	if err := c.Get(ctx, instanceKey, instance); err != nil {
+		return nil, nil, err
+	}
+
+	inventory := &v1beta1.ProviderInventory{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: instance.Spec.InventoryRef.Namespace, Name: instance.Spec.InventoryRef.Name}, inventory); err != nil {
+		return nil, nil, err
+	}
+	if err := checkNamespaceAllowed(ctx, c, inventory, instance.Namespace); err != nil {
+		return nil, nil, err
+	}
+	return instance, inventory, nil
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+)
+
+// newReadyInstance returns the objects of a ProviderInstance whose cluster is created at the fake provider,
+// in the store of the tenant of the test.
+func newReadyInstance(g *WithT, t *testing.T) (*testutil.FakeAPIClient, *testutil.Cluster, []client.Object) {
+	cloudService := testutil.NewFakeAPIClientForTenant(t.Name())
+	cluster, _, err := cloudService.CreateCluster(context.Background(), &testutil.CreateClusterRequest{Name: "backed-up", Provider: testutil.APICLOUDPROVIDER_AWS})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"},
+		Data: map[string][]byte{
+			"CredentialField1": []byte(t.Name()),
+			"CredentialField2": []byte("field2"),
+		},
+	}
+	inventory := &v1beta1.ProviderInventory{
+		ObjectMeta: metav1.ObjectMeta{Name: "inventory", Namespace: "default"},
+		Spec: dbaasv1beta1.DBaaSInventorySpec{
+			CredentialsRef: &dbaasv1beta1.LocalObjectReference{Name: secret.Name},
+		},
+	}
+	instance := &v1beta1.ProviderInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: "instance", Namespace: "default"},
+		Spec: v1beta1.ProviderInstanceSpec{DBaaSInstanceSpec: dbaasv1beta1.DBaaSInstanceSpec{
+			InventoryRef: dbaasv1beta1.NamespacedName{Name: inventory.Name, Namespace: inventory.Namespace},
+		}},
+		Status: dbaasv1beta1.DBaaSInstanceStatus{
+			InstanceID: cluster.Id,
+			Phase:      dbaasv1beta1.InstancePhaseReady,
+		},
+	}
+	return cloudService, cluster, []client.Object{secret, inventory, instance}
+}
+
+// failedBackupService reports the backups of the fake provider as failed.
+type failedBackupService struct {
+	*testutil.FakeProviderService
+}
+
+func (s failedBackupService) GetBackup(ctx context.Context, cloudService testutil.Service, clusterID, backupID string) (*testutil.Backup, error) {
+	backup, err := s.FakeProviderService.GetBackup(ctx, cloudService, clusterID, backupID)
+	if err == nil {
+		backup.State = testutil.BACKUPSTATETYPE_FAILED
+	}
+	return backup, err
+}
+
+func newBackup(instanceRef dbaasv1beta1.NamespacedName) *v1beta1.ProviderBackup {
+	return &v1beta1.ProviderBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "default"},
+		Spec:       v1beta1.ProviderBackupSpec{InstanceRef: instanceRef},
+	}
+}
+
+func reconcileBackup(g *WithT, c client.Client, r *ProviderBackupReconciler, backup *v1beta1.ProviderBackup) ctrl.Result {
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(backup)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(backup), backup)).To(Succeed())
+	return result
+}
+
+func backupReason(backup *v1beta1.ProviderBackup) string {
+	condition := apimeta.FindStatusCondition(backup.Status.Conditions, backupConditionReadyType)
+	if condition == nil {
+		return ""
+	}
+	return condition.Reason
+}
+
+func TestProviderBackupLifecycle(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	cloudService, cluster, objects := newReadyInstance(g, t)
+	instance := objects[2].(*v1beta1.ProviderInstance)
+	instance.Status.Phase = dbaasv1beta1.InstancePhaseCreating
+	backup := newBackup(dbaasv1beta1.NamespacedName{Name: instance.Name})
+
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(append(objects, backup)...).Build()
+	r := &ProviderBackupReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+
+	// the backup waits for the instance
+	result := reconcileBackup(g, c, r, backup)
+	g.Expect(backup.Status.Phase).To(Equal(v1beta1.BackupPhasePending))
+	g.Expect(backupReason(backup)).To(Equal(string(InstanceNotReady)))
+	g.Expect(result.RequeueAfter).To(Equal(DefaultRetryDelay))
+
+	instance.Status.Phase = dbaasv1beta1.InstancePhaseReady
+	g.Expect(c.Status().Update(ctx, instance)).To(Succeed())
+	reconcileBackup(g, c, r, backup)
+	g.Expect(backup.Finalizers).To(ContainElement(backupFinalizer))
+	g.Expect(backup.Status.Phase).To(Equal(v1beta1.BackupPhaseInProgress))
+	g.Expect(backup.Status.BackupID).NotTo(BeEmpty())
+	g.Expect(backup.Status.Progress).To(Equal(int32(50)))
+	g.Expect(backupReason(backup)).To(Equal(string(BackupInProgress)))
+
+	reconcileBackup(g, c, r, backup)
+	g.Expect(backup.Status.Phase).To(Equal(v1beta1.BackupPhaseCompleted))
+	g.Expect(backup.Status.CompletionTime).NotTo(BeNil())
+	g.Expect(backupReason(backup)).To(Equal(string(BackupCompleted)))
+
+	// the deleted backup is deleted at the provider before it is released
+	g.Expect(c.Delete(ctx, backup)).To(Succeed())
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(backup)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apierrors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(backup), backup))).To(BeTrue())
+	backups, _, err := cloudService.ListBackups(ctx, cluster.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(backups.Backups).To(BeEmpty())
+}
+
+func TestProviderBackupFailed(t *testing.T) {
+	g := NewWithT(t)
+	_, _, objects := newReadyInstance(g, t)
+	backup := newBackup(dbaasv1beta1.NamespacedName{Name: "instance"})
+
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(append(objects, backup)...).Build()
+	r := &ProviderBackupReconciler{
+		DBaaSProviderService: failedBackupService{&testutil.FakeProviderService{Client: c}},
+		Scheme:               c.Scheme(),
+	}
+
+	result := reconcileBackup(g, c, r, backup)
+	g.Expect(backup.Status.Phase).To(Equal(v1beta1.BackupPhaseFailed))
+	g.Expect(backupReason(backup)).To(Equal(string(BackupFailed)))
+	g.Expect(result).To(Equal(ctrl.Result{}))
+}
+
+func TestProviderBackupRejectsOtherNamespaces(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	cloudService, cluster, objects := newReadyInstance(g, t)
+	backup := newBackup(dbaasv1beta1.NamespacedName{Name: "instance", Namespace: "default"})
+	backup.Namespace = "other"
+
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(append(objects, backup)...).Build()
+	r := &ProviderBackupReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+
+	reconcileBackup(g, c, r, backup)
+	g.Expect(backup.Status.Phase).To(Equal(v1beta1.BackupPhaseFailed))
+	g.Expect(backupReason(backup)).To(Equal(string(InputError)))
+	backups, _, err := cloudService.ListBackups(ctx, cluster.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(backups.Backups).To(BeEmpty())
+
+	// the rejected backup is released without a provider call
+	g.Expect(c.Delete(ctx, backup)).To(Succeed())
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(backup)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(apierrors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(backup), backup))).To(BeTrue())
+}
+
+// TestProviderBackupAdoptsRequestedBackup checks that a backup requested by a reconcile that could not
+// write the backup ID to the status is not created a second time.
+func TestProviderBackupAdoptsRequestedBackup(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	cloudService, cluster, objects := newReadyInstance(g, t)
+	requested := metav1.NewTime(time.Now().Add(-time.Minute))
+	backup := newBackup(dbaasv1beta1.NamespacedName{Name: "instance"})
+	backup.Status = v1beta1.ProviderBackupStatus{Phase: v1beta1.BackupPhaseInProgress, StartTime: &requested}
+	created, _, err := cloudService.CreateBackup(ctx, cluster.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(append(objects, backup)...).Build()
+	r := &ProviderBackupReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+
+	reconcileBackup(g, c, r, backup)
+	g.Expect(backup.Status.BackupID).To(Equal(created.Id))
+	backups, _, err := cloudService.ListBackups(ctx, cluster.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(backups.Backups).To(HaveLen(1))
+}
@@ -0,0 +1,317 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+	"github.com/robfig/cron/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// ProviderBackupScheduleReconciler reconciles a ProviderBackupSchedule object
+type ProviderBackupScheduleReconciler struct {
+	testutil.DBaaSProviderService
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerbackupschedules,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerbackupschedules/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerbackupschedules/finalizers,verbs=update
+
+// Reconcile creates a ProviderBackup of the referenced ProviderInstance every time the
+// schedule is due, prunes the completed backups that the retention policy does not keep,
+// and reports the last successful backup time and the failed or missed runs in the status.
+// The backups are not owned by the schedule and are kept when the schedule is deleted.
+func (r *ProviderBackupScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx, "ProviderBackupSchedule", req.NamespacedName)
+
+	var schedule v1beta1.ProviderBackupSchedule
+	if err := r.Get(ctx, req.NamespacedName, &schedule); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("ProviderBackupSchedule resource not found, may have been deleted")
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to fetch ProviderBackupSchedule for reconcile")
+		return ctrl.Result{}, err
+	}
+	original := schedule.DeepCopy()
+
+	if isReconcilePaused(&schedule) {
+		logger.Info("ProviderBackupSchedule reconciliation is paused")
+		return ctrl.Result{}, nil
+	}
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	if err != nil {
+		statusErr := r.updateStatus(ctx, original, &schedule, metav1.ConditionFalse, InputError, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating backup schedule status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Error(err, "Invalid backup schedule")
+		return ctrl.Result{}, nil
+	}
+
+	instanceKey, err := localObjectKey(schedule.Spec.InstanceRef, schedule.Namespace)
+	if err != nil {
+		statusErr := r.updateStatus(ctx, original, &schedule, metav1.ConditionFalse, InputError, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating backup schedule status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Error(err, "Invalid ProviderInstance reference")
+		return ctrl.Result{}, nil
+	}
+	// a missing instance is reported by the backups of the schedule
+	if _, _, err := getInstanceInventory(ctx, r.DBaaSProviderService, instanceKey); err != nil && !apierrors.IsNotFound(err) {
+		if !errors.Is(err, errNamespaceNotAllowed) {
+			logger.Error(err, "Failed to fetch the ProviderInstance and its ProviderInventory")
+			return ctrl.Result{}, err
+		}
+		statusErr := r.updateStatus(ctx, original, &schedule, metav1.ConditionFalse, NamespaceNotAllowed, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating backup schedule status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Info("ProviderBackupSchedule namespace is not allowed to use the ProviderInventory of the instance", "reason", err.Error())
+		// the runs are skipped until the namespace is allowed again
+		now := time.Now()
+		return ctrl.Result{RequeueAfter: cronSchedule.Next(now).Sub(now)}, nil
+	}
+
+	var backupList v1beta1.ProviderBackupList
+	if err := r.List(ctx, &backupList, client.InNamespace(schedule.Namespace),
+		client.MatchingLabels{backupScheduleLabel: schedule.Name}); err != nil {
+		logger.Error(err, "Failed to list ProviderBackups of the schedule")
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	lastRun := schedule.CreationTimestamp.Time
+	if schedule.Status.LastScheduleTime != nil {
+		lastRun = schedule.Status.LastScheduleTime.Time
+	}
+	var missedRuns int32
+	var createErr error
+	if scheduledTime, skipped := lastScheduledTime(cronSchedule, lastRun, now); !scheduledTime.IsZero() {
+		missedRuns = skipped
+		schedule.Status.LastScheduleTime = &metav1.Time{Time: scheduledTime}
+		var backup *v1beta1.ProviderBackup
+		backup, createErr = r.createScheduledBackup(ctx, &schedule, scheduledTime)
+		if createErr != nil {
+			// the run is not retried, it counts as missed and the next run creates a backup again
+			logger.Error(createErr, "Failed to create scheduled ProviderBackup")
+			missedRuns++
+		} else {
+			logger.Info("Created scheduled backup", "backup", backup.Name, "missedRuns", missedRuns)
+			schedule.Status.LastBackupName = backup.Name
+			backupList.Items = append(backupList.Items, *backup)
+		}
+	}
+
+	for _, backup := range backupsToPrune(backupList.Items, schedule.Spec.Retention) {
+		logger.Info("Pruning backup by retention policy", "backup", backup.Name)
+		if err := r.Delete(ctx, &backup); err != nil && !apierrors.IsNotFound(err) {
+			logger.Error(err, "Failed to prune ProviderBackup")
+			return ctrl.Result{}, err
+		}
+	}
+
+	lastSuccess, failedBackups := backupHistory(backupList.Items, schedule.Status.LastBackupName)
+	if lastSuccess != nil && (schedule.Status.LastSuccessfulBackupTime == nil ||
+		lastSuccess.After(schedule.Status.LastSuccessfulBackupTime.Time)) {
+		schedule.Status.MissedRuns = 0
+	}
+	schedule.Status.LastSuccessfulBackupTime = lastSuccess
+	schedule.Status.MissedRuns += missedRuns
+	schedule.Status.FailureCount = failedBackups + schedule.Status.MissedRuns
+	status, reason, msg := metav1.ConditionTrue, ScheduleOK, "Backups are taken on schedule"
+	if createErr != nil {
+		status, reason, msg = metav1.ConditionFalse, BackendError, createErr.Error()
+	} else if schedule.Status.FailureCount > 0 {
+		status, reason = metav1.ConditionFalse, BackupFailed
+		msg = fmt.Sprintf("%d backup run(s) failed since the last successful backup, %d of them missed",
+			schedule.Status.FailureCount, schedule.Status.MissedRuns)
+	}
+	if statusErr := r.updateStatus(ctx, original, &schedule, status, reason, msg); statusErr != nil {
+		logger.Error(statusErr, "Error in updating backup schedule status")
+		return ctrl.Result{Requeue: true}, statusErr
+	}
+
+	return ctrl.Result{RequeueAfter: cronSchedule.Next(now).Sub(now)}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ProviderBackupScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// the backups of a schedule are linked to it by label only, so that deleting
+	// a schedule does not delete its backups
+	scheduleMapFn := handler.MapFunc(func(a client.Object) []ctrl.Request {
+		name, ok := a.GetLabels()[backupScheduleLabel]
+		if !ok {
+			return nil
+		}
+		return []ctrl.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: a.GetNamespace()}}}
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1beta1.ProviderBackupSchedule{}).
+		Watches(&source.Kind{Type: &v1beta1.ProviderBackup{}}, handler.EnqueueRequestsFromMapFunc(scheduleMapFn)).
+		Complete(traceReconciles("ProviderBackupSchedule", r))
+}
+
+func (r *ProviderBackupScheduleReconciler) createScheduledBackup(ctx context.Context, schedule *v1beta1.ProviderBackupSchedule,
+	scheduledTime time.Time) (*v1beta1.ProviderBackup, error) {
+	backup := &v1beta1.ProviderBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", schedule.Name, scheduledTime.Unix()),
+			Namespace: schedule.Namespace,
+			Labels:    map[string]string{backupScheduleLabel: schedule.Name},
+		},
+		Spec: v1beta1.ProviderBackupSpec{
+			InstanceRef: schedule.Spec.InstanceRef,
+		},
+	}
+	backup.Spec.InstanceRef.Namespace = schedule.Namespace
+	if err := r.Create(ctx, backup); err != nil && !apierrors.IsAlreadyExists(err) {
+		return nil, err
+	}
+	return backup, nil
+}
+
+func (r *ProviderBackupScheduleReconciler) updateStatus(ctx context.Context, original, schedule *v1beta1.ProviderBackupSchedule,
+	status metav1.ConditionStatus, reason ConditionReason, msg string) error {
+	setStatusCondition(&schedule.Status.Conditions, schedule, backupScheduleConditionType, status, reason, msg)
+	return patchStatus(ctx, r.DBaaSProviderService, original, schedule)
+}
+
+// lastScheduledTime returns the latest time the schedule was due after lastRun and not after now,
+// or the zero time if the schedule is not due, and the number of runs missed in between.
+func lastScheduledTime(schedule cron.Schedule, lastRun, now time.Time) (time.Time, int32) {
+	var scheduled time.Time
+	var missed int32
+	for t := schedule.Next(lastRun); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		if !scheduled.IsZero() {
+			missed++
+		}
+		scheduled = t
+	}
+	return scheduled, missed
+}
+
+// backupsToPrune returns the completed backups not kept by the retention policy,
+// and the failed backups older than the most recent completed backup.
+func backupsToPrune(backups []v1beta1.ProviderBackup, retention v1beta1.BackupRetentionPolicy) []v1beta1.ProviderBackup {
+	if retention == (v1beta1.BackupRetentionPolicy{}) {
+		return nil
+	}
+
+	var completed []v1beta1.ProviderBackup
+	for _, backup := range backups {
+		if backup.Status.Phase == v1beta1.BackupPhaseCompleted && backup.DeletionTimestamp.IsZero() {
+			completed = append(completed, backup)
+		}
+	}
+	sortBackupsNewestFirst(completed)
+
+	keep := map[string]bool{}
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+	for i, backup := range completed {
+		t := backupTime(backup).UTC()
+		if int32(i) < retention.KeepLast {
+			keep[backup.Name] = true
+		}
+		day := t.Format("2006-01-02")
+		if !days[day] && int32(len(days)) < retention.KeepDaily {
+			days[day] = true
+			keep[backup.Name] = true
+		}
+		year, week := t.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%d", year, week)
+		if !weeks[weekKey] && int32(len(weeks)) < retention.KeepWeekly {
+			weeks[weekKey] = true
+			keep[backup.Name] = true
+		}
+	}
+
+	var prune []v1beta1.ProviderBackup
+	for _, backup := range completed {
+		if !keep[backup.Name] {
+			prune = append(prune, backup)
+		}
+	}
+	if len(completed) > 0 {
+		newest := backupTime(completed[0])
+		for _, backup := range backups {
+			if backup.Status.Phase == v1beta1.BackupPhaseFailed && backup.DeletionTimestamp.IsZero() &&
+				backupTime(backup).Before(newest) {
+				prune = append(prune, backup)
+			}
+		}
+	}
+	return prune
+}
+
+// backupHistory returns the completion time of the most recent successful backup,
+// and the number of backups after it that failed or did not finish before the backup of the
+// next run was created. The backup of the latest run is only counted once it failed.
+func backupHistory(backups []v1beta1.ProviderBackup, latestBackupName string) (*metav1.Time, int32) {
+	sorted := append([]v1beta1.ProviderBackup{}, backups...)
+	sortBackupsNewestFirst(sorted)
+
+	var failures int32
+	for _, backup := range sorted {
+		switch {
+		case backup.Status.Phase == v1beta1.BackupPhaseCompleted:
+			lastSuccess := metav1.NewTime(backupTime(backup))
+			return &lastSuccess, failures
+		case backup.Status.Phase == v1beta1.BackupPhaseFailed:
+			failures++
+		case backup.Name != latestBackupName && backup.DeletionTimestamp.IsZero():
+			failures++
+		}
+	}
+	return nil, failures
+}
+
+func sortBackupsNewestFirst(backups []v1beta1.ProviderBackup) {
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backupTime(backups[i]).After(backupTime(backups[j]))
+	})
+}
+
+func backupTime(backup v1beta1.ProviderBackup) time.Time {
+	if backup.Status.CompletionTime != nil {
+		return backup.Status.CompletionTime.Time
+	}
+	return backup.CreationTimestamp.Time
+}
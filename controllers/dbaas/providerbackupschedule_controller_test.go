@@ -0,0 +1,280 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	. "github.com/onsi/gomega"
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+)
+
+var scheduleTestTime = time.Date(2023, time.March, 15, 12, 30, 0, 0, time.UTC)
+
+func newScheduledBackup(name string, phase v1beta1.BackupPhase, age time.Duration) v1beta1.ProviderBackup {
+	created := metav1.NewTime(scheduleTestTime.Add(-age))
+	backup := v1beta1.ProviderBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", CreationTimestamp: created},
+		Status:     v1beta1.ProviderBackupStatus{Phase: phase},
+	}
+	if phase == v1beta1.BackupPhaseCompleted || phase == v1beta1.BackupPhaseFailed {
+		backup.Status.CompletionTime = &created
+	}
+	return backup
+}
+
+func backupNames(backups []v1beta1.ProviderBackup) []string {
+	var names []string
+	for _, backup := range backups {
+		names = append(names, backup.Name)
+	}
+	return names
+}
+
+func TestLastScheduledTime(t *testing.T) {
+	hourly, err := cron.ParseStandard("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		lastRun       time.Time
+		wantScheduled time.Time
+		wantMissed    int32
+	}{
+		{
+			name:    "not due",
+			lastRun: scheduleTestTime.Add(-30 * time.Minute),
+		},
+		{
+			name:          "due",
+			lastRun:       scheduleTestTime.Add(-time.Hour),
+			wantScheduled: time.Date(2023, time.March, 15, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "due since the last run",
+			lastRun:       scheduleTestTime.Add(-90 * time.Minute),
+			wantScheduled: time.Date(2023, time.March, 15, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "missed runs",
+			lastRun:       scheduleTestTime.Add(-4 * time.Hour),
+			wantScheduled: time.Date(2023, time.March, 15, 12, 0, 0, 0, time.UTC),
+			wantMissed:    3,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			scheduled, missed := lastScheduledTime(hourly, tc.lastRun, scheduleTestTime)
+			g.Expect(scheduled).To(Equal(tc.wantScheduled))
+			g.Expect(missed).To(Equal(tc.wantMissed))
+		})
+	}
+}
+
+func TestBackupsToPrune(t *testing.T) {
+	day := 24 * time.Hour
+	backups := []v1beta1.ProviderBackup{
+		newScheduledBackup("b-0", v1beta1.BackupPhaseCompleted, time.Hour),
+		newScheduledBackup("b-1", v1beta1.BackupPhaseCompleted, 2*time.Hour),
+		newScheduledBackup("b-2", v1beta1.BackupPhaseFailed, 3*time.Hour),
+		newScheduledBackup("b-3", v1beta1.BackupPhaseCompleted, day+time.Hour),
+		newScheduledBackup("b-4", v1beta1.BackupPhaseCompleted, 2*day+time.Hour),
+		newScheduledBackup("b-5", v1beta1.BackupPhaseCompleted, 8*day),
+		newScheduledBackup("b-6", v1beta1.BackupPhaseInProgress, 9*day),
+	}
+	tests := []struct {
+		name      string
+		retention v1beta1.BackupRetentionPolicy
+		want      []string
+	}{
+		{
+			name: "no retention policy",
+		},
+		{
+			name:      "keep last",
+			retention: v1beta1.BackupRetentionPolicy{KeepLast: 2},
+			want:      []string{"b-3", "b-4", "b-5", "b-2"},
+		},
+		{
+			name:      "keep daily",
+			retention: v1beta1.BackupRetentionPolicy{KeepDaily: 2},
+			want:      []string{"b-1", "b-4", "b-5", "b-2"},
+		},
+		{
+			name:      "keep weekly",
+			retention: v1beta1.BackupRetentionPolicy{KeepWeekly: 2},
+			want:      []string{"b-1", "b-3", "b-4", "b-2"},
+		},
+		{
+			name:      "combined rules",
+			retention: v1beta1.BackupRetentionPolicy{KeepLast: 1, KeepDaily: 3},
+			want:      []string{"b-1", "b-5", "b-2"},
+		},
+		{
+			name:      "more rules than backups",
+			retention: v1beta1.BackupRetentionPolicy{KeepLast: 10},
+			want:      []string{"b-2"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(backupNames(backupsToPrune(backups, tc.retention))).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestBackupHistory(t *testing.T) {
+	tests := []struct {
+		name            string
+		backups         []v1beta1.ProviderBackup
+		latest          string
+		wantLastSuccess *time.Time
+		wantFailures    int32
+	}{
+		{
+			name: "no backups",
+		},
+		{
+			name: "last backup completed",
+			backups: []v1beta1.ProviderBackup{
+				newScheduledBackup("b-0", v1beta1.BackupPhaseCompleted, time.Hour),
+				newScheduledBackup("b-1", v1beta1.BackupPhaseFailed, 2*time.Hour),
+			},
+			latest:          "b-0",
+			wantLastSuccess: timePtr(scheduleTestTime.Add(-time.Hour)),
+		},
+		{
+			name: "failed since the last success",
+			backups: []v1beta1.ProviderBackup{
+				newScheduledBackup("b-0", v1beta1.BackupPhaseFailed, time.Hour),
+				newScheduledBackup("b-1", v1beta1.BackupPhaseFailed, 2*time.Hour),
+				newScheduledBackup("b-2", v1beta1.BackupPhaseCompleted, 3*time.Hour),
+			},
+			latest:          "b-0",
+			wantLastSuccess: timePtr(scheduleTestTime.Add(-3 * time.Hour)),
+			wantFailures:    2,
+		},
+		{
+			name: "latest backup in progress",
+			backups: []v1beta1.ProviderBackup{
+				newScheduledBackup("b-0", v1beta1.BackupPhaseInProgress, time.Hour),
+				newScheduledBackup("b-1", v1beta1.BackupPhaseCompleted, 2*time.Hour),
+			},
+			latest:          "b-0",
+			wantLastSuccess: timePtr(scheduleTestTime.Add(-2 * time.Hour)),
+		},
+		{
+			name: "stuck backups",
+			backups: []v1beta1.ProviderBackup{
+				newScheduledBackup("b-0", v1beta1.BackupPhasePending, time.Hour),
+				newScheduledBackup("b-1", v1beta1.BackupPhasePending, 2*time.Hour),
+				newScheduledBackup("b-2", v1beta1.BackupPhaseInProgress, 3*time.Hour),
+			},
+			latest:       "b-0",
+			wantFailures: 2,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			lastSuccess, failures := backupHistory(tc.backups, tc.latest)
+			if tc.wantLastSuccess == nil {
+				g.Expect(lastSuccess).To(BeNil())
+			} else {
+				g.Expect(lastSuccess).NotTo(BeNil())
+				g.Expect(lastSuccess.Time).To(Equal(*tc.wantLastSuccess))
+			}
+			g.Expect(failures).To(Equal(tc.wantFailures))
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+// failingCreateClient fails the creation of ProviderBackups.
+type failingCreateClient struct {
+	client.Client
+}
+
+func (c *failingCreateClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if _, ok := obj.(*v1beta1.ProviderBackup); ok {
+		return context.DeadlineExceeded
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func TestProviderBackupScheduleReconcile(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	lastRun := metav1.NewTime(time.Now().Add(-3 * time.Hour).Truncate(time.Hour))
+	schedule := &v1beta1.ProviderBackupSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "default"},
+		Spec: v1beta1.ProviderBackupScheduleSpec{
+			InstanceRef: dbaasv1beta1.NamespacedName{Name: "instance"},
+			Schedule:    "0 * * * *",
+		},
+		Status: v1beta1.ProviderBackupScheduleStatus{LastScheduleTime: &lastRun},
+	}
+
+	// the runs missed while the operator was not running are reported
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(schedule).Build()
+	r := &ProviderBackupScheduleReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(schedule)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(schedule), schedule)).To(Succeed())
+	g.Expect(schedule.Status.MissedRuns).To(Equal(int32(2)))
+	g.Expect(schedule.Status.FailureCount).To(Equal(int32(2)))
+	g.Expect(schedule.Status.Conditions[0].Reason).To(Equal(string(BackupFailed)))
+
+	// the backups are linked by label only, they are kept when the schedule is deleted
+	var backup v1beta1.ProviderBackup
+	g.Expect(c.Get(ctx, client.ObjectKey{Namespace: "default", Name: schedule.Status.LastBackupName}, &backup)).To(Succeed())
+	g.Expect(backup.Labels).To(HaveKeyWithValue(backupScheduleLabel, "nightly"))
+	g.Expect(backup.OwnerReferences).To(BeEmpty())
+
+	// a run whose backup cannot be created counts as missed
+	backup.Status.Phase = v1beta1.BackupPhaseCompleted
+	backup.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+	g.Expect(c.Status().Update(ctx, &backup)).To(Succeed())
+	previousRun := metav1.NewTime(schedule.Status.LastScheduleTime.Add(-time.Hour))
+	schedule.Status.LastScheduleTime = &previousRun
+	g.Expect(c.Status().Update(ctx, schedule)).To(Succeed())
+	r.DBaaSProviderService = &testutil.FakeProviderService{Client: &failingCreateClient{Client: c}}
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(schedule)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(schedule), schedule)).To(Succeed())
+	g.Expect(schedule.Status.LastSuccessfulBackupTime).NotTo(BeNil())
+	g.Expect(schedule.Status.MissedRuns).To(Equal(int32(1)))
+	g.Expect(schedule.Status.FailureCount).To(Equal(int32(1)))
+	g.Expect(schedule.Status.Conditions[0].Reason).To(Equal(string(BackendError)))
+}
@@ -20,31 +20,39 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
+
 	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
 	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
 	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // ProviderConnectionReconciler reconciles a ProviderConnection object
 type ProviderConnectionReconciler struct {
 	testutil.DBaaSProviderService
 	Scheme *runtime.Scheme
+	// Options are the concurrency and the rate limiter of the controller
+	Options ControllerOptions
 }
 
 //+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerconnections,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerconnections/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerconnections/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;delete;update
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;delete;update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -69,11 +77,17 @@ func (r *ProviderConnectionReconciler) Reconcile(ctx context.Context, req ctrl.R
 		logger.Error(err, "Failed to fetch ProviderConnection for reconcile")
 		return ctrl.Result{}, err
 	}
+	original := connection.DeepCopy()
+
+	if isReconcilePaused(&connection) {
+		logger.Info("ProviderConnection reconciliation is paused")
+		return ctrl.Result{}, nil
+	}
 
 	inventory := v1beta1.ProviderInventory{}
 	if err := r.Get(ctx, client.ObjectKey{Namespace: connection.Spec.InventoryRef.Namespace, Name: connection.Spec.InventoryRef.Name}, &inventory); err != nil {
 		if apierrors.IsNotFound(err) {
-			statusErr := r.updateStatus(ctx, &connection, metav1.ConditionFalse, InventoryNotFound, err.Error())
+			statusErr := r.updateStatus(ctx, original, &connection, metav1.ConditionFalse, InventoryNotFound, err.Error())
 			if statusErr != nil {
 				logger.Error(statusErr, "Error in updating connection status")
 				return ctrl.Result{Requeue: true}, statusErr
@@ -86,10 +100,43 @@ func (r *ProviderConnectionReconciler) Reconcile(ctx context.Context, req ctrl.R
 		return ctrl.Result{}, err
 	}
 
+	if err := checkNamespaceAllowed(ctx, r.DBaaSProviderService, &inventory, connection.Namespace); err != nil {
+		if !errors.Is(err, errNamespaceNotAllowed) {
+			logger.Error(err, "Failed to check the namespace against the ProviderInventory allowed namespaces")
+			return ctrl.Result{}, err
+		}
+		// the credentials and the connection info of a rejected connection are not kept in its namespace
+		if deleteErr := r.deleteConnectionObjects(ctx, &connection); deleteErr != nil {
+			logger.Error(deleteErr, "Failed to delete the secret and config map of the rejected ProviderConnection")
+			return ctrl.Result{}, deleteErr
+		}
+		statusErr := r.updateStatus(ctx, original, &connection, metav1.ConditionFalse, NamespaceNotAllowed, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating connection status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Info("ProviderConnection namespace is not allowed to use the ProviderInventory", "inventory", connection.Spec.InventoryRef)
+		return ctrl.Result{}, nil
+	}
+
+	if err := checkConnectionQuota(ctx, r.DBaaSProviderService, &connection); err != nil {
+		if !errors.Is(err, errQuotaExceeded) {
+			logger.Error(err, "Failed to check the ProviderQuotas of the namespace")
+			return ctrl.Result{}, err
+		}
+		statusErr := r.updateStatus(ctx, original, &connection, metav1.ConditionFalse, QuotaExceeded, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating connection status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Info("ProviderConnection exceeds a ProviderQuota", "reason", err.Error())
+		return ctrl.Result{RequeueAfter: quotaRetryDelay}, nil
+	}
+
 	logger.Info("Checking if Inventory is with a valid cluster")
 	instance, err := getClusterInstance(inventory, connection.Spec.DatabaseServiceID)
 	if err != nil {
-		statusErr := r.updateStatus(ctx, &connection, metav1.ConditionFalse, ConnectionNotReady, err.Error())
+		statusErr := r.updateStatus(ctx, original, &connection, metav1.ConditionFalse, ConnectionNotReady, err.Error())
 		if statusErr != nil {
 			logger.Error(statusErr, "Error in updating connection status")
 			return ctrl.Result{Requeue: true}, statusErr
@@ -105,7 +152,7 @@ func (r *ProviderConnectionReconciler) Reconcile(ctx context.Context, req ctrl.R
 	}
 	cloudService, err := r.CreateCloudService(ctx, secretSelector)
 	if err != nil {
-		statusErr := r.updateStatus(ctx, &connection, metav1.ConditionFalse, BackendError, err.Error())
+		statusErr := r.updateStatus(ctx, original, &connection, metav1.ConditionFalse, BackendError, err.Error())
 		if statusErr != nil {
 			logger.Error(statusErr, "Error in updating connection status")
 			return ctrl.Result{Requeue: true}, statusErr
@@ -114,13 +161,50 @@ func (r *ProviderConnectionReconciler) Reconcile(ctx context.Context, req ctrl.R
 		return ctrl.Result{}, err
 	}
 
-	logger.Info("Created CloudClient for cloud", "cloudService", cloudService)
-	logger.Info("Create or get sql user for Connection", "instance", instance)
+	logger.Info("Created CloudClient for cloud")
+
+	interval, err := getRotationInterval(&inventory, &connection)
+	if err != nil {
+		statusErr := r.updateStatus(ctx, original, &connection, metav1.ConditionFalse, InputError, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating connection status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Error(err, "Invalid credential rotation interval")
+		return ctrl.Result{}, nil
+	}
+
+	userSecret := &corev1.Secret{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: connection.Namespace, Name: userSecretName(&connection)}, userSecret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			logger.Error(err, "Failed to fetch the secret of the sql user")
+			return ctrl.Result{}, err
+		}
+		userSecret = nil
+	}
+
+	now := time.Now()
+	var credentials map[string][]byte
+	if rotationDue(&connection, userSecret, interval, now) {
+		// the instance info of a provider may hold connection details, only its ID is logged
+		logger.Info("Set the password of the sql user for Connection", "instanceID", instance.ServiceID)
+		credentials, err = r.rotateCredentials(ctx, cloudService, &connection, instance)
+		if err != nil {
+			statusErr := r.updateStatus(ctx, original, &connection, metav1.ConditionFalse, BackendError, err.Error())
+			if statusErr != nil {
+				logger.Error(statusErr, "Error in updating connection status")
+				return ctrl.Result{Requeue: true}, statusErr
+			}
+			logger.Error(err, "Failed to set the password of the sql user")
+			return ctrl.Result{}, err
+		}
+	}
 
 	logger.Info("Create or update secret for Connection")
-	userSecret, err := r.createOrUpdateSecret(ctx, &connection, logger)
+	// the new credentials are written in a single update of the secret
+	userSecret, err = r.createOrUpdateSecret(ctx, &connection, credentials, logger)
 	if err != nil {
-		statusErr := r.updateStatus(ctx, &connection, metav1.ConditionFalse, BackendError, err.Error())
+		statusErr := r.updateStatus(ctx, original, &connection, metav1.ConditionFalse, BackendError, err.Error())
 		if statusErr != nil {
 			logger.Error(statusErr, "Error in updating connection status")
 			return ctrl.Result{Requeue: true}, statusErr
@@ -131,7 +215,7 @@ func (r *ProviderConnectionReconciler) Reconcile(ctx context.Context, req ctrl.R
 	logger.Info("Create or update config map for Connection")
 	dbConfigMap, err := r.createOrUpdateConfigMap(ctx, &connection, instance, logger)
 	if err != nil {
-		statusErr := r.updateStatus(ctx, &connection, metav1.ConditionFalse, BackendError, err.Error())
+		statusErr := r.updateStatus(ctx, original, &connection, metav1.ConditionFalse, BackendError, err.Error())
 		if statusErr != nil {
 			logger.Error(statusErr, "Error in updating connection status")
 			return ctrl.Result{Requeue: true}, statusErr
@@ -142,19 +226,41 @@ func (r *ProviderConnectionReconciler) Reconcile(ctx context.Context, req ctrl.R
 	logger.Info("Updating Connection status")
 	connection.Status.CredentialsRef = &corev1.LocalObjectReference{Name: userSecret.Name}
 	connection.Status.ConnectionInfoRef = &corev1.LocalObjectReference{Name: dbConfigMap.Name}
-	statusErr := r.updateStatus(ctx, &connection, metav1.ConditionTrue, ConnectionReady, SuccessConnection)
+	if credentials != nil {
+		connection.Status.LastRotationTime = &metav1.Time{Time: now}
+	}
+	lastRotationTime := connection.Status.LastRotationTime
+	statusErr := r.updateStatus(ctx, original, &connection, metav1.ConditionTrue, ConnectionReady, SuccessConnection)
 	if statusErr != nil {
 		logger.Error(statusErr, "Error in updating connection status")
 		return ctrl.Result{Requeue: true}, statusErr
 	}
+
+	if credentials != nil && len(connection.Annotations[RotateCredentialsAnnotation]) != 0 {
+		// the rotation is recorded in the status, a failure to remove the annotation only rotates once more
+		patch := client.MergeFrom(connection.DeepCopy())
+		delete(connection.Annotations, RotateCredentialsAnnotation)
+		if err := r.Patch(ctx, &connection, patch); err != nil {
+			logger.Error(err, "Failed to remove the rotate credentials annotation")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if interval > 0 && lastRotationTime != nil {
+		return ctrl.Result{RequeueAfter: lastRotationTime.Add(interval).Sub(now)}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ProviderConnectionReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(r.Options.controllerOptions()).
 		For(&v1beta1.ProviderConnection{}).
-		Complete(r)
+		Watches(&source.Kind{Type: &v1beta1.ProviderInventory{}}, handler.EnqueueRequestsFromMapFunc(inventoryConnectionsMapFn(r.DBaaSProviderService)),
+			builder.WithPredicates(allowedNamespacesChanged())).
+		Watches(&source.Kind{Type: &v1beta1.ProviderQuota{}}, handler.EnqueueRequestsFromMapFunc(quotaRejectedConnectionsMapFn(r.DBaaSProviderService))).
+		Complete(traceReconciles("ProviderConnection", r))
 }
 
 func getClusterInstance(inventory v1beta1.ProviderInventory, instanceID string) (*dbaasv1beta1.DatabaseService, error) {
@@ -177,12 +283,54 @@ func getClusterInstance(inventory v1beta1.ProviderInventory, instanceID string)
 	return nil, fmt.Errorf("instance with id:%v not found in ProviderInventory", instanceID)
 }
 
-func (r *ProviderConnectionReconciler) createOrUpdateSecret(ctx context.Context, connection *v1beta1.ProviderConnection, logger logr.Logger) (*corev1.Secret, error) {
+// rotateCredentials sets a new password of the database user of the connection, and returns the credentials of the user.
+func (r *ProviderConnectionReconciler) rotateCredentials(ctx context.Context, cloudService testutil.Service,
+	connection *v1beta1.ProviderConnection, instance *dbaasv1beta1.DatabaseService) (map[string][]byte, error) {
+	password, err := generatePassword()
+	if err != nil {
+		return nil, err
+	}
+	username := sqlUserName(connection)
+	if err := r.SetSqlUserPassword(ctx, cloudService, instance.ServiceID, username, password); err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		"username": []byte(username),
+		"password": []byte(password),
+	}, nil
+}
+
+func userSecretName(connection *v1beta1.ProviderConnection) string {
+	return fmt.Sprintf("cloud-user-credentials-%s", connection.Name)
+}
+
+func configMapName(connection *v1beta1.ProviderConnection) string {
+	return fmt.Sprintf("cloud-conn-cm-%s", connection.Name)
+}
 
-	secretName := fmt.Sprintf("cloud-user-credentials-%s", connection.Name)
+// deleteConnectionObjects deletes the secret of the sql user and the config map of the connection,
+// and removes their references from the status.
+func (r *ProviderConnectionReconciler) deleteConnectionObjects(ctx context.Context, connection *v1beta1.ProviderConnection) error {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: userSecretName(connection), Namespace: connection.Namespace}}
+	if err := r.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: configMapName(connection), Namespace: connection.Namespace}}
+	if err := r.Delete(ctx, cm); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	connection.Status.CredentialsRef = nil
+	connection.Status.ConnectionInfoRef = nil
+	return nil
+}
+
+// createOrUpdateSecret writes the secret of the sql user of the connection, replacing its data with the credentials
+// if they are not nil.
+func (r *ProviderConnectionReconciler) createOrUpdateSecret(ctx context.Context, connection *v1beta1.ProviderConnection,
+	credentials map[string][]byte, logger logr.Logger) (*corev1.Secret, error) {
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      secretName,
+			Name:      userSecretName(connection),
 			Namespace: connection.Namespace,
 		},
 	}
@@ -194,7 +342,9 @@ func (r *ProviderConnectionReconciler) createOrUpdateSecret(ctx context.Context,
 			return err
 		}
 		secret.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
-		setSecret(secret)
+		if credentials != nil {
+			secret.Data = credentials
+		}
 		return nil
 	})
 	if err != nil {
@@ -206,29 +356,20 @@ func (r *ProviderConnectionReconciler) createOrUpdateSecret(ctx context.Context,
 
 func buildLabels(connection *v1beta1.ProviderConnection) map[string]string {
 	return map[string]string{
-		"managed-by":      "dbaas-operator",
+		managedByLabel:    managedByValue,
 		"owner":           connection.Name,
 		"owner.kind":      connection.Kind,
 		"owner.namespace": connection.Namespace,
 	}
 }
 
-func setSecret(secret *corev1.Secret) {
-	data := map[string][]byte{
-		"username": []byte("username1"),
-		"password": []byte("Password"),
-	}
-	secret.Data = data
-}
-
 func (r *ProviderConnectionReconciler) createOrUpdateConfigMap(ctx context.Context, connection *v1beta1.ProviderConnection,
 	instance *dbaasv1beta1.DatabaseService, logger logr.Logger) (*corev1.ConfigMap, error) {
 	logger.Info("Saving this instance's connection info in a configMap")
 
-	cmName := fmt.Sprintf("cloud-conn-cm-%s", connection.Name)
 	cm := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      cmName,
+			Name:      configMapName(connection),
 			Namespace: connection.Namespace,
 		},
 	}
@@ -260,22 +401,8 @@ func setConfigMap(cm *corev1.ConfigMap, instance *dbaasv1beta1.DatabaseService)
 	cm.Data = dataMap
 }
 
-func (r *ProviderConnectionReconciler) updateStatus(ctx context.Context, conn *v1beta1.ProviderConnection,
+func (r *ProviderConnectionReconciler) updateStatus(ctx context.Context, original, conn *v1beta1.ProviderConnection,
 	status metav1.ConditionStatus, reason ConditionReason, msg string) error {
-
-	curCondition := metav1.Condition{
-		Type:    connectionConditionReadyType,
-		Status:  status,
-		Reason:  string(reason),
-		Message: msg,
-	}
-
-	apimeta.SetStatusCondition(&conn.Status.Conditions, curCondition)
-	if err := r.Status().Update(ctx, conn); err != nil {
-		if apierrors.IsConflict(err) {
-			return nil
-		}
-		return err
-	}
-	return nil
+	setStatusCondition(&conn.Status.Conditions, conn, connectionConditionReadyType, status, reason, msg)
+	return patchStatus(ctx, r.DBaaSProviderService, original, conn)
 }
@@ -0,0 +1,246 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+)
+
+func createConnection(ctx context.Context, namespace, inventoryName, serviceID string) *v1beta1.ProviderConnection {
+	connection := &v1beta1.ProviderConnection{
+		ObjectMeta: metav1.ObjectMeta{Name: "connection", Namespace: namespace},
+		Spec: dbaasv1beta1.DBaaSConnectionSpec{
+			InventoryRef:      dbaasv1beta1.NamespacedName{Name: inventoryName, Namespace: namespace},
+			DatabaseServiceID: serviceID,
+		},
+	}
+	Expect(k8sClient.Create(ctx, connection)).To(Succeed())
+	return connection
+}
+
+var _ = Describe("ProviderConnection controller", func() {
+	ctx := context.Background()
+	var namespace string
+
+	BeforeEach(func() {
+		namespace = createNamespace(ctx)
+	})
+
+	It("creates the connection secret and config map", func() {
+		secret := createCredentials(ctx, namespace, validCredentials(namespace))
+		inventory := createInventory(ctx, namespace, secret.Name)
+		Eventually(condition(ctx, inventory, &inventory.Status.Conditions, inventoryConditionTypeReady), timeout, interval).
+			Should(haveCondition(metav1.ConditionTrue, InventorySyncOK))
+		connection := createConnection(ctx, namespace, inventory.Name, "a-cluster-instance-1-id")
+
+		Eventually(condition(ctx, connection, &connection.Status.Conditions, connectionConditionReadyType), timeout, interval).
+			Should(haveCondition(metav1.ConditionTrue, ConnectionReady))
+		Expect(connection.Status.CredentialsRef).NotTo(BeNil())
+		Expect(connection.Status.ConnectionInfoRef).NotTo(BeNil())
+
+		userSecret := &corev1.Secret{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: connection.Status.CredentialsRef.Name}, userSecret)).To(Succeed())
+		Expect(userSecret.Name).To(Equal(fmt.Sprintf("cloud-user-credentials-%s", connection.Name)))
+		Expect(userSecret.Data).To(HaveKey("username"))
+		Expect(userSecret.Data).To(HaveKey("password"))
+		Expect(userSecret.Labels).To(HaveKeyWithValue(dbaasv1beta1.TypeLabelKey, dbaasv1beta1.TypeLabelValue))
+		Expect(metav1.IsControlledBy(userSecret, connection)).To(BeTrue())
+
+		configMap := &corev1.ConfigMap{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: connection.Status.ConnectionInfoRef.Name}, configMap)).To(Succeed())
+		Expect(configMap.Name).To(Equal(fmt.Sprintf("cloud-conn-cm-%s", connection.Name)))
+		Expect(configMap.Data).To(HaveKeyWithValue("host", databasehost))
+		Expect(metav1.IsControlledBy(configMap, connection)).To(BeTrue())
+	})
+
+	It("reports a missing inventory", func() {
+		connection := createConnection(ctx, namespace, "missing", "a-cluster-instance-1-id")
+
+		Eventually(condition(ctx, connection, &connection.Status.Conditions, connectionConditionReadyType), timeout, interval).
+			Should(haveCondition(metav1.ConditionFalse, InventoryNotFound))
+		Expect(connection.Status.CredentialsRef).To(BeNil())
+	})
+
+	It("reports a database service the inventory did not discover", func() {
+		secret := createCredentials(ctx, namespace, validCredentials(namespace))
+		inventory := createInventory(ctx, namespace, secret.Name)
+		Eventually(condition(ctx, inventory, &inventory.Status.Conditions, inventoryConditionTypeReady), timeout, interval).
+			Should(haveCondition(metav1.ConditionTrue, InventorySyncOK))
+		connection := createConnection(ctx, namespace, inventory.Name, "missing-cluster-id")
+
+		Eventually(condition(ctx, connection, &connection.Status.Conditions, connectionConditionReadyType), timeout, interval).
+			Should(haveCondition(metav1.ConditionFalse, ConnectionNotReady))
+		Expect(connection.Status.CredentialsRef).To(BeNil())
+	})
+})
+
+// TestProviderConnectionRotatesCredentials checks that the password of the database user is set when the
+// connection is created, when a rotation is requested by annotation and when the rotation interval elapses.
+func TestSQLUserName(t *testing.T) {
+	g := NewWithT(t)
+	newConnection := func(namespace, name string) *v1beta1.ProviderConnection {
+		return &v1beta1.ProviderConnection{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	}
+
+	g.Expect(sqlUserName(newConnection("team", "db"))).To(Equal("team_db"))
+	g.Expect(sqlUserName(newConnection("a-b", "c"))).NotTo(Equal(sqlUserName(newConnection("a", "b-c"))))
+}
+
+func TestProviderConnectionRotatesCredentials(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"},
+		Data: map[string][]byte{
+			"CredentialField1": []byte(t.Name()),
+			"CredentialField2": []byte("field2"),
+		},
+	}
+	inventory := &v1beta1.ProviderInventory{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "inventory",
+			Namespace:   "default",
+			Annotations: map[string]string{CredentialRotationIntervalAnnotation: "24h"},
+		},
+		Spec: dbaasv1beta1.DBaaSInventorySpec{
+			CredentialsRef: &dbaasv1beta1.LocalObjectReference{Name: secret.Name},
+		},
+		Status: dbaasv1beta1.DBaaSInventoryStatus{
+			Conditions: []metav1.Condition{{
+				Type:               inventoryConditionTypeReady,
+				Status:             metav1.ConditionTrue,
+				Reason:             string(InventorySyncOK),
+				LastTransitionTime: metav1.Now(),
+			}},
+			DatabaseServices: []dbaasv1beta1.DatabaseService{{ServiceID: "a-cluster-instance-1-id"}},
+		},
+	}
+	connection := &v1beta1.ProviderConnection{
+		ObjectMeta: metav1.ObjectMeta{Name: "connection", Namespace: "default"},
+		Spec: dbaasv1beta1.DBaaSConnectionSpec{
+			InventoryRef:      dbaasv1beta1.NamespacedName{Name: inventory.Name, Namespace: inventory.Namespace},
+			DatabaseServiceID: "a-cluster-instance-1-id",
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(secret, inventory, connection).Build()
+	r := &ProviderConnectionReconciler{
+		DBaaSProviderService: &testutil.FakeProviderService{Client: c},
+		Scheme:               c.Scheme(),
+	}
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(connection)}
+	userSecretKey := client.ObjectKey{Namespace: connection.Namespace, Name: userSecretName(connection)}
+	reconcile := func() (ctrl.Result, *v1beta1.ProviderConnection, *corev1.Secret) {
+		result, err := r.Reconcile(ctx, request)
+		g.Expect(err).NotTo(HaveOccurred())
+		reconciled := &v1beta1.ProviderConnection{}
+		g.Expect(c.Get(ctx, request.NamespacedName, reconciled)).To(Succeed())
+		userSecret := &corev1.Secret{}
+		g.Expect(c.Get(ctx, userSecretKey, userSecret)).To(Succeed())
+		return result, reconciled, userSecret
+	}
+
+	result, reconciled, userSecret := reconcile()
+	g.Expect(userSecret.Data).To(HaveKeyWithValue("username", []byte("default_connection")))
+	g.Expect(userSecret.Data["password"]).NotTo(BeEmpty())
+	g.Expect(reconciled.Status.LastRotationTime).NotTo(BeNil())
+	g.Expect(result.RequeueAfter).To(BeNumerically("~", 24*time.Hour, time.Minute))
+	password := userSecret.Data["password"]
+
+	// the credentials are kept until a rotation is due
+	_, reconciled, userSecret = reconcile()
+	g.Expect(userSecret.Data["password"]).To(Equal(password))
+	lastRotationTime := reconciled.Status.LastRotationTime
+
+	reconciled.Annotations = map[string]string{RotateCredentialsAnnotation: "true"}
+	g.Expect(c.Update(ctx, reconciled)).To(Succeed())
+	_, reconciled, userSecret = reconcile()
+	g.Expect(userSecret.Data["password"]).NotTo(Equal(password))
+	g.Expect(reconciled.Annotations).NotTo(HaveKey(RotateCredentialsAnnotation))
+	password = userSecret.Data["password"]
+
+	reconciled.Status.LastRotationTime = &metav1.Time{Time: lastRotationTime.Add(-25 * time.Hour)}
+	g.Expect(c.Status().Update(ctx, reconciled)).To(Succeed())
+	_, reconciled, userSecret = reconcile()
+	g.Expect(userSecret.Data["password"]).NotTo(Equal(password))
+	g.Expect(reconciled.Status.LastRotationTime.After(lastRotationTime.Add(-time.Hour))).To(BeTrue())
+
+	// an invalid interval is reported without changing the credentials
+	reconciled.Annotations = map[string]string{CredentialRotationIntervalAnnotation: "monthly"}
+	g.Expect(c.Update(ctx, reconciled)).To(Succeed())
+	password = userSecret.Data["password"]
+	_, reconciled, userSecret = reconcile()
+	g.Expect(userSecret.Data["password"]).To(Equal(password))
+	g.Expect(apimeta.FindStatusCondition(reconciled.Status.Conditions, connectionConditionReadyType).Reason).To(Equal(string(InputError)))
+}
+
+func FuzzConnectionInfo(f *testing.F) {
+	f.Add("a-cluster-instance-1-id", "a-cluster-instance-1-id", "a-cluster-test-1", "state", "CREATED", true)
+	f.Add("a-cluster-instance-1-id", "other-id", "", "", "", true)
+	f.Add("", "", "", "", "", false)
+
+	f.Fuzz(func(t *testing.T, connectionServiceID, serviceID, serviceName, infoKey, infoValue string, synced bool) {
+		inventory := v1beta1.ProviderInventory{
+			Status: dbaasv1beta1.DBaaSInventoryStatus{
+				DatabaseServices: []dbaasv1beta1.DatabaseService{{
+					ServiceID:   serviceID,
+					ServiceName: serviceName,
+					ServiceInfo: map[string]string{infoKey: infoValue},
+				}},
+			},
+		}
+		if synced {
+			inventory.Status.Conditions = []metav1.Condition{{Type: inventoryConditionTypeReady, Status: metav1.ConditionTrue}}
+		}
+
+		instance, err := getClusterInstance(inventory, connectionServiceID)
+		if err != nil {
+			if synced && connectionServiceID == serviceID {
+				t.Errorf("the synced database service %q is not found: %v", serviceID, err)
+			}
+			return
+		}
+		if !synced || instance.ServiceID != connectionServiceID {
+			t.Errorf("the database service %+v is returned for %q", instance, connectionServiceID)
+		}
+
+		cm := &corev1.ConfigMap{}
+		setConfigMap(cm, instance)
+		for _, key := range []string{"type", "provider", "host", "port", "database", "sslmode"} {
+			if len(cm.Data[key]) == 0 {
+				t.Errorf("the connection info has no %v", key)
+			}
+		}
+	})
+}
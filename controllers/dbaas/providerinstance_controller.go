@@ -19,16 +19,23 @@ package dbaas
 import (
 	"context"
 	errors1 "errors"
+	"fmt"
 	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
 	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
 	"k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
 )
@@ -37,11 +44,14 @@ import (
 type ProviderInstanceReconciler struct {
 	testutil.DBaaSProviderService
 	Scheme *runtime.Scheme
+	// Options are the concurrency and the rate limiter of the controller
+	Options ControllerOptions
 }
 
 //+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerinstances,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerinstances/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerinstances/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -56,7 +66,6 @@ func (r *ProviderInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	logger := log.FromContext(ctx, "ProviderInstance", req.NamespacedName)
 
 	var instance v1beta1.ProviderInstance
-	var cluster *testutil.Cluster
 
 	if err := r.Get(ctx, req.NamespacedName, &instance); err != nil {
 		if errors.IsNotFound(err) {
@@ -66,12 +75,27 @@ func (r *ProviderInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		logger.Error(err, "Failed to fetch ProviderInstance for reconcile")
 		return ctrl.Result{}, err
 	}
+	original := instance.DeepCopy()
+
+	if len(instance.Status.InstanceID) > 0 {
+		trace.SpanFromContext(ctx).SetAttributes(testutil.ClusterIDKey.String(instance.Status.InstanceID))
+	}
+
+	if isReconcilePaused(&instance) {
+		logger.Info("ProviderInstance reconciliation is paused")
+		return ctrl.Result{}, nil
+	}
+
+	if !instance.DeletionTimestamp.IsZero() {
+		logger.Info("ProviderInstance is being deleted, the cluster is kept at the provider cloud")
+		return ctrl.Result{}, nil
+	}
 
 	instance.Status.Phase = dbaasv1beta1.InstancePhaseUnknown
 	inventory := v1beta1.ProviderInventory{}
 	if err := r.Get(ctx, client.ObjectKey{Namespace: instance.Spec.InventoryRef.Namespace, Name: instance.Spec.InventoryRef.Name}, &inventory); err != nil {
 		if errors.IsNotFound(err) {
-			statusErr := r.updateStatus(ctx, &instance, metav1.ConditionFalse, InventoryNotFound, err.Error())
+			statusErr := r.updateStatus(ctx, original, &instance, instanceConditionInventoryReady, metav1.ConditionFalse, InventoryNotFound, err.Error())
 			if statusErr != nil {
 				logger.Error(statusErr, "Error in updating instance status")
 				return ctrl.Result{Requeue: true}, statusErr
@@ -84,6 +108,27 @@ func (r *ProviderInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, err
 	}
 
+	if err := checkNamespaceAllowed(ctx, r.DBaaSProviderService, &inventory, instance.Namespace); err != nil {
+		if !errors1.Is(err, errNamespaceNotAllowed) {
+			logger.Error(err, "Failed to check the namespace against the ProviderInventory allowed namespaces")
+			return ctrl.Result{}, err
+		}
+		instance.Status.Phase = dbaasv1beta1.InstancePhaseFailed
+		statusErr := r.updateStatus(ctx, original, &instance, instanceConditionReadyType, metav1.ConditionFalse, NamespaceNotAllowed, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating instance status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Info("ProviderInstance namespace is not allowed to use the ProviderInventory", "inventory", instance.Spec.InventoryRef)
+		return ctrl.Result{}, nil
+	}
+
+	if apimeta.IsStatusConditionTrue(inventory.Status.Conditions, inventoryConditionTypeReady) {
+		setStatusCondition(&instance.Status.Conditions, &instance, instanceConditionInventoryReady, metav1.ConditionTrue, InventoryReady, "ProviderInventory is synced")
+	} else {
+		setStatusCondition(&instance.Status.Conditions, &instance, instanceConditionInventoryReady, metav1.ConditionFalse, InventoryNotReady, "ProviderInventory is not yet synced")
+	}
+
 	instance.Status.Phase = dbaasv1beta1.InstancePhasePending
 	logger.Info("Creating API client for provider cloud")
 	secretSelector := client.ObjectKey{
@@ -92,7 +137,7 @@ func (r *ProviderInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	}
 	cloudService, err := r.CreateCloudService(ctx, secretSelector)
 	if err != nil {
-		statusErr := r.updateStatus(ctx, &instance, metav1.ConditionFalse, BackendError, err.Error())
+		statusErr := r.updateStatus(ctx, original, &instance, instanceConditionCredentialsValid, metav1.ConditionFalse, AuthenticationError, err.Error())
 		if statusErr != nil {
 			logger.Error(statusErr, "Error in updating instance status")
 			return ctrl.Result{Requeue: true}, statusErr
@@ -100,13 +145,57 @@ func (r *ProviderInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		logger.Error(err, "Failed to create CloudClient")
 		return ctrl.Result{}, err
 	}
+	setStatusCondition(&instance.Status.Conditions, &instance, instanceConditionCredentialsValid, metav1.ConditionTrue, CredentialsOK, "Provider credentials are valid")
 
 	if len(instance.Status.InstanceID) == 0 {
+		if err := r.validateCloneSource(ctx, cloudService, inventory, &instance); err != nil {
+			if !errors1.Is(err, errInvalidCloneSource) {
+				statusErr := r.updateStatus(ctx, original, &instance, instanceConditionReadyType, metav1.ConditionFalse, BackendError, err.Error())
+				if statusErr != nil {
+					logger.Error(statusErr, "Error in updating instance status")
+					return ctrl.Result{Requeue: true}, statusErr
+				}
+				logger.Error(err, "Failed to list the backups of the clone source")
+				return ctrl.Result{}, err
+			}
+			instance.Status.Phase = dbaasv1beta1.InstancePhaseFailed
+			statusErr := r.updateStatus(ctx, original, &instance, instanceConditionReadyType, metav1.ConditionFalse, InputError, err.Error())
+			if statusErr != nil {
+				logger.Error(statusErr, "Error in updating instance status")
+				return ctrl.Result{Requeue: true}, statusErr
+			}
+			logger.Error(err, "Invalid clone source")
+			return ctrl.Result{}, nil
+		}
+		if err := checkInstanceQuota(ctx, r.DBaaSProviderService, &instance); err != nil {
+			if errors1.Is(err, errInvalidQuotaParameter) {
+				instance.Status.Phase = dbaasv1beta1.InstancePhaseFailed
+				statusErr := r.updateStatus(ctx, original, &instance, instanceConditionReadyType, metav1.ConditionFalse, InputError, err.Error())
+				if statusErr != nil {
+					logger.Error(statusErr, "Error in updating instance status")
+					return ctrl.Result{Requeue: true}, statusErr
+				}
+				logger.Error(err, "Invalid provisioning parameter")
+				return ctrl.Result{}, nil
+			}
+			if !errors1.Is(err, errQuotaExceeded) {
+				logger.Error(err, "Failed to check the ProviderQuotas of the namespace")
+				return ctrl.Result{}, err
+			}
+			instance.Status.Phase = dbaasv1beta1.InstancePhasePending
+			statusErr := r.updateStatus(ctx, original, &instance, instanceConditionReadyType, metav1.ConditionFalse, QuotaExceeded, err.Error())
+			if statusErr != nil {
+				logger.Error(statusErr, "Error in updating instance status")
+				return ctrl.Result{Requeue: true}, statusErr
+			}
+			logger.Info("ProviderInstance exceeds a ProviderQuota", "reason", err.Error())
+			return ctrl.Result{RequeueAfter: quotaRetryDelay}, nil
+		}
 		instance.Status.Phase = dbaasv1beta1.InstancePhaseCreating
 		logger.Info("Creating  cloud cluster")
-		cluster, err = r.CreateCluster(ctx, cloudService, &instance)
+		cluster, err := r.CreateCluster(ctx, cloudService, &instance)
 		if err != nil {
-			statusErr := r.updateStatus(ctx, &instance, metav1.ConditionFalse, BackendError, err.Error())
+			statusErr := r.updateStatus(ctx, original, &instance, instanceConditionReadyType, metav1.ConditionFalse, InstanceCreationFailed, err.Error())
 			if statusErr != nil {
 				logger.Error(statusErr, "Error in updating instance status")
 				return ctrl.Result{Requeue: true}, statusErr
@@ -114,19 +203,35 @@ func (r *ProviderInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Req
 			logger.Error(err, "Failed to create a cluster at provider cloud")
 			return ctrl.Result{}, err
 		}
-	} else {
-		if cluster, err = r.GetCluster(ctx, cloudService, instance.Status.InstanceID); err != nil {
-			statusErr := r.updateStatus(ctx, &instance, metav1.ConditionFalse, BackendError, err.Error())
+		if err := r.updateClusterDetails(cluster, &instance.Status); err != nil {
+			statusErr := r.updateStatus(ctx, original, &instance, instanceConditionReadyType, metav1.ConditionFalse, InstanceCreationFailed, err.Error())
 			if statusErr != nil {
 				logger.Error(statusErr, "Error in updating instance status")
 				return ctrl.Result{Requeue: true}, statusErr
 			}
-			logger.Error(err, "Failed to get a cluster at provider cloud")
+			logger.Error(err, "Could not update Instance status")
 			return ctrl.Result{}, err
 		}
+		statusErr := r.updateStatus(ctx, original, &instance, instanceConditionReadyType, metav1.ConditionFalse, InstanceCreating, "Cluster is being created")
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating instance status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		return ctrl.Result{RequeueAfter: DefaultRetryDelay}, nil
+	}
+
+	cluster, err := r.GetCluster(ctx, cloudService, instance.Status.InstanceID)
+	if err != nil {
+		statusErr := r.updateStatus(ctx, original, &instance, instanceConditionReadyType, metav1.ConditionFalse, BackendError, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating instance status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Error(err, "Failed to get a cluster at provider cloud")
+		return ctrl.Result{}, err
 	}
 	if err := r.updateClusterDetails(cluster, &instance.Status); err != nil {
-		statusErr := r.updateStatus(ctx, &instance, metav1.ConditionFalse, BackendError, err.Error())
+		statusErr := r.updateStatus(ctx, original, &instance, instanceConditionReadyType, metav1.ConditionFalse, BackendError, err.Error())
 		if statusErr != nil {
 			logger.Error(statusErr, "Error in updating instance status")
 			return ctrl.Result{Requeue: true}, statusErr
@@ -135,47 +240,244 @@ func (r *ProviderInstanceReconciler) Reconcile(ctx context.Context, req ctrl.Req
 
 	}
 
-	instance.Status.Phase = dbaasv1beta1.InstancePhaseReady
-	logger.Info("updating  cluster details")
-	if err := r.Status().Update(ctx, &instance); err != nil {
-		if errors.IsConflict(err) {
-			logger.Info("Instance modified, retry reconciling")
-			return ctrl.Result{Requeue: true}, nil
+	if cluster.State == testutil.CLUSTERSTATETYPE_CREATING {
+		instance.Status.Phase = dbaasv1beta1.InstancePhaseCreating
+		statusErr := r.updateStatus(ctx, original, &instance, instanceConditionReadyType, metav1.ConditionFalse, InstanceCreating, "Cluster is being created")
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating instance status")
+			return ctrl.Result{Requeue: true}, statusErr
 		}
-		statusErr := r.updateStatus(ctx, &instance, metav1.ConditionTrue, InstanceReady, err.Error())
+		return ctrl.Result{RequeueAfter: DefaultRetryDelay}, nil
+	}
+
+	window, err := getMaintenanceWindow(&inventory, &instance)
+	if err != nil {
+		statusErr := r.updateStatus(ctx, original, &instance, instanceConditionReadyType, metav1.ConditionFalse, InputError, err.Error())
 		if statusErr != nil {
 			logger.Error(statusErr, "Error in updating instance status")
 			return ctrl.Result{Requeue: true}, statusErr
 		}
+		logger.Error(err, "Invalid maintenance window")
+		return ctrl.Result{}, nil
+	}
+	now := time.Now()
+	inMaintenanceWindow := window == nil || window.isOpen(now)
+	var pendingOperations []string
+
+	suspend := instance.Spec.Suspend
+	suspended := cluster.State == testutil.CLUSTERSTATETYPE_SUSPENDED
+	if suspend && !suspended && !inMaintenanceWindow {
+		pendingOperations = append(pendingOperations, "Suspend")
+	} else if suspend != suspended {
+		instance.Status.Phase = dbaasv1beta1.InstancePhaseUpdating
+		if suspend {
+			logger.Info("Suspending cloud cluster")
+			cluster, err = r.SuspendCluster(ctx, cloudService, instance.Status.InstanceID)
+		} else {
+			logger.Info("Resuming cloud cluster")
+			cluster, err = r.ResumeCluster(ctx, cloudService, instance.Status.InstanceID)
+		}
+		if err != nil {
+			statusErr := r.updateStatus(ctx, original, &instance, instanceConditionReadyType, metav1.ConditionFalse, BackendError, err.Error())
+			if statusErr != nil {
+				logger.Error(statusErr, "Error in updating instance status")
+				return ctrl.Result{Requeue: true}, statusErr
+			}
+			logger.Error(err, "Failed to suspend or resume a cluster at provider cloud")
+			return ctrl.Result{}, err
+		}
+		instance.Status.InstanceInfo = testutil.PopulateInstanceInfo(cluster)
+		if err := patchStatus(ctx, r.DBaaSProviderService, original, &instance); err != nil {
+			logger.Error(err, "Error in updating instance status")
+			return ctrl.Result{Requeue: true}, err
+		}
+		return ctrl.Result{RequeueAfter: DefaultRetryDelay}, nil
+	}
+	setSuspendedCondition(&instance, suspended)
 
+	if version := instance.Spec.ProvisioningParameters[v1beta1.ProvisioningVersion]; len(version) > 0 {
+		upgrading, pendingUpgrade, err := r.reconcileVersion(ctx, cloudService, cluster, &instance, version, inMaintenanceWindow)
+		if err != nil {
+			statusErr := r.updateStatus(ctx, original, &instance, instanceConditionReadyType, metav1.ConditionFalse, BackendError, err.Error())
+			if statusErr != nil {
+				logger.Error(statusErr, "Error in updating instance status")
+				return ctrl.Result{Requeue: true}, statusErr
+			}
+			logger.Error(err, "Failed to upgrade a cluster at provider cloud")
+			return ctrl.Result{}, err
+		}
+		if upgrading {
+			instance.Status.Phase = dbaasv1beta1.InstancePhaseUpdating
+			if err := patchStatus(ctx, r.DBaaSProviderService, original, &instance); err != nil {
+				logger.Error(err, "Error in updating instance status")
+				return ctrl.Result{Requeue: true}, err
+			}
+			return ctrl.Result{RequeueAfter: DefaultRetryDelay}, nil
+		}
+		if len(pendingUpgrade) > 0 {
+			pendingOperations = append(pendingOperations, pendingUpgrade)
+		}
+	}
+
+	result := ctrl.Result{}
+	if window != nil {
+		nextOpen := window.nextOpen(now)
+		setMaintenanceCondition(&instance, pendingOperations, nextOpen)
+		if len(pendingOperations) > 0 {
+			logger.Info("Disruptive operations wait for the maintenance window", "operations", pendingOperations, "windowOpens", nextOpen)
+			result.RequeueAfter = nextOpen.Sub(now)
+		}
+	} else {
+		apimeta.RemoveStatusCondition(&instance.Status.Conditions, instanceConditionMaintenance)
 	}
 
-	return ctrl.Result{}, nil
+	instance.Status.Phase = dbaasv1beta1.InstancePhaseReady
+	logger.Info("updating  cluster details")
+	if err := r.updateStatus(ctx, original, &instance, instanceConditionReadyType, metav1.ConditionTrue, InstanceReady, "Cluster is ready"); err != nil {
+		logger.Error(err, "Error in updating instance status")
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	return result, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ProviderInstanceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(r.Options.controllerOptions()).
 		For(&v1beta1.ProviderInstance{}).
-		Complete(r)
+		Watches(&source.Kind{Type: &v1beta1.ProviderInventory{}}, handler.EnqueueRequestsFromMapFunc(inventoryInstancesMapFn(r.DBaaSProviderService)),
+			builder.WithPredicates(allowedNamespacesChanged())).
+		Watches(&source.Kind{Type: &v1beta1.ProviderQuota{}}, handler.EnqueueRequestsFromMapFunc(quotaRejectedInstancesMapFn(r.DBaaSProviderService))).
+		Complete(traceReconciles("ProviderInstance", r))
 }
 
-func (r *ProviderInstanceReconciler) updateStatus(ctx context.Context, conn *v1beta1.ProviderInstance,
+func (r *ProviderInstanceReconciler) updateStatus(ctx context.Context, original, instance *v1beta1.ProviderInstance, conditionType string,
 	status metav1.ConditionStatus, reason ConditionReason, msg string) error {
+	setStatusCondition(&instance.Status.Conditions, instance, conditionType, status, reason, msg)
+	return patchStatus(ctx, r.DBaaSProviderService, original, instance)
+}
 
-	curCondition := metav1.Condition{
-		Type:    connectionConditionReadyType,
-		Status:  status,
-		Reason:  string(reason),
-		Message: msg,
+// reconcileVersion upgrades the cluster to the desired version, or rolls back a running upgrade if the
+// desired version was changed back to the version of the cluster, and reports the progress in the
+// Upgraded condition. It returns true while an upgrade or a rollback is running, and the pending
+// upgrade if it waits for the maintenance window. Rollbacks do not wait for the maintenance window.
+func (r *ProviderInstanceReconciler) reconcileVersion(ctx context.Context, cloudService testutil.Service,
+	cluster *testutil.Cluster, instance *v1beta1.ProviderInstance, version string, inMaintenanceWindow bool) (bool, string, error) {
+	switch cluster.UpgradeStatus {
+	case testutil.UPGRADESTATUSTYPE_UPGRADE_RUNNING:
+		if version != cluster.Version {
+			setUpgradedCondition(instance, metav1.ConditionFalse, UpgradeInProgress,
+				fmt.Sprintf("Upgrading to version %v: %d%%", cluster.TargetVersion, cluster.UpgradeProgress))
+			return true, "", nil
+		}
+		rolledBack, err := r.RollbackClusterUpgrade(ctx, cloudService, cluster.Id)
+		if err != nil {
+			return false, "", err
+		}
+		instance.Status.InstanceInfo = testutil.PopulateInstanceInfo(rolledBack)
+		setUpgradedCondition(instance, metav1.ConditionFalse, UpgradeRollbackInProgress,
+			fmt.Sprintf("Rolling back the upgrade to version %v", rolledBack.TargetVersion))
+		return true, "", nil
+	case testutil.UPGRADESTATUSTYPE_ROLLBACK_RUNNING:
+		setUpgradedCondition(instance, metav1.ConditionFalse, UpgradeRollbackInProgress,
+			fmt.Sprintf("Rolling back the upgrade to version %v: %d%%", cluster.TargetVersion, cluster.UpgradeProgress))
+		return true, "", nil
+	}
+
+	if version == cluster.Version {
+		if cluster.UpgradeStatus == testutil.UPGRADESTATUSTYPE_ROLLED_BACK {
+			setUpgradedCondition(instance, metav1.ConditionFalse, UpgradeRolledBack,
+				fmt.Sprintf("The upgrade to version %v was rolled back, the cluster runs version %v", cluster.TargetVersion, cluster.Version))
+		} else {
+			setUpgradedCondition(instance, metav1.ConditionTrue, UpgradeCompleted,
+				fmt.Sprintf("The cluster runs version %v", cluster.Version))
+		}
+		return false, "", nil
+	}
+
+	// an invalid version is reported right away, instead of waiting for the maintenance window
+	versions, err := r.ListVersions(ctx, cloudService)
+	if err != nil {
+		return false, "", err
+	}
+	if err := testutil.ValidateVersionUpgrade(versions, cluster.Version, version); err != nil {
+		setUpgradedCondition(instance, metav1.ConditionFalse, InputError, err.Error())
+		return false, "", nil
+	}
+	if !inMaintenanceWindow {
+		return false, fmt.Sprintf("Upgrade to version %v", version), nil
+	}
+	upgraded, err := r.UpgradeCluster(ctx, cloudService, cluster.Id, version)
+	if err != nil {
+		return false, "", err
+	}
+	instance.Status.InstanceInfo = testutil.PopulateInstanceInfo(upgraded)
+	setUpgradedCondition(instance, metav1.ConditionFalse, UpgradeInProgress,
+		fmt.Sprintf("Upgrading from version %v to %v", cluster.Version, version))
+	return true, "", nil
+}
+
+func setUpgradedCondition(instance *v1beta1.ProviderInstance, status metav1.ConditionStatus, reason ConditionReason, msg string) {
+	setStatusCondition(&instance.Status.Conditions, instance, instanceConditionUpgraded, status, reason, msg)
+}
+
+// setMaintenanceCondition reports the disruptive operations waiting for the maintenance window.
+func setMaintenanceCondition(instance *v1beta1.ProviderInstance, pendingOperations []string, nextOpen time.Time) {
+	if len(pendingOperations) == 0 {
+		setStatusCondition(&instance.Status.Conditions, instance, instanceConditionMaintenance, metav1.ConditionFalse,
+			NoPendingMaintenance, "No operation waits for the maintenance window")
+		return
 	}
+	setStatusCondition(&instance.Status.Conditions, instance, instanceConditionMaintenance, metav1.ConditionTrue, MaintenanceWindowClosed,
+		fmt.Sprintf("%v waiting for the maintenance window opening at %v", strings.Join(pendingOperations, ", "), nextOpen.UTC().Format(time.RFC3339)))
+}
+
+// setSuspendedCondition reports whether the cluster of the instance is suspended at the provider cloud.
+// The DBaaS instance phases have no suspended phase, the phase of a suspended instance is Ready.
+func setSuspendedCondition(instance *v1beta1.ProviderInstance, suspended bool) {
+	if suspended {
+		setStatusCondition(&instance.Status.Conditions, instance, instanceConditionSuspended, metav1.ConditionTrue, InstanceSuspended, "Cluster is suspended")
+		return
+	}
+	setStatusCondition(&instance.Status.Conditions, instance, instanceConditionSuspended, metav1.ConditionFalse, InstanceRunning, "Cluster is running")
+}
+
+// errInvalidCloneSource is returned for clone source parameters the cluster cannot be created from.
+var errInvalidCloneSource = errors1.New("invalid clone source")
 
-	apimeta.SetStatusCondition(&conn.Status.Conditions, curCondition)
-	if err := r.Status().Update(ctx, conn); err != nil {
-		if errors.IsConflict(err) {
-			return nil
+// validateCloneSource checks the clone source parameters of the instance before the cluster is created:
+// the instance to clone from must be discovered by the same inventory, the backup to clone from must be
+// one of its completed backups, and the point in time to clone from cannot be in the future.
+// Invalid parameters are reported with errInvalidCloneSource, they cannot be fixed by retrying.
+func (r *ProviderInstanceReconciler) validateCloneSource(ctx context.Context, cloudService testutil.Service,
+	inventory v1beta1.ProviderInventory, instance *v1beta1.ProviderInstance) error {
+	source, err := testutil.GetCloneSource(instance)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidCloneSource, err)
+	}
+	if source == nil {
+		return nil
+	}
+	found := false
+	for _, databaseService := range inventory.Status.DatabaseServices {
+		if databaseService.ServiceID == source.ClusterId {
+			found = true
+			break
 		}
-		return err
+	}
+	if !found {
+		return fmt.Errorf("%w: source instance with id:%v not found in ProviderInventory %v",
+			errInvalidCloneSource, source.ClusterId, inventory.Name)
+	}
+	var backups []testutil.Backup
+	if len(source.BackupId) > 0 {
+		if backups, err = r.ListBackups(ctx, cloudService, source.ClusterId); err != nil {
+			return err
+		}
+	}
+	if err := testutil.ValidateCloneSource(source, backups, time.Now()); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidCloneSource, err)
 	}
 	return nil
 }
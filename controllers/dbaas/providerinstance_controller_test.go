@@ -0,0 +1,347 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+)
+
+// TestProviderInstanceStatusInSync checks that the status a reconcile observed is the status
+// stored when the reconcile returns, even if the instance changes before every status write.
+func TestProviderInstanceStatusInSync(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"},
+		Data: map[string][]byte{
+			"CredentialField1": []byte("field1"),
+			"CredentialField2": []byte("field2"),
+		},
+	}
+	inventory := &v1beta1.ProviderInventory{
+		ObjectMeta: metav1.ObjectMeta{Name: "inventory", Namespace: "default"},
+		Spec: dbaasv1beta1.DBaaSInventorySpec{
+			CredentialsRef: &dbaasv1beta1.LocalObjectReference{Name: secret.Name},
+		},
+		Status: dbaasv1beta1.DBaaSInventoryStatus{
+			Conditions: []metav1.Condition{{
+				Type:               inventoryConditionTypeReady,
+				Status:             metav1.ConditionTrue,
+				Reason:             string(InventorySyncOK),
+				LastTransitionTime: metav1.Now(),
+			}},
+		},
+	}
+	instance := &v1beta1.ProviderInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: "instance", Namespace: "default"},
+		Spec: v1beta1.ProviderInstanceSpec{DBaaSInstanceSpec: dbaasv1beta1.DBaaSInstanceSpec{
+			InventoryRef: dbaasv1beta1.NamespacedName{Name: inventory.Name, Namespace: inventory.Namespace},
+			ProvisioningParameters: map[dbaasv1beta1.ProvisioningParameterType]string{
+				dbaasv1beta1.ProvisioningName: "status-in-sync-test",
+			},
+		}},
+	}
+
+	c := &racingClient{Client: fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(secret, inventory, instance).Build()}
+	r := &ProviderInstanceReconciler{
+		DBaaSProviderService: &testutil.FakeProviderService{Client: c},
+		Scheme:               c.Scheme(),
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(instance)}
+
+	expectStatusInSync := func(g Gomega) *v1beta1.ProviderInstance {
+		stored := &v1beta1.ProviderInstance{}
+		g.Expect(c.Get(ctx, req.NamespacedName, stored)).To(Succeed())
+		g.Expect(c.written).NotTo(BeNil())
+		g.Expect(stored.Status).To(Equal(c.written.(*v1beta1.ProviderInstance).Status))
+		return stored
+	}
+
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	created := expectStatusInSync(g)
+	g.Expect(created.Status.InstanceID).NotTo(BeEmpty())
+	g.Expect(created.Status.Phase).To(Equal(dbaasv1beta1.InstancePhaseCreating))
+	g.Expect(apimeta.FindStatusCondition(created.Status.Conditions, instanceConditionReadyType).Reason).To(Equal(string(InstanceCreating)))
+
+	// the cluster is ready on a later reconcile
+	g.Eventually(func(g Gomega) {
+		_, err := r.Reconcile(ctx, req)
+		g.Expect(err).NotTo(HaveOccurred())
+		ready := expectStatusInSync(g)
+		g.Expect(ready.Status.Phase).To(Equal(dbaasv1beta1.InstancePhaseReady))
+		g.Expect(apimeta.IsStatusConditionTrue(ready.Status.Conditions, instanceConditionReadyType)).To(BeTrue())
+		g.Expect(apimeta.IsStatusConditionTrue(ready.Status.Conditions, instanceConditionCredentialsValid)).To(BeTrue())
+		g.Expect(apimeta.IsStatusConditionTrue(ready.Status.Conditions, instanceConditionInventoryReady)).To(BeTrue())
+	}).Should(Succeed())
+}
+
+// TestProviderInstanceRejectsInvalidCloneSource checks that clone source parameters the cluster cannot be
+// created from fail the instance before the cluster is created, instead of being retried.
+func TestProviderInstanceRejectsInvalidCloneSource(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		params map[dbaasv1beta1.ProvisioningParameterType]string
+	}{
+		{
+			name:   "unknown instance",
+			params: map[dbaasv1beta1.ProvisioningParameterType]string{v1beta1.ProvisioningSourceInstanceID: "unknown"},
+		},
+		{
+			name:   "unknown backup",
+			params: map[dbaasv1beta1.ProvisioningParameterType]string{v1beta1.ProvisioningSourceBackupID: "unknown"},
+		},
+		{
+			name:   "future point in time",
+			params: map[dbaasv1beta1.ProvisioningParameterType]string{v1beta1.ProvisioningSourcePointInTime: "2999-01-01T00:00:00Z"},
+		},
+		{
+			name:   "malformed point in time",
+			params: map[dbaasv1beta1.ProvisioningParameterType]string{v1beta1.ProvisioningSourcePointInTime: "yesterday"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cloudService, cluster, objects := newReadyInstance(g, t)
+			inventory := objects[1].(*v1beta1.ProviderInventory)
+			inventory.Status.DatabaseServices = []dbaasv1beta1.DatabaseService{{ServiceID: cluster.Id}}
+			clone := &v1beta1.ProviderInstance{
+				ObjectMeta: metav1.ObjectMeta{Name: "clone", Namespace: "default"},
+				Spec: v1beta1.ProviderInstanceSpec{DBaaSInstanceSpec: dbaasv1beta1.DBaaSInstanceSpec{
+					InventoryRef: dbaasv1beta1.NamespacedName{Name: inventory.Name, Namespace: inventory.Namespace},
+					ProvisioningParameters: map[dbaasv1beta1.ProvisioningParameterType]string{
+						dbaasv1beta1.ProvisioningName:        "clone",
+						v1beta1.ProvisioningSourceInstanceID: cluster.Id,
+					},
+				}},
+			}
+			for key, value := range tc.params {
+				clone.Spec.ProvisioningParameters[key] = value
+			}
+
+			before, _, err := cloudService.ListClusters(ctx)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(append(objects, clone)...).Build()
+			r := &ProviderInstanceReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+			result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(clone)})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result).To(Equal(ctrl.Result{}))
+			g.Expect(c.Get(ctx, client.ObjectKeyFromObject(clone), clone)).To(Succeed())
+			g.Expect(clone.Status.Phase).To(Equal(dbaasv1beta1.InstancePhaseFailed))
+			g.Expect(clone.Status.InstanceID).To(BeEmpty())
+			g.Expect(apimeta.FindStatusCondition(clone.Status.Conditions, instanceConditionReadyType).Reason).To(Equal(string(InputError)))
+			after, _, err := cloudService.ListClusters(ctx)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(after.Clusters).To(HaveLen(len(before.Clusters)))
+		})
+	}
+}
+
+// TestProviderInstanceSuspendAndResume checks that the cluster follows the suspend field of the instance.
+func TestProviderInstanceSuspendAndResume(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	cloudService, cluster, objects := newReadyInstance(g, t)
+	instance := objects[2].(*v1beta1.ProviderInstance)
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(objects...).Build()
+	r := &ProviderInstanceReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(instance)}
+	reconcileUntilSuspended := func(suspended metav1.ConditionStatus) {
+		g.Eventually(func(g Gomega) {
+			_, err := r.Reconcile(ctx, req)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(c.Get(ctx, req.NamespacedName, instance)).To(Succeed())
+			g.Expect(apimeta.FindStatusCondition(instance.Status.Conditions, instanceConditionSuspended)).
+				To(HaveField("Status", suspended))
+		}).Should(Succeed())
+	}
+
+	reconcileUntilSuspended(metav1.ConditionFalse)
+
+	instance.Spec.Suspend = true
+	g.Expect(c.Update(ctx, instance)).To(Succeed())
+	reconcileUntilSuspended(metav1.ConditionTrue)
+	suspended, _, err := cloudService.GetCluster(ctx, cluster.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(suspended.State).To(Equal(testutil.CLUSTERSTATETYPE_SUSPENDED))
+	g.Expect(instance.Status.Phase).To(Equal(dbaasv1beta1.InstancePhaseReady))
+
+	instance.Spec.Suspend = false
+	g.Expect(c.Update(ctx, instance)).To(Succeed())
+	reconcileUntilSuspended(metav1.ConditionFalse)
+	resumed, _, err := cloudService.GetCluster(ctx, cluster.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resumed.State).To(Equal(testutil.CLUSTERSTATETYPE_CREATED))
+}
+
+// TestProviderInstanceReconcilePaused checks that a paused instance is left as it is, and that
+// the instance is reconciled again once the pause annotation is removed.
+func TestProviderInstanceReconcilePaused(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	cloudService, cluster, objects := newReadyInstance(g, t)
+	instance := objects[2].(*v1beta1.ProviderInstance)
+	instance.Annotations = map[string]string{PauseReconcileAnnotation: "true"}
+	instance.Spec.Suspend = true
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(objects...).Build()
+	r := &ProviderInstanceReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(instance)}
+
+	result, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{}))
+	g.Expect(c.Get(ctx, req.NamespacedName, instance)).To(Succeed())
+	g.Expect(instance.Status.Conditions).To(BeEmpty())
+	paused, _, err := cloudService.GetCluster(ctx, cluster.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(paused.State).To(Equal(testutil.CLUSTERSTATETYPE_CREATED))
+
+	delete(instance.Annotations, PauseReconcileAnnotation)
+	g.Expect(c.Update(ctx, instance)).To(Succeed())
+	_, err = r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	suspended, _, err := cloudService.GetCluster(ctx, cluster.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(suspended.State).To(Equal(testutil.CLUSTERSTATETYPE_SUSPENDED))
+}
+
+// TestProviderInstanceRejectsInvalidVersion checks that an invalid version is reported right away,
+// instead of waiting for the maintenance window.
+func TestProviderInstanceRejectsInvalidVersion(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	_, cluster, objects := newReadyInstance(g, t)
+	instance := objects[2].(*v1beta1.ProviderInstance)
+	closedDay := time.Now().UTC().AddDate(0, 0, 3).Weekday().String()[:3]
+	instance.Annotations = map[string]string{MaintenanceWindowAnnotation: closedDay + " 00:00-01:00"}
+	instance.Spec.ProvisioningParameters = map[dbaasv1beta1.ProvisioningParameterType]string{v1beta1.ProvisioningVersion: "v14.9"}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(objects...).Build()
+	r := &ProviderInstanceReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(instance)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(instance), instance)).To(Succeed())
+	upgraded := apimeta.FindStatusCondition(instance.Status.Conditions, instanceConditionUpgraded)
+	g.Expect(upgraded).NotTo(BeNil())
+	g.Expect(upgraded.Reason).To(Equal(string(InputError)))
+	g.Expect(upgraded.Message).To(ContainSubstring(cluster.Version))
+	g.Expect(apimeta.IsStatusConditionTrue(instance.Status.Conditions, instanceConditionMaintenance)).To(BeFalse())
+}
+
+// TestProviderInstanceDeletionKeepsCluster checks that deleting an instance keeps its cluster at the provider cloud.
+func TestProviderInstanceDeletionKeepsCluster(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	cloudService, cluster, objects := newReadyInstance(g, t)
+	instance := objects[2].(*v1beta1.ProviderInstance)
+	instance.Finalizers = []string{"test/other"}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(objects...).Build()
+	r := &ProviderInstanceReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(instance)}
+
+	g.Expect(c.Delete(ctx, instance)).To(Succeed())
+	result, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{}))
+	g.Expect(c.Get(ctx, req.NamespacedName, instance)).To(Succeed())
+	g.Expect(instance.Finalizers).To(Equal([]string{"test/other"}))
+	g.Expect(instance.Status.Phase).To(Equal(dbaasv1beta1.InstancePhaseReady))
+	_, _, err = cloudService.GetCluster(ctx, cluster.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+var _ = Describe("ProviderInstance controller", func() {
+	ctx := context.Background()
+	var namespace string
+
+	BeforeEach(func() {
+		namespace = createNamespace(ctx)
+	})
+
+	It("creates the cluster, and keeps it when the instance is deleted", func() {
+		secret := createCredentials(ctx, namespace, validCredentials(namespace))
+		inventory := createInventory(ctx, namespace, secret.Name)
+		instance := createInstance(ctx, inventory, "instance-lifecycle-test")
+
+		Eventually(condition(ctx, instance, &instance.Status.Conditions, instanceConditionReadyType), timeout, interval).
+			Should(haveCondition(metav1.ConditionTrue, InstanceReady))
+		Expect(instance.Status.Phase).To(Equal(dbaasv1beta1.InstancePhaseReady))
+		Expect(instance.Status.InstanceID).NotTo(BeEmpty())
+		Expect(instance.Finalizers).To(BeEmpty())
+		Expect(apimeta.IsStatusConditionTrue(instance.Status.Conditions, instanceConditionCredentialsValid)).To(BeTrue())
+		cloudService := testutil.NewFakeAPIClientForTenant(namespace)
+		cluster, _, err := cloudService.GetCluster(ctx, instance.Status.InstanceID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.Name).To(Equal("instance-lifecycle-test"))
+
+		Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(instance), instance))
+		}, timeout, interval).Should(BeTrue())
+		_, _, err = cloudService.GetCluster(ctx, cluster.Id)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("reports a missing inventory", func() {
+		missing := &v1beta1.ProviderInventory{ObjectMeta: metav1.ObjectMeta{Name: "missing", Namespace: namespace}}
+		instance := createInstance(ctx, missing, "missing-inventory-test")
+
+		Eventually(condition(ctx, instance, &instance.Status.Conditions, instanceConditionInventoryReady), timeout, interval).
+			Should(haveCondition(metav1.ConditionFalse, InventoryNotFound))
+		Expect(instance.Status.InstanceID).To(BeEmpty())
+	})
+
+	It("reports invalid credentials", func() {
+		secret := createCredentials(ctx, namespace, map[string][]byte{"CredentialField1": []byte(namespace)})
+		inventory := createInventory(ctx, namespace, secret.Name)
+		instance := createInstance(ctx, inventory, "invalid-credentials-test")
+
+		Eventually(condition(ctx, instance, &instance.Status.Conditions, instanceConditionCredentialsValid), timeout, interval).
+			Should(haveCondition(metav1.ConditionFalse, AuthenticationError))
+		Expect(instance.Status.InstanceID).To(BeEmpty())
+	})
+
+	It("reports a cluster creation failure", func() {
+		secret := createCredentials(ctx, namespace, validCredentials(namespace))
+		inventory := createInventory(ctx, namespace, secret.Name)
+		instance := createInstance(ctx, inventory, "invalid-cluster-creation-request")
+
+		Eventually(condition(ctx, instance, &instance.Status.Conditions, instanceConditionReadyType), timeout, interval).
+			Should(haveCondition(metav1.ConditionFalse, InstanceCreationFailed))
+		Expect(instance.Status.InstanceID).To(BeEmpty())
+	})
+})
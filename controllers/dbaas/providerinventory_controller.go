@@ -31,14 +31,16 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/source"
-
-	apimeta "k8s.io/apimachinery/pkg/api/meta"
 )
 
 // ProviderInventoryReconciler reconciles a ProviderInventory object
 type ProviderInventoryReconciler struct {
 	testutil.DBaaSProviderService
 	Scheme *runtime.Scheme
+	// Options are the concurrency and the rate limiter of the controller
+	Options ControllerOptions
+	// Health records the syncs of the inventories, if it is set
+	Health *ProviderHealth
 }
 
 //+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerinventories,verbs=get;list;watch;create;update;patch;delete
@@ -62,11 +64,18 @@ func (r *ProviderInventoryReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		if apierrors.IsNotFound(err) {
 			// CR deleted since request queued, child objects getting GC'd, no requeue
 			logger.Info("ProviderInventory resource not found, may have been deleted")
+			r.Health.ForgetInventory(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 		logger.Error(err, "Failed to fetch ProviderInventory for reconcile")
 		return ctrl.Result{}, err
 	}
+	original := inventory.DeepCopy()
+
+	if isReconcilePaused(&inventory) {
+		logger.Info("ProviderInventory reconciliation is paused")
+		return ctrl.Result{}, nil
+	}
 
 	logger.Info("Creating API client for ProviderInventory cloud to get list of cloud clusters/database")
 	secretSelector := client.ObjectKey{
@@ -75,7 +84,8 @@ func (r *ProviderInventoryReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	}
 	cloudService, err := r.CreateCloudService(ctx, secretSelector)
 	if err != nil {
-		if errUpdate := r.updateInventoryStatus(ctx, inventory, metav1.ConditionFalse, InputError, string(InputError), logger); errUpdate != nil {
+		r.Health.RecordInventorySync(req.NamespacedName, InputError, 0, err)
+		if errUpdate := r.updateInventoryStatus(ctx, original, inventory, metav1.ConditionFalse, InputError, string(InputError), logger); errUpdate != nil {
 			logger.Error(errUpdate, "Failed to update Inventory status")
 		}
 		logger.Error(err, "Failed to create CloudClient")
@@ -86,7 +96,8 @@ func (r *ProviderInventoryReconciler) Reconcile(ctx context.Context, req ctrl.Re
 
 	instanceLst, err := r.DiscoverClusters(ctx, cloudService)
 	if err != nil {
-		if errUpdate := r.updateInventoryStatus(ctx, inventory, metav1.ConditionFalse, BackendError, string(BackendError), logger); errUpdate != nil {
+		r.Health.RecordInventorySync(req.NamespacedName, BackendError, 0, err)
+		if errUpdate := r.updateInventoryStatus(ctx, original, inventory, metav1.ConditionFalse, BackendError, string(BackendError), logger); errUpdate != nil {
 			logger.Error(errUpdate, "Failed to update Inventory status")
 		}
 		logger.Error(err, "Failed to discover Clusters")
@@ -94,28 +105,19 @@ func (r *ProviderInventoryReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	}
 	logger.Info("Sync Instances of the Inventory")
 	inventory.Status.DatabaseServices = instanceLst
-	if err := r.updateInventoryStatus(ctx, inventory, metav1.ConditionTrue, InventorySyncOK, string(InventorySyncOK), logger); err != nil {
+	r.Health.RecordInventorySync(req.NamespacedName, InventorySyncOK, len(instanceLst), nil)
+	if err := r.updateInventoryStatus(ctx, original, inventory, metav1.ConditionTrue, InventorySyncOK, string(InventorySyncOK), logger); err != nil {
 		logger.Error(err, "Failed to update Inventory status")
 		return ctrl.Result{}, err
 	}
 	return ctrl.Result{}, nil
 }
 
-func (r *ProviderInventoryReconciler) updateInventoryStatus(ctx context.Context, inventory v1beta1.ProviderInventory,
-	status metav1.ConditionStatus, reason ConditionReason, reasonMsg string, logger logr.Logger) error {
+func (r *ProviderInventoryReconciler) updateInventoryStatus(ctx context.Context, original *v1beta1.ProviderInventory,
+	inventory v1beta1.ProviderInventory, status metav1.ConditionStatus, reason ConditionReason, reasonMsg string, logger logr.Logger) error {
 
-	curCondition := metav1.Condition{
-		Type:    inventoryConditionTypeReady,
-		Status:  status,
-		Reason:  string(reason),
-		Message: reasonMsg}
-
-	apimeta.SetStatusCondition(&inventory.Status.Conditions, curCondition)
-	if err := r.Status().Update(ctx, &inventory); err != nil {
-		if apierrors.IsConflict(err) {
-			logger.Info("Inventory modified, retry reconciling")
-			return nil
-		}
+	setStatusCondition(&inventory.Status.Conditions, &inventory, inventoryConditionTypeReady, status, reason, reasonMsg)
+	if err := patchStatus(ctx, r.DBaaSProviderService, original, &inventory); err != nil {
 		logger.Error(err, fmt.Sprintf("Could not update Inventory status:%v", inventory.Name))
 		return err
 	}
@@ -138,7 +140,8 @@ func (r *ProviderInventoryReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	})
 
 	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(r.Options.controllerOptions()).
 		For(&v1beta1.ProviderInventory{}).
 		Watches(&source.Kind{Type: &v1beta1.ProviderInstance{}}, handler.EnqueueRequestsFromMapFunc(instanceMapFn)).
-		Complete(r)
+		Complete(traceReconciles("ProviderInventory", r))
 }
@@ -0,0 +1,78 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("ProviderInventory controller", func() {
+	ctx := context.Background()
+	var namespace string
+
+	BeforeEach(func() {
+		namespace = createNamespace(ctx)
+	})
+
+	It("discovers the clusters of the provider account", func() {
+		secret := createCredentials(ctx, namespace, validCredentials(namespace))
+		inventory := createInventory(ctx, namespace, secret.Name)
+
+		Eventually(condition(ctx, inventory, &inventory.Status.Conditions, inventoryConditionTypeReady), timeout, interval).
+			Should(haveCondition(metav1.ConditionTrue, InventorySyncOK))
+		Expect(inventory.Status.DatabaseServices).To(ContainElement(HaveField("ServiceID", "a-cluster-instance-1-id")))
+	})
+
+	It("reports credentials without all the fields", func() {
+		secret := createCredentials(ctx, namespace, map[string][]byte{"CredentialField1": []byte(namespace)})
+		inventory := createInventory(ctx, namespace, secret.Name)
+
+		Eventually(condition(ctx, inventory, &inventory.Status.Conditions, inventoryConditionTypeReady), timeout, interval).
+			Should(haveCondition(metav1.ConditionFalse, InputError))
+		Expect(inventory.Status.DatabaseServices).To(BeEmpty())
+	})
+
+	It("reports missing credentials", func() {
+		inventory := createInventory(ctx, namespace, "missing-credentials")
+
+		Eventually(condition(ctx, inventory, &inventory.Status.Conditions, inventoryConditionTypeReady), timeout, interval).
+			Should(haveCondition(metav1.ConditionFalse, InputError))
+	})
+
+	It("discovers the clusters of the instances created with the inventory", func() {
+		secret := createCredentials(ctx, namespace, validCredentials(namespace))
+		inventory := createInventory(ctx, namespace, secret.Name)
+		Eventually(condition(ctx, inventory, &inventory.Status.Conditions, inventoryConditionTypeReady), timeout, interval).
+			Should(haveCondition(metav1.ConditionTrue, InventorySyncOK))
+
+		instance := createInstance(ctx, inventory, "inventory-discovery-test")
+		Eventually(func() string {
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(instance), instance)).To(Succeed())
+			return instance.Status.InstanceID
+		}, timeout, interval).ShouldNot(BeEmpty())
+		Eventually(func() []dbaasv1beta1.DatabaseService {
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(inventory), inventory)).To(Succeed())
+			return inventory.Status.DatabaseServices
+		}, timeout, interval).Should(ContainElement(HaveField("ServiceID", instance.Status.InstanceID)))
+	})
+})
@@ -0,0 +1,114 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"strings"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// ProviderQuotaReconciler reconciles a ProviderQuota object
+type ProviderQuotaReconciler struct {
+	testutil.DBaaSProviderService
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerquotas,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerquotas/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerquotas/finalizers,verbs=update
+
+// Reconcile reports the current usage of the namespace of the ProviderQuota in its status,
+// and whether the usage is within the limits of the quota.
+func (r *ProviderQuotaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx, "ProviderQuota", req.NamespacedName)
+
+	var quota v1beta1.ProviderQuota
+	if err := r.Get(ctx, req.NamespacedName, &quota); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("ProviderQuota resource not found, may have been deleted")
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to fetch ProviderQuota for reconcile")
+		return ctrl.Result{}, err
+	}
+	original := quota.DeepCopy()
+
+	if isReconcilePaused(&quota) {
+		logger.Info("ProviderQuota reconciliation is paused")
+		return ctrl.Result{}, nil
+	}
+
+	used, err := namespaceUsage(ctx, r.DBaaSProviderService, quota.Namespace)
+	if err != nil {
+		logger.Error(err, "Failed to compute the usage of the namespace")
+		return ctrl.Result{}, err
+	}
+	quota.Status.Used = used
+
+	if exceeded := exceededLimits(quota.Spec.Hard, used); len(exceeded) != 0 {
+		if err := r.updateStatus(ctx, original, &quota, metav1.ConditionFalse, QuotaExceeded, strings.Join(exceeded, ", ")); err != nil {
+			logger.Error(err, "Error in updating quota status")
+			return ctrl.Result{Requeue: true}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if err := r.updateStatus(ctx, original, &quota, metav1.ConditionTrue, QuotaOK, "Usage is within the quota limits"); err != nil {
+		logger.Error(err, "Error in updating quota status")
+		return ctrl.Result{Requeue: true}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ProviderQuotaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// any instance or connection change can change the usage of the quotas of its namespace
+	namespaceQuotasMapFn := handler.MapFunc(func(a client.Object) []ctrl.Request {
+		var quotaList v1beta1.ProviderQuotaList
+		if err := r.List(context.Background(), &quotaList, client.InNamespace(a.GetNamespace())); err != nil {
+			return nil
+		}
+		requests := make([]ctrl.Request, 0, len(quotaList.Items))
+		for _, quota := range quotaList.Items {
+			requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Name: quota.Name, Namespace: quota.Namespace}})
+		}
+		return requests
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1beta1.ProviderQuota{}).
+		Watches(&source.Kind{Type: &v1beta1.ProviderInstance{}}, handler.EnqueueRequestsFromMapFunc(namespaceQuotasMapFn)).
+		Watches(&source.Kind{Type: &v1beta1.ProviderConnection{}}, handler.EnqueueRequestsFromMapFunc(namespaceQuotasMapFn)).
+		Complete(traceReconciles("ProviderQuota", r))
+}
+
+func (r *ProviderQuotaReconciler) updateStatus(ctx context.Context, original, quota *v1beta1.ProviderQuota,
+	status metav1.ConditionStatus, reason ConditionReason, msg string) error {
+	setStatusCondition(&quota.Status.Conditions, quota, quotaConditionType, status, reason, msg)
+	return patchStatus(ctx, r.DBaaSProviderService, original, quota)
+}
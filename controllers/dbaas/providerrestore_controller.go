@@ -0,0 +1,226 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ProviderRestoreReconciler reconciles a ProviderRestore object
+type ProviderRestoreReconciler struct {
+	testutil.DBaaSProviderService
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerrestores,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerrestores/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=dbaas.redhat.com,resources=providerrestores/finalizers,verbs=update
+
+// Reconcile restores a completed ProviderBackup to a new cluster at the provider cloud
+// and reports the restore progress in the status, until the new cluster is created. The new
+// cluster is discovered by the inventory like any other cluster, and is left in place when
+// the ProviderRestore is deleted.
+func (r *ProviderRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx, "ProviderRestore", req.NamespacedName)
+
+	var restore v1beta1.ProviderRestore
+	if err := r.Get(ctx, req.NamespacedName, &restore); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("ProviderRestore resource not found, may have been deleted")
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Failed to fetch ProviderRestore for reconcile")
+		return ctrl.Result{}, err
+	}
+	original := restore.DeepCopy()
+
+	if isReconcilePaused(&restore) {
+		logger.Info("ProviderRestore reconciliation is paused")
+		return ctrl.Result{}, nil
+	}
+
+	if restore.Status.Phase == v1beta1.RestorePhaseCompleted || restore.Status.Phase == v1beta1.RestorePhaseFailed {
+		return ctrl.Result{}, nil
+	}
+
+	backupKey, err := localObjectKey(restore.Spec.BackupRef, restore.Namespace)
+	if err != nil {
+		restore.Status.Phase = v1beta1.RestorePhaseFailed
+		statusErr := r.updateStatus(ctx, original, &restore, metav1.ConditionFalse, InputError, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating restore status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Error(err, "Invalid ProviderBackup reference")
+		return ctrl.Result{}, nil
+	}
+	backup := v1beta1.ProviderBackup{}
+	if err := r.Get(ctx, backupKey, &backup); err != nil {
+		if apierrors.IsNotFound(err) {
+			restore.Status.Phase = v1beta1.RestorePhasePending
+			statusErr := r.updateStatus(ctx, original, &restore, metav1.ConditionFalse, BackupNotFound, err.Error())
+			if statusErr != nil {
+				logger.Error(statusErr, "Error in updating restore status")
+				return ctrl.Result{Requeue: true}, statusErr
+			}
+			logger.Info("backup resource not found, has been deleted")
+			return ctrl.Result{}, err
+		}
+		logger.Error(err, "Failed to fetch ProviderBackup")
+		return ctrl.Result{}, err
+	}
+
+	if backup.Status.Phase != v1beta1.BackupPhaseCompleted {
+		restore.Status.Phase = v1beta1.RestorePhasePending
+		statusErr := r.updateStatus(ctx, original, &restore, metav1.ConditionFalse, BackupNotReady, "ProviderBackup is not yet completed")
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating restore status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Info("backup is not completed, waiting before restoring")
+		return ctrl.Result{RequeueAfter: DefaultRetryDelay}, nil
+	}
+
+	instanceKey, err := localObjectKey(backup.Spec.InstanceRef, backup.Namespace)
+	if err != nil {
+		restore.Status.Phase = v1beta1.RestorePhaseFailed
+		statusErr := r.updateStatus(ctx, original, &restore, metav1.ConditionFalse, InputError, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating restore status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Error(err, "Invalid ProviderInstance reference of the ProviderBackup")
+		return ctrl.Result{}, nil
+	}
+	_, cloudService, err := getInstanceCloudService(ctx, r.DBaaSProviderService, instanceKey)
+	if errors.Is(err, errNamespaceNotAllowed) {
+		restore.Status.Phase = v1beta1.RestorePhaseFailed
+		statusErr := r.updateStatus(ctx, original, &restore, metav1.ConditionFalse, NamespaceNotAllowed, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating restore status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Info("ProviderRestore namespace is not allowed to use the ProviderInventory of the instance", "reason", err.Error())
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		reason := BackendError
+		if apierrors.IsNotFound(err) {
+			reason = InstanceNotFound
+		}
+		statusErr := r.updateStatus(ctx, original, &restore, metav1.ConditionFalse, reason, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating restore status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Error(err, "Failed to create CloudClient for the instance")
+		return ctrl.Result{}, err
+	}
+
+	if len(restore.Status.InstanceID) == 0 {
+		if restore.Status.StartTime == nil {
+			// the restore is recorded before the cluster is created, so that the cluster of a restore whose
+			// status was not written is found again instead of being reported as an existing cluster
+			now := metav1.Now()
+			restore.Status.StartTime = &now
+			restore.Status.Phase = v1beta1.RestorePhaseInProgress
+			statusErr := r.updateStatus(ctx, original, &restore, metav1.ConditionFalse, RestoreInProgress, "Restoring the backup to a new cluster")
+			if statusErr != nil {
+				logger.Error(statusErr, "Error in updating restore status")
+				return ctrl.Result{Requeue: true}, statusErr
+			}
+		}
+
+		logger.Info("Restoring backup to a new cluster at provider cloud")
+		cluster, err := r.RestoreBackup(ctx, cloudService, backup.Status.InstanceID, backup.Status.BackupID, restore.Spec.ClusterName)
+		if err != nil {
+			statusErr := r.updateStatus(ctx, original, &restore, metav1.ConditionFalse, BackendError, err.Error())
+			if statusErr != nil {
+				logger.Error(statusErr, "Error in updating restore status")
+				return ctrl.Result{Requeue: true}, statusErr
+			}
+			logger.Error(err, "Failed to restore a backup at provider cloud")
+			return ctrl.Result{}, err
+		}
+		if cluster.CreatedAt != nil && cluster.CreatedAt.Before(restore.Status.StartTime.Time) {
+			restore.Status.Phase = v1beta1.RestorePhaseFailed
+			msg := fmt.Sprintf("a cluster named %v existed before the restore", restore.Spec.ClusterName)
+			statusErr := r.updateStatus(ctx, original, &restore, metav1.ConditionFalse, InputError, msg)
+			if statusErr != nil {
+				logger.Error(statusErr, "Error in updating restore status")
+				return ctrl.Result{Requeue: true}, statusErr
+			}
+			logger.Info("the cluster name of the restore is already used", "clusterName", restore.Spec.ClusterName)
+			return ctrl.Result{}, nil
+		}
+		restore.Status.InstanceID = cluster.Id
+		restore.Status.Phase = v1beta1.RestorePhaseInProgress
+	}
+
+	cluster, err := r.GetCluster(ctx, cloudService, restore.Status.InstanceID)
+	if err != nil {
+		statusErr := r.updateStatus(ctx, original, &restore, metav1.ConditionFalse, RestoreInProgress, err.Error())
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating restore status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Info("restored cluster is not yet available at provider cloud")
+		return ctrl.Result{RequeueAfter: DefaultRetryDelay}, nil
+	}
+	if cluster.State != testutil.CLUSTERSTATETYPE_CREATED {
+		msg := fmt.Sprintf("Restored cluster is %v", cluster.State)
+		statusErr := r.updateStatus(ctx, original, &restore, metav1.ConditionFalse, RestoreInProgress, msg)
+		if statusErr != nil {
+			logger.Error(statusErr, "Error in updating restore status")
+			return ctrl.Result{Requeue: true}, statusErr
+		}
+		logger.Info("restored cluster is not yet created at provider cloud", "state", cluster.State)
+		return ctrl.Result{RequeueAfter: DefaultRetryDelay}, nil
+	}
+
+	logger.Info("restore completed")
+	now := metav1.Now()
+	restore.Status.CompletionTime = &now
+	restore.Status.Phase = v1beta1.RestorePhaseCompleted
+	if statusErr := r.updateStatus(ctx, original, &restore, metav1.ConditionTrue, RestoreCompleted, "Cluster restored from the backup"); statusErr != nil {
+		logger.Error(statusErr, "Error in updating restore status")
+		return ctrl.Result{Requeue: true}, statusErr
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ProviderRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1beta1.ProviderRestore{}).
+		Complete(traceReconciles("ProviderRestore", r))
+}
+
+func (r *ProviderRestoreReconciler) updateStatus(ctx context.Context, original, restore *v1beta1.ProviderRestore,
+	status metav1.ConditionStatus, reason ConditionReason, msg string) error {
+	setStatusCondition(&restore.Status.Conditions, restore, restoreConditionReadyType, status, reason, msg)
+	return patchStatus(ctx, r.DBaaSProviderService, original, restore)
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	. "github.com/onsi/gomega"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+)
+
+// newCompletedBackup returns a ProviderBackup of the instance of newReadyInstance with a completed backup at the fake provider.
+func newCompletedBackup(g *WithT, cloudService *testutil.FakeAPIClient, cluster *testutil.Cluster) *v1beta1.ProviderBackup {
+	ctx := context.Background()
+	created, _, err := cloudService.CreateBackup(ctx, cluster.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Eventually(func() testutil.BackupStateType {
+		b, _, err := cloudService.GetBackup(ctx, cluster.Id, created.Id)
+		g.Expect(err).NotTo(HaveOccurred())
+		return b.State
+	}).Should(Equal(testutil.BACKUPSTATETYPE_COMPLETED))
+
+	backup := newBackup(dbaasv1beta1.NamespacedName{Name: "instance"})
+	backup.Status = v1beta1.ProviderBackupStatus{
+		Phase:      v1beta1.BackupPhaseCompleted,
+		BackupID:   created.Id,
+		InstanceID: cluster.Id,
+	}
+	return backup
+}
+
+func newRestore(clusterName string) *v1beta1.ProviderRestore {
+	return &v1beta1.ProviderRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: "default"},
+		Spec: v1beta1.ProviderRestoreSpec{
+			BackupRef:   dbaasv1beta1.NamespacedName{Name: "backup"},
+			ClusterName: clusterName,
+		},
+	}
+}
+
+func reconcileRestore(g *WithT, c client.Client, r *ProviderRestoreReconciler, restore *v1beta1.ProviderRestore) ctrl.Result {
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(restore)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Get(context.Background(), client.ObjectKeyFromObject(restore), restore)).To(Succeed())
+	return result
+}
+
+func restoreReason(restore *v1beta1.ProviderRestore) string {
+	condition := apimeta.FindStatusCondition(restore.Status.Conditions, restoreConditionReadyType)
+	if condition == nil {
+		return ""
+	}
+	return condition.Reason
+}
+
+func TestProviderRestoreLifecycle(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	cloudService, cluster, objects := newReadyInstance(g, t)
+	backup := newCompletedBackup(g, cloudService, cluster)
+	backup.Status.Phase = v1beta1.BackupPhaseInProgress
+	restore := newRestore("restored")
+
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(append(objects, backup, restore)...).Build()
+	r := &ProviderRestoreReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+
+	// the restore waits for the backup
+	result := reconcileRestore(g, c, r, restore)
+	g.Expect(restore.Status.Phase).To(Equal(v1beta1.RestorePhasePending))
+	g.Expect(restoreReason(restore)).To(Equal(string(BackupNotReady)))
+	g.Expect(result.RequeueAfter).To(Equal(DefaultRetryDelay))
+
+	// the restore is in progress while the new cluster is being created
+	backup.Status.Phase = v1beta1.BackupPhaseCompleted
+	g.Expect(c.Status().Update(ctx, backup)).To(Succeed())
+	cloudService.SetProvisioningDelay(time.Hour)
+	result = reconcileRestore(g, c, r, restore)
+	g.Expect(restore.Status.Phase).To(Equal(v1beta1.RestorePhaseInProgress))
+	g.Expect(restore.Status.InstanceID).NotTo(BeEmpty())
+	g.Expect(restore.Status.StartTime).NotTo(BeNil())
+	g.Expect(restoreReason(restore)).To(Equal(string(RestoreInProgress)))
+	g.Expect(result.RequeueAfter).To(Equal(DefaultRetryDelay))
+
+	cloudService.SetProvisioningDelay(0)
+	reconcileRestore(g, c, r, restore)
+	g.Expect(restore.Status.Phase).To(Equal(v1beta1.RestorePhaseCompleted))
+	g.Expect(restore.Status.CompletionTime).NotTo(BeNil())
+	g.Expect(restoreReason(restore)).To(Equal(string(RestoreCompleted)))
+
+	// the restored cluster is left in place when the restore is deleted
+	g.Expect(c.Delete(ctx, restore)).To(Succeed())
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(restore)})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, _, err = cloudService.GetCluster(ctx, restore.Status.InstanceID)
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestProviderRestoreFailed(t *testing.T) {
+	g := NewWithT(t)
+	existingTime := time.Now().Add(-time.Hour)
+	testutil.RestoreTenants(map[string]testutil.TenantState{t.Name(): {Clusters: []testutil.Cluster{{
+		Id:        "a-cluster-instance-id-existing",
+		Name:      "existing",
+		State:     testutil.CLUSTERSTATETYPE_CREATED,
+		CreatedAt: &existingTime,
+	}}}})
+	cloudService, cluster, objects := newReadyInstance(g, t)
+	backup := newCompletedBackup(g, cloudService, cluster)
+
+	// a cluster that existed before the restore is not taken for the restored cluster
+	restore := newRestore("existing")
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(append(objects, backup, restore)...).Build()
+	r := &ProviderRestoreReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+	result := reconcileRestore(g, c, r, restore)
+	g.Expect(restore.Status.Phase).To(Equal(v1beta1.RestorePhaseFailed))
+	g.Expect(restore.Status.InstanceID).To(BeEmpty())
+	g.Expect(restoreReason(restore)).To(Equal(string(InputError)))
+	g.Expect(result).To(Equal(ctrl.Result{}))
+
+	// the backups of other namespaces cannot be restored
+	restore = newRestore("restored")
+	restore.Spec.BackupRef.Namespace = "other"
+	c = fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(append(objects, backup, restore)...).Build()
+	r = &ProviderRestoreReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+	reconcileRestore(g, c, r, restore)
+	g.Expect(restore.Status.Phase).To(Equal(v1beta1.RestorePhaseFailed))
+	g.Expect(restoreReason(restore)).To(Equal(string(InputError)))
+}
+
+// TestProviderRestoreAdoptsRequestedCluster checks that the cluster of a restore requested by a reconcile
+// that could not write the cluster ID to the status is taken for the restored cluster.
+func TestProviderRestoreAdoptsRequestedCluster(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	cloudService, cluster, objects := newReadyInstance(g, t)
+	backup := newCompletedBackup(g, cloudService, cluster)
+	requested := metav1.NewTime(time.Now().Add(-time.Minute))
+	restore := newRestore("restored")
+	restore.Status = v1beta1.ProviderRestoreStatus{Phase: v1beta1.RestorePhaseInProgress, StartTime: &requested}
+	restored, _, err := cloudService.RestoreBackup(ctx, &testutil.RestoreBackupRequest{ClusterId: cluster.Id, BackupId: backup.Status.BackupID, Name: "restored"})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(append(objects, backup, restore)...).Build()
+	r := &ProviderRestoreReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+	reconcileRestore(g, c, r, restore)
+	g.Expect(restore.Status.Phase).To(Equal(v1beta1.RestorePhaseCompleted))
+	g.Expect(restore.Status.InstanceID).To(Equal(restored.Id))
+}
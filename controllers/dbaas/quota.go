@@ -0,0 +1,251 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+)
+
+// errQuotaExceeded is returned when an instance or a connection is not allowed by a ProviderQuota of its namespace.
+var errQuotaExceeded = errors.New("rejected by ProviderQuota")
+
+// errInvalidQuotaParameter is returned when a provisioning parameter counted by the quotas is not a non-negative integer.
+var errInvalidQuotaParameter = errors.New("invalid provisioning parameter")
+
+// quotaRetryDelay is the delay before an instance or a connection rejected by a ProviderQuota is checked again,
+// besides the checks triggered by the changes of the quotas of its namespace.
+const quotaRetryDelay = time.Minute
+
+// instanceUsage returns the amounts the provisioning parameters of the instance count against a quota.
+func instanceUsage(instance *v1beta1.ProviderInstance) (nodes, storageGib, spendLimit int64, err error) {
+	params := instance.Spec.ProvisioningParameters
+	if nodes, err = parseQuotaParameter(params, dbaasv1beta1.ProvisioningNodes); err != nil {
+		return
+	}
+	if storageGib, err = parseQuotaParameter(params, dbaasv1beta1.ProvisioningStorageGib); err != nil {
+		return
+	}
+	spendLimit, err = parseQuotaParameter(params, dbaasv1beta1.ProvisioningSpendLimit)
+	return
+}
+
+func parseQuotaParameter(params map[dbaasv1beta1.ProvisioningParameterType]string, param dbaasv1beta1.ProvisioningParameterType) (int64, error) {
+	value, ok := params[param]
+	if !ok || len(value) == 0 {
+		return 0, nil
+	}
+	amount, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || amount < 0 {
+		return 0, fmt.Errorf("%w: the %v provisioning parameter %q is not a non-negative integer", errInvalidQuotaParameter, param, value)
+	}
+	return amount, nil
+}
+
+// countsAgainstQuota reports whether an instance counts against the quotas of its namespace: the provisioned
+// instances, and the instances that were accepted but are not provisioned yet.
+func countsAgainstQuota(instance *v1beta1.ProviderInstance) bool {
+	if len(instance.Status.InstanceID) != 0 {
+		return true
+	}
+	if instance.DeletionTimestamp != nil || instance.Status.Phase == dbaasv1beta1.InstancePhaseFailed {
+		return false
+	}
+	cond := apimeta.FindStatusCondition(instance.Status.Conditions, instanceConditionReadyType)
+	return cond == nil || cond.Reason != string(QuotaExceeded)
+}
+
+// queuedBefore reports whether the instance a was created before the instance b, the names break the ties.
+func queuedBefore(a, b *v1beta1.ProviderInstance) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.Name < b.Name
+}
+
+// namespaceUsage returns the usage counted by the quotas of the namespace: the instances that count against
+// the quotas, the amounts of their provisioning parameters, and the ready connections.
+func namespaceUsage(ctx context.Context, c client.Client, namespace string) (v1beta1.ProviderQuotaResources, error) {
+	return countUsage(ctx, c, namespace, countsAgainstQuota)
+}
+
+// countUsage returns the usage of the namespace, counting the instances selected by counted.
+func countUsage(ctx context.Context, c client.Client, namespace string, counted func(instance *v1beta1.ProviderInstance) bool) (v1beta1.ProviderQuotaResources, error) {
+	var instances, connections, nodes, storageGib, spendLimit int64
+
+	var instanceList v1beta1.ProviderInstanceList
+	if err := c.List(ctx, &instanceList, client.InNamespace(namespace)); err != nil {
+		return v1beta1.ProviderQuotaResources{}, err
+	}
+	for i := range instanceList.Items {
+		if !counted(&instanceList.Items[i]) {
+			continue
+		}
+		// the parameters of an accepted instance passed the quota check, so they cannot be invalid
+		n, s, l, _ := instanceUsage(&instanceList.Items[i])
+		instances++
+		nodes += n
+		storageGib += s
+		spendLimit += l
+	}
+
+	var connectionList v1beta1.ProviderConnectionList
+	if err := c.List(ctx, &connectionList, client.InNamespace(namespace)); err != nil {
+		return v1beta1.ProviderQuotaResources{}, err
+	}
+	for i := range connectionList.Items {
+		if apimeta.IsStatusConditionTrue(connectionList.Items[i].Status.Conditions, connectionConditionReadyType) {
+			connections++
+		}
+	}
+
+	return v1beta1.ProviderQuotaResources{
+		Instances:   &instances,
+		Connections: &connections,
+		Nodes:       &nodes,
+		StorageGib:  &storageGib,
+		SpendLimit:  &spendLimit,
+	}, nil
+}
+
+// checkInstanceQuota returns an error wrapping errQuotaExceeded if the instance, with its current provisioning
+// parameters, exceeds a ProviderQuota of its namespace, or wrapping errInvalidQuotaParameter if one of the parameters
+// counted by the quotas is invalid, whether the namespace has quotas or not. An instance that is not provisioned yet is queued behind
+// the accepted instances created before it, so the instances created at the same time cannot all be accepted;
+// a new or a provisioned instance is checked against all the accepted instances.
+func checkInstanceQuota(ctx context.Context, c client.Client, instance *v1beta1.ProviderInstance) error {
+	nodes, storageGib, spendLimit, err := instanceUsage(instance)
+	if err != nil {
+		return err
+	}
+	queued := len(instance.Status.InstanceID) == 0 && !instance.CreationTimestamp.IsZero()
+	counted := func(other *v1beta1.ProviderInstance) bool {
+		if other.Name == instance.Name || !countsAgainstQuota(other) {
+			return false
+		}
+		return !queued || len(other.Status.InstanceID) != 0 || queuedBefore(other, instance)
+	}
+	return checkQuotas(ctx, c, instance.Namespace, counted, func(used *v1beta1.ProviderQuotaResources) error {
+		*used.Instances++
+		*used.Nodes += nodes
+		*used.StorageGib += storageGib
+		*used.SpendLimit += spendLimit
+		return nil
+	})
+}
+
+// checkConnectionQuota returns an error wrapping errQuotaExceeded if making the connection ready
+// exceeds a ProviderQuota of its namespace.
+func checkConnectionQuota(ctx context.Context, c client.Client, connection *v1beta1.ProviderConnection) error {
+	return checkQuotas(ctx, c, connection.Namespace, countsAgainstQuota, func(used *v1beta1.ProviderQuotaResources) error {
+		if !apimeta.IsStatusConditionTrue(connection.Status.Conditions, connectionConditionReadyType) {
+			*used.Connections++
+		}
+		return nil
+	})
+}
+
+// checkQuotas adds the usage of an object to the usage of the namespace and compares the sum with the quotas of the namespace.
+func checkQuotas(ctx context.Context, c client.Client, namespace string, counted func(instance *v1beta1.ProviderInstance) bool,
+	addUsage func(used *v1beta1.ProviderQuotaResources) error) error {
+	var quotaList v1beta1.ProviderQuotaList
+	if err := c.List(ctx, &quotaList, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	if len(quotaList.Items) == 0 {
+		return nil
+	}
+
+	used, err := countUsage(ctx, c, namespace, counted)
+	if err != nil {
+		return err
+	}
+	if err := addUsage(&used); err != nil {
+		return err
+	}
+	for i := range quotaList.Items {
+		if exceeded := exceededLimits(quotaList.Items[i].Spec.Hard, used); len(exceeded) != 0 {
+			return fmt.Errorf("%w %v: %v", errQuotaExceeded, quotaList.Items[i].Name, strings.Join(exceeded, ", "))
+		}
+	}
+	return nil
+}
+
+// exceededLimits describes the limits of hard that used is above.
+func exceededLimits(hard, used v1beta1.ProviderQuotaResources) []string {
+	var exceeded []string
+	check := func(name string, limit, amount *int64) {
+		if limit != nil && amount != nil && *amount > *limit {
+			exceeded = append(exceeded, fmt.Sprintf("%v %d exceeds the limit %d", name, *amount, *limit))
+		}
+	}
+	check("instances", hard.Instances, used.Instances)
+	check("connections", hard.Connections, used.Connections)
+	check("nodes", hard.Nodes, used.Nodes)
+	check("storageGib", hard.StorageGib, used.StorageGib)
+	check("spendLimit", hard.SpendLimit, used.SpendLimit)
+	return exceeded
+}
+
+// quotaRejectedInstancesMapFn maps a quota to the requests of the instances of its namespace rejected by a quota,
+// so that they are checked again when the limits or the usage of the quota change.
+func quotaRejectedInstancesMapFn(c client.Client) handler.MapFunc {
+	return func(a client.Object) []ctrl.Request {
+		var instanceList v1beta1.ProviderInstanceList
+		if err := c.List(context.Background(), &instanceList, client.InNamespace(a.GetNamespace())); err != nil {
+			return nil
+		}
+		var requests []ctrl.Request
+		for _, instance := range instanceList.Items {
+			if cond := apimeta.FindStatusCondition(instance.Status.Conditions, instanceConditionReadyType); cond != nil && cond.Reason == string(QuotaExceeded) {
+				requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}})
+			}
+		}
+		return requests
+	}
+}
+
+// quotaRejectedConnectionsMapFn maps a quota to the requests of the connections of its namespace rejected by a quota,
+// so that they are checked again when the limits or the usage of the quota change.
+func quotaRejectedConnectionsMapFn(c client.Client) handler.MapFunc {
+	return func(a client.Object) []ctrl.Request {
+		var connectionList v1beta1.ProviderConnectionList
+		if err := c.List(context.Background(), &connectionList, client.InNamespace(a.GetNamespace())); err != nil {
+			return nil
+		}
+		var requests []ctrl.Request
+		for _, connection := range connectionList.Items {
+			if cond := apimeta.FindStatusCondition(connection.Status.Conditions, connectionConditionReadyType); cond != nil && cond.Reason == string(QuotaExceeded) {
+				requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Name: connection.Name, Namespace: connection.Namespace}})
+			}
+		}
+		return requests
+	}
+}
@@ -0,0 +1,290 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	. "github.com/onsi/gomega"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+)
+
+var quotaEpoch = time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+// newQuotaInstance returns an instance of the default namespace created age after quotaEpoch, with nodes
+// as its nodes provisioning parameter, provisioned when instanceID is set.
+func newQuotaInstance(name string, age time.Duration, nodes, instanceID string) *v1beta1.ProviderInstance {
+	return &v1beta1.ProviderInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", CreationTimestamp: metav1.NewTime(quotaEpoch.Add(age))},
+		Spec: v1beta1.ProviderInstanceSpec{DBaaSInstanceSpec: dbaasv1beta1.DBaaSInstanceSpec{
+			ProvisioningParameters: map[dbaasv1beta1.ProvisioningParameterType]string{dbaasv1beta1.ProvisioningNodes: nodes},
+		}},
+		Status: dbaasv1beta1.DBaaSInstanceStatus{InstanceID: instanceID},
+	}
+}
+
+func withReadyReason(instance *v1beta1.ProviderInstance, reason ConditionReason) *v1beta1.ProviderInstance {
+	instance.Status.Conditions = []metav1.Condition{{
+		Type:               instanceConditionReadyType,
+		Status:             metav1.ConditionFalse,
+		Reason:             string(reason),
+		LastTransitionTime: metav1.NewTime(quotaEpoch),
+	}}
+	return instance
+}
+
+func newQuota(instances, nodes int64) *v1beta1.ProviderQuota {
+	return &v1beta1.ProviderQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "quota", Namespace: "default"},
+		Spec: v1beta1.ProviderQuotaSpec{Hard: v1beta1.ProviderQuotaResources{
+			Instances: pointer.Int64(instances),
+			Nodes:     pointer.Int64(nodes),
+		}},
+	}
+}
+
+func TestNamespaceUsage(t *testing.T) {
+	g := NewWithT(t)
+	failed := newQuotaInstance("failed", 0, "4", "")
+	failed.Status.Phase = dbaasv1beta1.InstancePhaseFailed
+	deleting := newQuotaInstance("deleting", 0, "4", "")
+	deleting.Finalizers = []string{"test/finalizer"}
+	otherNamespace := newQuotaInstance("other-namespace", 0, "8", "other-id")
+	otherNamespace.Namespace = "other"
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(
+		newQuotaInstance("provisioned", 0, "1", "provisioned-id"),
+		withReadyReason(newQuotaInstance("accepted", time.Minute, "2", ""), InstanceCreationFailed),
+		newQuotaInstance("new", 2*time.Minute, "", ""),
+		withReadyReason(newQuotaInstance("rejected", time.Minute, "4", ""), QuotaExceeded),
+		failed,
+		deleting,
+		&v1beta1.ProviderConnection{
+			ObjectMeta: metav1.ObjectMeta{Name: "ready", Namespace: "default"},
+			Status: v1beta1.ProviderConnectionStatus{DBaaSConnectionStatus: dbaasv1beta1.DBaaSConnectionStatus{
+				Conditions: []metav1.Condition{{Type: connectionConditionReadyType, Status: metav1.ConditionTrue, Reason: string(ConnectionReady), LastTransitionTime: metav1.NewTime(quotaEpoch)}},
+			}},
+		},
+		&v1beta1.ProviderConnection{ObjectMeta: metav1.ObjectMeta{Name: "not-ready", Namespace: "default"}},
+		otherNamespace,
+	).Build()
+	g.Expect(c.Delete(context.Background(), deleting)).To(Succeed())
+
+	used, err := namespaceUsage(context.Background(), c, "default")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(*used.Instances).To(Equal(int64(3)))
+	g.Expect(*used.Nodes).To(Equal(int64(3)))
+	g.Expect(*used.Connections).To(Equal(int64(1)))
+}
+
+func TestCheckInstanceQuota(t *testing.T) {
+	tests := []struct {
+		name     string
+		quota    *v1beta1.ProviderQuota
+		existing []client.Object
+		instance *v1beta1.ProviderInstance
+		wantErr  error
+	}{
+		{
+			name:     "no quota",
+			existing: []client.Object{newQuotaInstance("provisioned", 0, "1", "provisioned-id")},
+			instance: newQuotaInstance("instance", time.Minute, "100", ""),
+		},
+		{
+			name:     "within the limits",
+			quota:    newQuota(2, 3),
+			existing: []client.Object{newQuotaInstance("provisioned", 0, "1", "provisioned-id")},
+			instance: newQuotaInstance("instance", time.Minute, "2", ""),
+		},
+		{
+			name:     "over the nodes limit",
+			quota:    newQuota(2, 3),
+			existing: []client.Object{newQuotaInstance("provisioned", 0, "1", "provisioned-id")},
+			instance: newQuotaInstance("instance", time.Minute, "3", ""),
+			wantErr:  errQuotaExceeded,
+		},
+		{
+			name:     "invalid parameter",
+			quota:    newQuota(2, 3),
+			instance: newQuotaInstance("instance", time.Minute, "many", ""),
+			wantErr:  errInvalidQuotaParameter,
+		},
+		{
+			name:     "invalid parameter without quota",
+			instance: newQuotaInstance("instance", time.Minute, "-1", ""),
+			wantErr:  errInvalidQuotaParameter,
+		},
+		{
+			name:     "behind an earlier accepted instance",
+			quota:    newQuota(1, 10),
+			existing: []client.Object{newQuotaInstance("earlier", 0, "1", "")},
+			instance: newQuotaInstance("instance", time.Minute, "1", ""),
+			wantErr:  errQuotaExceeded,
+		},
+		{
+			name:     "behind an earlier accepted instance of the same creation time",
+			quota:    newQuota(1, 10),
+			existing: []client.Object{newQuotaInstance("an-instance", time.Minute, "1", "")},
+			instance: newQuotaInstance("instance", time.Minute, "1", ""),
+			wantErr:  errQuotaExceeded,
+		},
+		{
+			name:     "before a later accepted instance",
+			quota:    newQuota(1, 10),
+			existing: []client.Object{newQuotaInstance("later", 2*time.Minute, "1", "")},
+			instance: newQuotaInstance("instance", time.Minute, "1", ""),
+		},
+		{
+			name:     "after an earlier rejected instance",
+			quota:    newQuota(1, 10),
+			existing: []client.Object{withReadyReason(newQuotaInstance("earlier", 0, "1", ""), QuotaExceeded)},
+			instance: newQuotaInstance("instance", time.Minute, "1", ""),
+		},
+		{
+			name:     "new instance behind a later accepted instance",
+			quota:    newQuota(1, 10),
+			existing: []client.Object{newQuotaInstance("later", 2*time.Minute, "1", "")},
+			instance: &v1beta1.ProviderInstance{ObjectMeta: metav1.ObjectMeta{Name: "instance", Namespace: "default"}},
+			wantErr:  errQuotaExceeded,
+		},
+		{
+			name:     "provisioned instance with more nodes",
+			quota:    newQuota(2, 3),
+			existing: []client.Object{newQuotaInstance("accepted", 2*time.Minute, "2", "")},
+			instance: newQuotaInstance("instance", time.Minute, "2", "instance-id"),
+			wantErr:  errQuotaExceeded,
+		},
+		{
+			name:     "provisioned instance with fewer nodes",
+			quota:    newQuota(2, 3),
+			existing: []client.Object{newQuotaInstance("accepted", 2*time.Minute, "2", "")},
+			instance: newQuotaInstance("instance", time.Minute, "1", "instance-id"),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			objects := append([]client.Object{tc.instance.DeepCopy()}, tc.existing...)
+			if len(tc.instance.Name) == 0 || tc.instance.CreationTimestamp.IsZero() {
+				objects = tc.existing
+			}
+			if tc.quota != nil {
+				objects = append(objects, tc.quota)
+			}
+			c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(objects...).Build()
+
+			err := checkInstanceQuota(context.Background(), c, tc.instance)
+			if tc.wantErr != nil {
+				g.Expect(errors.Is(err, tc.wantErr)).To(BeTrue(), "unexpected error %v", err)
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestQuotaValidator(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	provisioned := newQuotaInstance("provisioned", 0, "2", "provisioned-id")
+	connection := &v1beta1.ProviderConnection{ObjectMeta: metav1.ObjectMeta{Name: "connection", Namespace: "default"}}
+	quota := newQuota(1, 2)
+	quota.Spec.Hard.Connections = pointer.Int64(0)
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(provisioned, quota).Build()
+	v := &QuotaValidator{Client: c}
+
+	err := v.ValidateCreate(ctx, &v1beta1.ProviderInstance{ObjectMeta: metav1.ObjectMeta{Name: "instance", Namespace: "default"}})
+	g.Expect(errors.Is(err, errQuotaExceeded)).To(BeTrue())
+	g.Expect(errors.Is(v.ValidateCreate(ctx, connection), errQuotaExceeded)).To(BeTrue())
+
+	// updates that keep the provisioning parameters are allowed over a quota, so the objects can still be changed and deleted
+	labeled := provisioned.DeepCopy()
+	labeled.Labels = map[string]string{"test": "label"}
+	g.Expect(v.ValidateUpdate(ctx, provisioned, labeled)).To(Succeed())
+	g.Expect(v.ValidateUpdate(ctx, connection, connection.DeepCopy())).To(Succeed())
+
+	smaller := provisioned.DeepCopy()
+	smaller.Spec.ProvisioningParameters[dbaasv1beta1.ProvisioningNodes] = "1"
+	g.Expect(v.ValidateUpdate(ctx, provisioned, smaller)).To(Succeed())
+	larger := provisioned.DeepCopy()
+	larger.Spec.ProvisioningParameters[dbaasv1beta1.ProvisioningNodes] = "3"
+	g.Expect(errors.Is(v.ValidateUpdate(ctx, provisioned, larger), errQuotaExceeded)).To(BeTrue())
+
+	g.Expect(v.ValidateDelete(ctx, provisioned)).To(Succeed())
+}
+
+// TestProviderInstanceQuotaExceeded checks that an instance over a quota is not provisioned and is checked again
+// soon, and when the quota changes.
+func TestProviderInstanceQuotaExceeded(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	cloudService, _, objects := newReadyInstance(g, t)
+	instance := objects[2].(*v1beta1.ProviderInstance)
+	instance.Status = dbaasv1beta1.DBaaSInstanceStatus{}
+	quota := newQuota(0, 10)
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(append(objects, quota)...).Build()
+	r := &ProviderInstanceReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+	before, _, err := cloudService.ListClusters(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(instance)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{RequeueAfter: quotaRetryDelay}))
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(instance), instance)).To(Succeed())
+	g.Expect(instance.Status.Phase).To(Equal(dbaasv1beta1.InstancePhasePending))
+	g.Expect(instance.Status.InstanceID).To(BeEmpty())
+	g.Expect(apimeta.FindStatusCondition(instance.Status.Conditions, instanceConditionReadyType).Reason).To(Equal(string(QuotaExceeded)))
+	after, _, err := cloudService.ListClusters(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(after.Clusters).To(HaveLen(len(before.Clusters)))
+
+	g.Expect(quotaRejectedInstancesMapFn(c)(quota)).To(ConsistOf(
+		ctrl.Request{NamespacedName: types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}}))
+}
+
+// TestProviderInstanceInvalidQuotaParameter checks that an instance with an invalid provisioning parameter
+// fails with an input error, and is not checked again until it changes.
+func TestProviderInstanceInvalidQuotaParameter(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	_, _, objects := newReadyInstance(g, t)
+	instance := objects[2].(*v1beta1.ProviderInstance)
+	instance.Spec.ProvisioningParameters = map[dbaasv1beta1.ProvisioningParameterType]string{dbaasv1beta1.ProvisioningStorageGib: "10Gi"}
+	instance.Status = dbaasv1beta1.DBaaSInstanceStatus{}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(g)).WithObjects(objects...).Build()
+	r := &ProviderInstanceReconciler{DBaaSProviderService: &testutil.FakeProviderService{Client: c}, Scheme: c.Scheme()}
+
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(instance)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(ctrl.Result{}))
+	g.Expect(c.Get(ctx, client.ObjectKeyFromObject(instance), instance)).To(Succeed())
+	g.Expect(instance.Status.Phase).To(Equal(dbaasv1beta1.InstancePhaseFailed))
+	g.Expect(instance.Status.InstanceID).To(BeEmpty())
+	g.Expect(apimeta.FindStatusCondition(instance.Status.Conditions, instanceConditionReadyType).Reason).To(Equal(string(InputError)))
+}
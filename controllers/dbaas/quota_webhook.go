@@ -0,0 +1,86 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+)
+
+//+kubebuilder:webhook:path=/validate-dbaas-redhat-com-v1beta1-providerinstance,mutating=false,failurePolicy=fail,sideEffects=None,groups=dbaas.redhat.com,resources=providerinstances,verbs=create;update,versions=v1beta1,name=vproviderinstance.dbaas.redhat.com,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-dbaas-redhat-com-v1beta1-providerconnection,mutating=false,failurePolicy=fail,sideEffects=None,groups=dbaas.redhat.com,resources=providerconnections,verbs=create,versions=v1beta1,name=vproviderconnection.dbaas.redhat.com,admissionReviewVersions=v1
+
+// QuotaValidator rejects the creation of ProviderInstances and ProviderConnections that exceed a ProviderQuota of their namespace
+type QuotaValidator struct {
+	client.Client
+}
+
+// SetupWebhookWithManager registers the quota admission webhooks with the Manager.
+func (v *QuotaValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if err := ctrl.NewWebhookManagedBy(mgr).
+		For(&v1beta1.ProviderInstance{}).
+		WithValidator(v).
+		Complete(); err != nil {
+		return err
+	}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1beta1.ProviderConnection{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate checks the new object against the quotas of its namespace.
+func (v *QuotaValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	switch o := obj.(type) {
+	case *v1beta1.ProviderInstance:
+		return checkInstanceQuota(ctx, v.Client, o)
+	case *v1beta1.ProviderConnection:
+		return checkConnectionQuota(ctx, v.Client, o)
+	}
+	return fmt.Errorf("unexpected object type %T", obj)
+}
+
+// ValidateUpdate checks an instance against the quotas of its namespace when its provisioning parameters change.
+// The other updates are allowed, so an instance or a connection over a lowered quota can still be updated and deleted.
+func (v *QuotaValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	switch o := newObj.(type) {
+	case *v1beta1.ProviderInstance:
+		old, ok := oldObj.(*v1beta1.ProviderInstance)
+		if !ok {
+			return fmt.Errorf("unexpected object type %T", oldObj)
+		}
+		if equality.Semantic.DeepEqual(old.Spec.ProvisioningParameters, o.Spec.ProvisioningParameters) {
+			return nil
+		}
+		return checkInstanceQuota(ctx, v.Client, o)
+	case *v1beta1.ProviderConnection:
+		return nil
+	}
+	return fmt.Errorf("unexpected object type %T", newObj)
+}
+
+// ValidateDelete allows all deletions.
+func (v *QuotaValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
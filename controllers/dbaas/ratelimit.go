@@ -0,0 +1,91 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+
+	configv1alpha1 "github.com/RHEcosystemAppEng/provider-operator-example/apis/config/v1alpha1"
+)
+
+// ControllerOptions are the concurrency and the rate limiter of a controller.
+// The zero value is the controller-runtime default: one worker, and the default controller rate limiter.
+type ControllerOptions struct {
+	// MaxConcurrentReconciles is the number of objects reconciled at the same time.
+	MaxConcurrentReconciles int
+	// BaseDelay is the requeue delay after the first failure of an object, doubled on every failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// QPS and Burst limit the rate of the reconciles of all the objects of the controller.
+	QPS   float64
+	Burst int
+}
+
+// BindFlags binds the options of the controller to the flags prefixed with its name, like
+// --instance-max-concurrent-reconciles.
+func (o *ControllerOptions) BindFlags(fs *flag.FlagSet, name string) {
+	fs.IntVar(&o.MaxConcurrentReconciles, name+"-max-concurrent-reconciles", 1,
+		fmt.Sprintf("The number of %v objects reconciled at the same time.", name))
+	fs.DurationVar(&o.BaseDelay, name+"-rate-limit-base-delay", 5*time.Millisecond,
+		fmt.Sprintf("The requeue delay of a %v object after its first failed reconcile.", name))
+	fs.DurationVar(&o.MaxDelay, name+"-rate-limit-max-delay", 1000*time.Second,
+		fmt.Sprintf("The maximum requeue delay of a %v object after failed reconciles.", name))
+	fs.Float64Var(&o.QPS, name+"-rate-limit-qps", 10,
+		fmt.Sprintf("The number of %v reconciles per second.", name))
+	fs.IntVar(&o.Burst, name+"-rate-limit-burst", 100,
+		fmt.Sprintf("The number of %v reconciles above the rate allowed at once.", name))
+}
+
+// ApplyConfig sets the options of the controller from the config file, except the options whose flags,
+// prefixed with the name of the controller, were set on the command line.
+func (o *ControllerOptions) ApplyConfig(c configv1alpha1.ControllerConfig, name string, flagSet func(flagName string) bool) {
+	if c.MaxConcurrentReconciles > 0 && !flagSet(name+"-max-concurrent-reconciles") {
+		o.MaxConcurrentReconciles = c.MaxConcurrentReconciles
+	}
+	if c.RateLimitBaseDelay != nil && !flagSet(name+"-rate-limit-base-delay") {
+		o.BaseDelay = c.RateLimitBaseDelay.Duration
+	}
+	if c.RateLimitMaxDelay != nil && !flagSet(name+"-rate-limit-max-delay") {
+		o.MaxDelay = c.RateLimitMaxDelay.Duration
+	}
+	if c.RateLimitQPS > 0 && !flagSet(name+"-rate-limit-qps") {
+		o.QPS = c.RateLimitQPS
+	}
+	if c.RateLimitBurst > 0 && !flagSet(name+"-rate-limit-burst") {
+		o.Burst = c.RateLimitBurst
+	}
+}
+
+// controllerOptions returns the controller-runtime options of the controller.
+func (o ControllerOptions) controllerOptions() controller.Options {
+	if o.BaseDelay == 0 && o.MaxDelay == 0 && o.QPS == 0 && o.Burst == 0 {
+		return controller.Options{MaxConcurrentReconciles: o.MaxConcurrentReconciles}
+	}
+	return controller.Options{
+		MaxConcurrentReconciles: o.MaxConcurrentReconciles,
+		RateLimiter: workqueue.NewMaxOfRateLimiter(
+			workqueue.NewItemExponentialFailureRateLimiter(o.BaseDelay, o.MaxDelay),
+			&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(o.QPS), o.Burst)},
+		),
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	configv1alpha1 "github.com/RHEcosystemAppEng/provider-operator-example/apis/config/v1alpha1"
+)
+
+func TestControllerOptions(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(ControllerOptions{}.controllerOptions().RateLimiter).To(BeNil())
+
+	var options ControllerOptions
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	options.BindFlags(fs, "instance")
+	g.Expect(fs.Parse([]string{"--instance-max-concurrent-reconciles=4", "--instance-rate-limit-base-delay=1s"})).To(Succeed())
+	g.Expect(options).To(Equal(ControllerOptions{
+		MaxConcurrentReconciles: 4,
+		BaseDelay:               time.Second,
+		MaxDelay:                1000 * time.Second,
+		QPS:                     10,
+		Burst:                   100,
+	}))
+
+	controllerOptions := options.controllerOptions()
+	g.Expect(controllerOptions.MaxConcurrentReconciles).To(Equal(4))
+	g.Expect(controllerOptions.RateLimiter.When("instance")).To(Equal(time.Second))
+	g.Expect(controllerOptions.RateLimiter.When("instance")).To(Equal(2 * time.Second))
+	g.Expect(controllerOptions.RateLimiter.When("other")).To(Equal(time.Second))
+}
+
+func TestControllerOptionsApplyConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(configv1alpha1.AddToScheme(scheme)).To(Succeed())
+	operatorConfig := configv1alpha1.ProviderOperatorConfig{}
+	options, err := ctrl.Options{Scheme: scheme}.AndFrom(
+		ctrl.ConfigFile().AtPath("../../config/manager/controller_manager_config.yaml").OfKind(&operatorConfig))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(options.HealthProbeBindAddress).To(Equal(":8081"))
+	g.Expect(options.LeaderElectionID).To(Equal("425ea28f.redhat.com"))
+	g.Expect(operatorConfig.ProviderSyncPeriod.Duration).To(Equal(3 * time.Hour))
+
+	var instanceOptions ControllerOptions
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	instanceOptions.BindFlags(fs, "instance")
+	g.Expect(fs.Parse([]string{"--instance-rate-limit-max-delay=1m"})).To(Succeed())
+	setFlags := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+	instanceOptions.ApplyConfig(operatorConfig.Controllers["instance"], "instance", func(name string) bool {
+		return setFlags[name]
+	})
+	// the flag set on the command line overrides the config file
+	g.Expect(instanceOptions).To(Equal(ControllerOptions{
+		MaxConcurrentReconciles: 2,
+		BaseDelay:               5 * time.Millisecond,
+		MaxDelay:                time.Minute,
+		QPS:                     10,
+		Burst:                   100,
+	}))
+}
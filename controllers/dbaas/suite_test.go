@@ -17,14 +17,24 @@ limitations under the License.
 package dbaas
 
 import (
+	"context"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	dbaasoperatorv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	"github.com/onsi/gomega/types"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
@@ -32,6 +42,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	dbaasv1beta1 "github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -41,6 +52,12 @@ import (
 var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
+var cancel context.CancelFunc
+
+const (
+	timeout  = 30 * time.Second
+	interval = 250 * time.Millisecond
+)
 
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
@@ -54,12 +71,18 @@ var _ = BeforeSuite(func() {
 	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
 
 	By("bootstrapping test environment")
+	// the DBaaS CRDs are loaded from the dbaas-operator module the operator is built with
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "github.com/RHEcosystemAppEng/dbaas-operator").Output()
+	Expect(err).NotTo(HaveOccurred())
+	dbaasOperatorDir := strings.TrimSpace(string(out))
 	testEnv = &envtest.Environment{
-		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases")},
+		CRDDirectoryPaths: []string{
+			filepath.Join("..", "..", "config", "crd", "bases"),
+			filepath.Join(dbaasOperatorDir, "config", "crd", "bases"),
+		},
 		ErrorIfCRDPathMissing: true,
 	}
 
-	var err error
 	// cfg is defined in this file globally.
 	cfg, err = testEnv.Start()
 	Expect(err).NotTo(HaveOccurred())
@@ -67,6 +90,8 @@ var _ = BeforeSuite(func() {
 
 	err = dbaasv1beta1.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
+	err = dbaasoperatorv1beta1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
 
 	//+kubebuilder:scaffold:scheme
 
@@ -74,10 +99,110 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(k8sClient).NotTo(BeNil())
 
+	By("starting the reconcilers with the fake provider service")
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:             scheme.Scheme,
+		MetricsBindAddress: "0",
+	})
+	Expect(err).NotTo(HaveOccurred())
+	fakeService := &testutil.FakeProviderService{Client: mgr.GetClient()}
+	err = (&ProviderInventoryReconciler{
+		DBaaSProviderService: fakeService,
+		Scheme:               mgr.GetScheme(),
+	}).SetupWithManager(mgr)
+	Expect(err).NotTo(HaveOccurred())
+	err = (&ProviderInstanceReconciler{
+		DBaaSProviderService: fakeService,
+		Scheme:               mgr.GetScheme(),
+	}).SetupWithManager(mgr)
+	Expect(err).NotTo(HaveOccurred())
+	err = (&ProviderConnectionReconciler{
+		DBaaSProviderService: fakeService,
+		Scheme:               mgr.GetScheme(),
+	}).SetupWithManager(mgr)
+	Expect(err).NotTo(HaveOccurred())
+
+	var ctx context.Context
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		defer GinkgoRecover()
+		err := mgr.Start(ctx)
+		Expect(err).NotTo(HaveOccurred())
+	}()
+
 }, 60)
 
 var _ = AfterSuite(func() {
 	By("tearing down the test environment")
+	if cancel != nil {
+		cancel()
+	}
 	err := testEnv.Stop()
 	Expect(err).NotTo(HaveOccurred())
 })
+
+// createNamespace creates a namespace for the objects of a spec, so that specs do not see each other's objects.
+func createNamespace(ctx context.Context) string {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "envtest-"}}
+	Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+	return namespace.Name
+}
+
+// createCredentials creates the credentials secret of a fake provider account. The first credential
+// field is the tenant of the account, every namespace uses its own tenant.
+func createCredentials(ctx context.Context, namespace string, data map[string][]byte) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: namespace},
+		Data:       data,
+	}
+	Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+	return secret
+}
+
+// validCredentials returns the credentials of the fake provider tenant of the namespace.
+func validCredentials(namespace string) map[string][]byte {
+	return map[string][]byte{
+		"CredentialField1": []byte(namespace),
+		"CredentialField2": []byte("field2"),
+	}
+}
+
+func createInventory(ctx context.Context, namespace, secretName string) *dbaasv1beta1.ProviderInventory {
+	inventory := &dbaasv1beta1.ProviderInventory{
+		ObjectMeta: metav1.ObjectMeta{Name: "inventory", Namespace: namespace},
+		Spec: dbaasoperatorv1beta1.DBaaSInventorySpec{
+			CredentialsRef: &dbaasoperatorv1beta1.LocalObjectReference{Name: secretName},
+		},
+	}
+	Expect(k8sClient.Create(ctx, inventory)).To(Succeed())
+	return inventory
+}
+
+// condition returns a function getting the condition of the object, for polling the object status.
+func condition(ctx context.Context, obj client.Object, conditions *[]metav1.Condition, conditionType string) func() *metav1.Condition {
+	return func() *metav1.Condition {
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			return nil
+		}
+		return apimeta.FindStatusCondition(*conditions, conditionType)
+	}
+}
+
+// haveCondition matches a condition with the status and the reason.
+func haveCondition(status metav1.ConditionStatus, reason ConditionReason) types.GomegaMatcher {
+	return And(Not(BeNil()), HaveField("Status", status), HaveField("Reason", string(reason)))
+}
+
+func createInstance(ctx context.Context, inventory *dbaasv1beta1.ProviderInventory, clusterName string) *dbaasv1beta1.ProviderInstance {
+	instance := &dbaasv1beta1.ProviderInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: "instance", Namespace: inventory.Namespace},
+		Spec: dbaasv1beta1.ProviderInstanceSpec{DBaaSInstanceSpec: dbaasoperatorv1beta1.DBaaSInstanceSpec{
+			InventoryRef: dbaasoperatorv1beta1.NamespacedName{Name: inventory.Name, Namespace: inventory.Namespace},
+			ProvisioningParameters: map[dbaasoperatorv1beta1.ProvisioningParameterType]string{
+				dbaasoperatorv1beta1.ProvisioningName: clusterName,
+			},
+		}},
+	}
+	Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+	return instance
+}
@@ -24,6 +24,7 @@ var (
 		Id:            "a-cluster-instance-1-id",
 		Name:          "a-cluster-test-1",
 		CloudProvider: APICLOUDPROVIDER_GCP,
+		Version:       "v15.3",
 		State:         CLUSTERSTATETYPE_CREATED,
 		Regions: []Region{
 			{
@@ -38,6 +39,7 @@ var (
 		Id:            "a-cluster-instance-2-id",
 		Name:          "a-cluster-test-2",
 		CloudProvider: APICLOUDPROVIDER_AWS,
+		Version:       "v15.3",
 		State:         CLUSTERSTATETYPE_CREATED,
 		Regions: []Region{
 			{
@@ -52,6 +54,7 @@ var (
 		Id:            "a-cluster-instance-id-a-provider-test-instance-name",
 		Name:          "provider-test-instance-name",
 		CloudProvider: APICLOUDPROVIDER_AWS,
+		Version:       "v15.3",
 		State:         CLUSTERSTATETYPE_CREATED,
 		Regions: []Region{
 			{
@@ -66,16 +69,42 @@ var (
 
 var fakeClusters = NewFakeClusters()
 
+// clusterVersions is the catalog of database versions offered by the fake provider.
+var clusterVersions = []ClusterVersion{
+	{Version: "v14.9"},
+	{Version: "v15.3"},
+	{Version: "v15.4", Default: true},
+	{Version: "v16.1"},
+}
+
 type FakeAPIClient struct {
 	*FakeClusters
 }
 
+// FakeClusters is the store of the clusters and backups of the fake provider. Writes are visible to the reads
+// that follow them, unless a read-after-write delay is set, and reads return copies.
 type FakeClusters struct {
-	addCluster    chan Cluster
-	updateCluster chan Cluster
-	deleteCluster chan string
-	clusters      *ListClustersResponse
-	clusterMutex  *sync.Mutex
+	clusters     []Cluster
+	backups      []Backup
+	users        map[string][]SqlUser
+	clusterMutex *sync.Mutex
+
+	// the number of backups created in the store, backup IDs are not reused after deletions
+	backupSequence int
+
+	// the time new clusters stay in the CREATING state
+	provisioningDelay time.Duration
+
+	// creations and deletions of clusters that are not yet visible to reads
+	pending             []pendingWrite
+	readAfterWriteDelay time.Duration
+}
+
+// pendingWrite is the creation of a cluster, or the deletion of the cluster with deleteID, that becomes visible at visibleAt.
+type pendingWrite struct {
+	visibleAt time.Time
+	cluster   *Cluster
+	deleteID  string
 }
 
 type Cluster struct {
@@ -88,6 +117,9 @@ type Cluster struct {
 	State                ClusterStateType  `json:"state"`
 	CreatorId            string            `json:"creator_id"`
 	OperationStatus      ClusterStatusType `json:"operation_status"`
+	UpgradeStatus        UpgradeStatusType `json:"upgrade_status,omitempty"`
+	TargetVersion        string            `json:"target_version,omitempty"`
+	UpgradeProgress      int32             `json:"upgrade_progress,omitempty"`
 	Regions              []Region          `json:"regions"`
 	CreatedAt            *time.Time        `json:"created_at,omitempty"`
 	UpdatedAt            *time.Time        `json:"updated_at,omitempty"`
@@ -100,57 +132,163 @@ type ListClustersResponse struct {
 	Clusters []Cluster `json:"clusters"`
 }
 
+// ClusterVersion struct for ClusterVersion.
+type ClusterVersion struct {
+	Version string `json:"version"`
+	Default bool   `json:"default,omitempty"`
+}
+
+// ListVersionsResponse struct for ListVersionsResponse.
+type ListVersionsResponse struct {
+	Versions []ClusterVersion `json:"versions"`
+}
+
+type Backup struct {
+	Id          string          `json:"id"`
+	ClusterId   string          `json:"cluster_id"`
+	State       BackupStateType `json:"state"`
+	Progress    int32           `json:"progress"`
+	SizeBytes   int64           `json:"size_bytes"`
+	CreatedAt   *time.Time      `json:"created_at,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+}
+
+// ListSqlUsersResponse struct for ListSqlUsersResponse.
+type ListSqlUsersResponse struct {
+	Users []SqlUser `json:"users"`
+}
+
+// ListBackupsResponse struct for ListBackupsResponse.
+type ListBackupsResponse struct {
+	Backups []Backup `json:"backups"`
+}
+
 func NewFakeClusters() *FakeClusters {
+	return newFakeClusters([]Cluster{cluster1, cluster2, cluster3})
+}
+
+// newFakeClusters returns a cluster store seeded with a copy of the fixtures.
+func newFakeClusters(fixtures []Cluster) *FakeClusters {
 	clusters := &FakeClusters{
-		addCluster:    make(chan Cluster, 5),
-		deleteCluster: make(chan string, 5),
-		updateCluster: make(chan Cluster, 5),
-		clusterMutex:  &sync.Mutex{},
-		clusters:      &ListClustersResponse{Clusters: []Cluster{cluster1, cluster2, cluster3}}, //fixed
-	}
-
-	go func() {
-		for c := range clusters.addCluster {
-			clusters.clusterMutex.Lock()
-			clusters.clusters.Clusters = append(clusters.clusters.Clusters, c)
-			clusters.clusterMutex.Unlock()
-		}
-	}()
-
-	go func() {
-		for id := range clusters.deleteCluster {
-			clusters.clusterMutex.Lock()
-			for i, c := range clusters.clusters.Clusters {
-				if c.Id == id {
-					clusters.clusters.Clusters[i] = clusters.clusters.Clusters[len(clusters.clusters.Clusters)-1]
-					clusters.clusters.Clusters = clusters.clusters.Clusters[:len(clusters.clusters.Clusters)-1]
-					break
-				}
-			}
-			clusters.clusterMutex.Unlock()
-		}
-	}()
-
-	go func() {
-		for cluster := range clusters.updateCluster {
-			clusters.clusterMutex.Lock()
-			for i, c := range clusters.clusters.Clusters {
-				if c.Id == cluster.Id {
-					clusters.clusters.Clusters[i] = cluster
-					break
-				}
-			}
-			clusters.clusterMutex.Unlock()
+		users:        map[string][]SqlUser{},
+		clusterMutex: &sync.Mutex{},
+	}
+	for _, c := range fixtures {
+		clusters.clusters = append(clusters.clusters, copyCluster(c))
+	}
+	return clusters
+}
+
+// SetReadAfterWriteDelay makes created and deleted clusters visible to reads only after the delay, like the reads
+// of an eventually consistent API. A zero delay makes the pending writes visible at once.
+func (f *FakeClusters) SetReadAfterWriteDelay(delay time.Duration) {
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+	f.readAfterWriteDelay = delay
+	if delay == 0 {
+		f.applyPendingWrites(time.Time{})
+	}
+}
+
+// SetProvisioningDelay makes new clusters stay in the CREATING state for the delay.
+func (f *FakeClusters) SetProvisioningDelay(delay time.Duration) {
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+	f.provisioningDelay = delay
+}
+
+// writeCluster adds the cluster, or deletes the cluster with deleteID, after the read-after-write delay.
+// The caller must hold the cluster mutex.
+func (f *FakeClusters) writeCluster(cluster *Cluster, deleteID string) {
+	f.pending = append(f.pending, pendingWrite{
+		visibleAt: time.Now().Add(f.readAfterWriteDelay),
+		cluster:   cluster,
+		deleteID:  deleteID,
+	})
+	if f.readAfterWriteDelay == 0 {
+		f.applyPendingWrites(time.Time{})
+	}
+}
+
+// applyPendingWrites applies in order the pending writes that are visible at now, or all of them if now is zero.
+// The caller must hold the cluster mutex.
+func (f *FakeClusters) applyPendingWrites(now time.Time) {
+	applied := 0
+	for _, write := range f.pending {
+		if !now.IsZero() && write.visibleAt.After(now) {
+			break
 		}
-	}()
+		applied++
+		if write.cluster != nil {
+			f.clusters = append(f.clusters, *write.cluster)
+			continue
+		}
+		if i := f.indexOfCluster(write.deleteID); i >= 0 {
+			f.clusters = append(f.clusters[:i], f.clusters[i+1:]...)
+		}
+	}
+	f.pending = f.pending[applied:]
+}
 
-	return clusters
+// visibleClusters makes the pending writes that are due visible, completes the provisioning of the clusters
+// created longer than the provisioning delay ago, and returns the clusters. The caller must hold the cluster mutex.
+func (f *FakeClusters) visibleClusters() []Cluster {
+	now := time.Now()
+	f.applyPendingWrites(now)
+	for i := range f.clusters {
+		cluster := &f.clusters[i]
+		if cluster.State == CLUSTERSTATETYPE_CREATING && cluster.CreatedAt != nil && !cluster.CreatedAt.Add(f.provisioningDelay).After(now) {
+			cluster.State = CLUSTERSTATETYPE_CREATED
+		}
+	}
+	return f.clusters
+}
+
+// indexOfCluster returns the index of the visible cluster with the ID, or -1.
+// The caller must hold the cluster mutex.
+func (f *FakeClusters) indexOfCluster(clusterID string) int {
+	for i := range f.clusters {
+		if f.clusters[i].Id == clusterID {
+			return i
+		}
+	}
+	return -1
+}
+
+// latestCluster returns a copy of the cluster with the ID including the writes that are not yet visible,
+// or nil if the cluster does not exist. The caller must hold the cluster mutex.
+func (f *FakeClusters) latestCluster(clusterID string) *Cluster {
+	var latest *Cluster
+	if i := f.indexOfCluster(clusterID); i >= 0 {
+		latest = &f.clusters[i]
+	}
+	for _, write := range f.pending {
+		if write.cluster != nil && write.cluster.Id == clusterID {
+			latest = write.cluster
+		} else if write.deleteID == clusterID {
+			latest = nil
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+	c := copyCluster(*latest)
+	return &c
+}
+
+// copyCluster returns a copy of the cluster that does not share its regions.
+func copyCluster(cluster Cluster) Cluster {
+	cluster.Regions = append([]Region(nil), cluster.Regions...)
+	return cluster
 }
 
 func (f FakeAPIClient) ListClusters(ctx context.Context) (*ListClustersResponse, *http.Response, error) {
 	f.clusterMutex.Lock()
-	clusters := f.clusters
-	f.clusterMutex.Unlock()
+	defer f.clusterMutex.Unlock()
+	clusters := &ListClustersResponse{Clusters: []Cluster{}}
+	for _, c := range f.visibleClusters() {
+		clusters.Clusters = append(clusters.Clusters, copyCluster(c))
+	}
 	return clusters, buildFakeResponse(), nil
 }
 
@@ -176,49 +314,437 @@ func (f FakeAPIClient) CreateCluster(ctx context.Context, createClusterRequest *
 		return nil, resp, fmt.Errorf("{\"code\": 0, \"message\": \"creation failed\"}")
 	}
 
+	clusterID := "a-cluster-instance-id-" + createClusterRequest.Name
 	f.clusterMutex.Lock()
-	clusters := f.clusters
+	exists := f.latestCluster(clusterID) != nil
 	f.clusterMutex.Unlock()
+	if exists {
+		return nil, buildAlreadyExistsResponse(), fmt.Errorf("{\"code\": 6, \"message\": \"code = AlreadyExists\"}")
+	}
 
-	clusterID := "a-cluster-instance-id-" + createClusterRequest.Name
-	for _, cluster := range clusters.Clusters {
-		if cluster.Id == clusterID {
-			resp := &http.Response{
-				StatusCode: 409,
-				Body:       io.NopCloser(strings.NewReader("{\"code\": 6, \"message\": \"code = AlreadyExists\"}")),
-			}
-			return nil, resp, fmt.Errorf("{\"code\": 6, \"message\": \"code = AlreadyExists\"}")
+	provider := createClusterRequest.Provider
+	version := createClusterRequest.Version
+	if source := createClusterRequest.Source; source != nil {
+		sourceCluster, resp, err := f.validateClusterSource(ctx, source)
+		if err != nil {
+			return nil, resp, err
 		}
+		provider = sourceCluster.CloudProvider
+		if len(version) == 0 {
+			version = sourceCluster.Version
+		}
+	}
+	if len(version) == 0 {
+		version = defaultClusterVersion()
+	} else if !isClusterVersion(version) {
+		return nil, buildInvalidArgumentResponse(), fmt.Errorf("{\"code\": 3, \"message\": \"version is not offered\"}")
 	}
 
 	cluster := Cluster{
 		Id:            clusterID,
 		Name:          createClusterRequest.Name,
-		CloudProvider: createClusterRequest.Provider,
+		Version:       version,
+		CloudProvider: provider,
 		Regions: []Region{
 			{
 				Name:   "region-2",
 				SqlDns: "free-tier5.cloud",
 			},
 		},
-		CreatedAt: &aDate,
-		UpdatedAt: &aDate,
 	}
-	f.addCluster <- cluster
+
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+	now := time.Now().UTC()
+	cluster.CreatedAt = &now
+	cluster.UpdatedAt = &now
+	cluster.State = CLUSTERSTATETYPE_CREATED
+	if f.provisioningDelay > 0 {
+		cluster.State = CLUSTERSTATETYPE_CREATING
+	}
+	// the cluster may have been created while the source was validated
+	if f.latestCluster(clusterID) != nil {
+		return nil, buildAlreadyExistsResponse(), fmt.Errorf("{\"code\": 6, \"message\": \"code = AlreadyExists\"}")
+	}
+	stored := copyCluster(cluster)
+	f.writeCluster(&stored, "")
 	return &cluster, buildFakeResponse(), nil
 }
 
+func (f FakeAPIClient) validateClusterSource(ctx context.Context, source *ClusterSource) (*Cluster, *http.Response, error) {
+	sourceCluster, resp, err := f.GetCluster(ctx, source.ClusterId)
+	if err != nil {
+		return nil, buildNotFoundResponse(), fmt.Errorf("could not find source cluster")
+	}
+	if len(source.BackupId) > 0 {
+		backups, _, _ := f.ListBackups(ctx, source.ClusterId)
+		for _, backup := range backups.Backups {
+			if backup.Id == source.BackupId && backup.State == BACKUPSTATETYPE_COMPLETED {
+				return sourceCluster, resp, nil
+			}
+		}
+		return nil, buildNotFoundResponse(), fmt.Errorf("could not find completed source backup")
+	}
+	if source.PointInTime != nil && source.PointInTime.After(time.Now()) {
+		resp := &http.Response{
+			StatusCode: 400,
+			Body:       io.NopCloser(strings.NewReader("{\"code\": 3, \"message\": \"point in time is in the future\"}")),
+		}
+		return nil, resp, fmt.Errorf("{\"code\": 3, \"message\": \"point in time is in the future\"}")
+	}
+	return sourceCluster, resp, nil
+}
+
+// GetCluster returns the cluster, advancing a running upgrade or rollback by half of the way on every call,
+// so that upgrade progress can be observed.
 func (f FakeAPIClient) GetCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
 	f.clusterMutex.Lock()
-	clusters := f.clusters
-	f.clusterMutex.Unlock()
+	defer f.clusterMutex.Unlock()
+
+	clusters := f.visibleClusters()
+	if i := f.indexOfCluster(clusterID); i >= 0 {
+		advanceUpgrade(&clusters[i])
+		c := copyCluster(clusters[i])
+		return &c, buildFakeResponse(), nil
+	}
+	return nil, buildNotFoundResponse(), fmt.Errorf("could not find cluster")
+}
+
+func (f FakeAPIClient) DeleteCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+
+	cluster := f.latestCluster(clusterID)
+	if cluster == nil {
+		return nil, buildNotFoundResponse(), fmt.Errorf("could not find cluster")
+	}
+	f.writeCluster(nil, clusterID)
+	delete(f.users, clusterID)
+	return cluster, buildFakeResponse(), nil
+}
+
+// CreateSqlUser creates a SQL user of the cluster.
+func (f FakeAPIClient) CreateSqlUser(ctx context.Context, clusterID string, user SqlUser) (*SqlUser, *http.Response, error) {
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+
+	if f.latestCluster(clusterID) == nil {
+		return nil, buildNotFoundResponse(), fmt.Errorf("could not find cluster")
+	}
+	if len(user.Name) == 0 {
+		return nil, buildInvalidArgumentResponse(), fmt.Errorf("{\"code\": 3, \"message\": \"user name is required\"}")
+	}
+	for _, u := range f.users[clusterID] {
+		if u.Name == user.Name {
+			return nil, buildAlreadyExistsResponse(), fmt.Errorf("{\"code\": 6, \"message\": \"code = AlreadyExists\"}")
+		}
+	}
+	f.users[clusterID] = append(f.users[clusterID], user)
+	return &SqlUser{Name: user.Name}, buildFakeResponse(), nil
+}
+
+// ResetSqlUserPassword sets a new password of a SQL user of the cluster.
+func (f FakeAPIClient) ResetSqlUserPassword(ctx context.Context, clusterID, name, password string) (*SqlUser, *http.Response, error) {
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+
+	if f.latestCluster(clusterID) == nil {
+		return nil, buildNotFoundResponse(), fmt.Errorf("could not find cluster")
+	}
+	if len(password) == 0 {
+		return nil, buildInvalidArgumentResponse(), fmt.Errorf("{\"code\": 3, \"message\": \"password is required\"}")
+	}
+	for i, u := range f.users[clusterID] {
+		if u.Name == name {
+			f.users[clusterID][i].Password = password
+			return &SqlUser{Name: u.Name}, buildFakeResponse(), nil
+		}
+	}
+	return nil, buildNotFoundResponse(), fmt.Errorf("could not find user")
+}
+
+// ListSqlUsers lists the SQL users of the cluster, without their passwords.
+func (f FakeAPIClient) ListSqlUsers(ctx context.Context, clusterID string) (*ListSqlUsersResponse, *http.Response, error) {
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+
+	if f.latestCluster(clusterID) == nil {
+		return nil, buildNotFoundResponse(), fmt.Errorf("could not find cluster")
+	}
+	users := &ListSqlUsersResponse{Users: []SqlUser{}}
+	for _, u := range f.users[clusterID] {
+		users.Users = append(users.Users, SqlUser{Name: u.Name})
+	}
+	return users, buildFakeResponse(), nil
+}
+
+// DeleteSqlUser deletes a SQL user of the cluster.
+func (f FakeAPIClient) DeleteSqlUser(ctx context.Context, clusterID, name string) (*SqlUser, *http.Response, error) {
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+
+	users := f.users[clusterID]
+	for i, u := range users {
+		if u.Name == name {
+			f.users[clusterID] = append(users[:i:i], users[i+1:]...)
+			return &SqlUser{Name: u.Name}, buildFakeResponse(), nil
+		}
+	}
+	return nil, buildNotFoundResponse(), fmt.Errorf("could not find user")
+}
+
+func advanceUpgrade(cluster *Cluster) {
+	if cluster.UpgradeStatus != UPGRADESTATUSTYPE_UPGRADE_RUNNING && cluster.UpgradeStatus != UPGRADESTATUSTYPE_ROLLBACK_RUNNING {
+		return
+	}
+	cluster.UpgradeProgress += 50
+	if cluster.UpgradeProgress < 100 {
+		return
+	}
+	now := time.Now().UTC()
+	cluster.UpgradeProgress = 100
+	cluster.UpdatedAt = &now
+	if cluster.UpgradeStatus == UPGRADESTATUSTYPE_UPGRADE_RUNNING {
+		cluster.Version = cluster.TargetVersion
+		cluster.TargetVersion = ""
+		cluster.UpgradeStatus = UPGRADESTATUSTYPE_FINALIZED
+	} else {
+		cluster.UpgradeStatus = UPGRADESTATUSTYPE_ROLLED_BACK
+	}
+}
+
+func (f FakeAPIClient) ListVersions(ctx context.Context) (*ListVersionsResponse, *http.Response, error) {
+	versions := &ListVersionsResponse{Versions: append([]ClusterVersion{}, clusterVersions...)}
+	return versions, buildFakeResponse(), nil
+}
+
+func (f FakeAPIClient) UpgradeCluster(ctx context.Context, clusterID, version string) (*Cluster, *http.Response, error) {
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+
+	clusters := f.visibleClusters()
+	for i := range clusters {
+		cluster := &clusters[i]
+		if cluster.Id != clusterID {
+			continue
+		}
+		if cluster.UpgradeStatus == UPGRADESTATUSTYPE_UPGRADE_RUNNING || cluster.UpgradeStatus == UPGRADESTATUSTYPE_ROLLBACK_RUNNING {
+			return nil, buildFailedPreconditionResponse(), fmt.Errorf("{\"code\": 9, \"message\": \"code = FailedPrecondition\"}")
+		}
+		if !isClusterVersion(version) {
+			return nil, buildInvalidArgumentResponse(), fmt.Errorf("{\"code\": 3, \"message\": \"version is not offered\"}")
+		}
+		now := time.Now().UTC()
+		cluster.TargetVersion = version
+		cluster.UpgradeStatus = UPGRADESTATUSTYPE_UPGRADE_RUNNING
+		cluster.UpgradeProgress = 0
+		cluster.UpdatedAt = &now
+		c := copyCluster(*cluster)
+		return &c, buildFakeResponse(), nil
+	}
+	return nil, buildNotFoundResponse(), fmt.Errorf("could not find cluster")
+}
+
+func (f FakeAPIClient) RollbackClusterUpgrade(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+
+	clusters := f.visibleClusters()
+	for i := range clusters {
+		cluster := &clusters[i]
+		if cluster.Id != clusterID {
+			continue
+		}
+		if cluster.UpgradeStatus != UPGRADESTATUSTYPE_UPGRADE_RUNNING {
+			return nil, buildFailedPreconditionResponse(), fmt.Errorf("{\"code\": 9, \"message\": \"code = FailedPrecondition\"}")
+		}
+		now := time.Now().UTC()
+		cluster.UpgradeStatus = UPGRADESTATUSTYPE_ROLLBACK_RUNNING
+		cluster.UpgradeProgress = 0
+		cluster.UpdatedAt = &now
+		c := copyCluster(*cluster)
+		return &c, buildFakeResponse(), nil
+	}
+	return nil, buildNotFoundResponse(), fmt.Errorf("could not find cluster")
+}
+
+func defaultClusterVersion() string {
+	for _, v := range clusterVersions {
+		if v.Default {
+			return v.Version
+		}
+	}
+	return clusterVersions[len(clusterVersions)-1].Version
+}
+
+func isClusterVersion(version string) bool {
+	for _, v := range clusterVersions {
+		if v.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (f FakeAPIClient) SuspendCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	return f.setClusterState(ctx, clusterID, CLUSTERSTATETYPE_SUSPENDED)
+}
+
+func (f FakeAPIClient) ResumeCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	return f.setClusterState(ctx, clusterID, CLUSTERSTATETYPE_CREATED)
+}
+
+func (f FakeAPIClient) setClusterState(ctx context.Context, clusterID string, state ClusterStateType) (*Cluster, *http.Response, error) {
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+
+	clusters := f.visibleClusters()
+	i := f.indexOfCluster(clusterID)
+	if i < 0 {
+		return nil, buildNotFoundResponse(), fmt.Errorf("could not find cluster")
+	}
+	now := time.Now().UTC()
+	clusters[i].State = state
+	clusters[i].UpdatedAt = &now
+	c := copyCluster(clusters[i])
+	return &c, buildFakeResponse(), nil
+}
+
+func (f FakeAPIClient) CreateBackup(ctx context.Context, clusterID string) (*Backup, *http.Response, error) {
+	if _, _, err := f.GetCluster(ctx, clusterID); err != nil {
+		return nil, buildNotFoundResponse(), err
+	}
+
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+	now := time.Now().UTC()
+	backup := Backup{
+		Id:        fmt.Sprintf("%s-backup-%d", clusterID, f.backupSequence+1),
+		ClusterId: clusterID,
+		State:     BACKUPSTATETYPE_IN_PROGRESS,
+		CreatedAt: &now,
+	}
+	f.backupSequence++
+	f.backups = append(f.backups, backup)
+	return &backup, buildFakeResponse(), nil
+}
+
+func (f FakeAPIClient) ListBackups(ctx context.Context, clusterID string) (*ListBackupsResponse, *http.Response, error) {
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+	backups := &ListBackupsResponse{}
+	for _, backup := range f.backups {
+		if backup.ClusterId == clusterID {
+			backups.Backups = append(backups.Backups, backup)
+		}
+	}
+	return backups, buildFakeResponse(), nil
+}
 
-	for _, cluster := range clusters.Clusters {
-		if cluster.Id == clusterID {
-			return &cluster, buildFakeResponse(), nil
+// GetBackup returns the backup, advancing an in-progress backup by half of the way on every call,
+// so that progress reporting can be observed.
+func (f FakeAPIClient) GetBackup(ctx context.Context, clusterID, backupID string) (*Backup, *http.Response, error) {
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+	for i := range f.backups {
+		backup := &f.backups[i]
+		if backup.ClusterId != clusterID || backup.Id != backupID {
+			continue
+		}
+		if backup.State == BACKUPSTATETYPE_IN_PROGRESS {
+			backup.Progress += 50
+			backup.SizeBytes += 512 * 1024 * 1024
+			if backup.Progress >= 100 {
+				now := time.Now().UTC()
+				backup.Progress = 100
+				backup.State = BACKUPSTATETYPE_COMPLETED
+				backup.CompletedAt = &now
+			}
 		}
+		b := *backup
+		return &b, buildFakeResponse(), nil
+	}
+	return nil, buildNotFoundResponse(), fmt.Errorf("could not find backup")
+}
+
+func (f FakeAPIClient) DeleteBackup(ctx context.Context, clusterID, backupID string) (*Backup, *http.Response, error) {
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+	for i, backup := range f.backups {
+		if backup.ClusterId == clusterID && backup.Id == backupID {
+			f.backups = append(f.backups[:i], f.backups[i+1:]...)
+			return &backup, buildFakeResponse(), nil
+		}
+	}
+	return nil, buildNotFoundResponse(), fmt.Errorf("could not find backup")
+}
+
+func (f FakeAPIClient) RestoreBackup(ctx context.Context, restoreBackupRequest *RestoreBackupRequest) (*Cluster, *http.Response, error) {
+	f.clusterMutex.Lock()
+	var source *Backup
+	for _, backup := range f.backups {
+		if backup.ClusterId == restoreBackupRequest.ClusterId && backup.Id == restoreBackupRequest.BackupId {
+			source = &backup
+			break
+		}
+	}
+	f.clusterMutex.Unlock()
+	if source == nil {
+		return nil, buildNotFoundResponse(), fmt.Errorf("could not find backup")
+	}
+	if source.State != BACKUPSTATETYPE_COMPLETED {
+		return nil, buildFailedPreconditionResponse(), fmt.Errorf("{\"code\": 9, \"message\": \"code = FailedPrecondition\"}")
+	}
+
+	sourceCluster, _, err := f.GetCluster(ctx, restoreBackupRequest.ClusterId)
+	if err != nil {
+		return nil, buildNotFoundResponse(), err
+	}
+	return f.CreateCluster(ctx, &CreateClusterRequest{
+		Name:     restoreBackupRequest.Name,
+		Provider: sourceCluster.CloudProvider,
+		Version:  sourceCluster.Version,
+	})
+}
+
+func buildNotFoundResponse() *http.Response {
+	return &http.Response{
+		Status:     "404 Not Found",
+		StatusCode: 404,
+		Proto:      "HTTP/1.1",
+		Body:       io.NopCloser(strings.NewReader("{\"code\": 5, \"message\": \"code = NotFound\"}")),
+		Header:     make(http.Header),
+	}
+}
+
+func buildAlreadyExistsResponse() *http.Response {
+	return &http.Response{
+		Status:     "409 Conflict",
+		StatusCode: 409,
+		Proto:      "HTTP/1.1",
+		Body:       io.NopCloser(strings.NewReader("{\"code\": 6, \"message\": \"code = AlreadyExists\"}")),
+		Header:     make(http.Header),
+	}
+}
+
+func buildFailedPreconditionResponse() *http.Response {
+	return &http.Response{
+		Status:     "400 Bad Request",
+		StatusCode: 400,
+		Proto:      "HTTP/1.1",
+		Body:       io.NopCloser(strings.NewReader("{\"code\": 9, \"message\": \"code = FailedPrecondition\"}")),
+		Header:     make(http.Header),
+	}
+}
+
+func buildInvalidArgumentResponse() *http.Response {
+	return &http.Response{
+		Status:     "400 Bad Request",
+		StatusCode: 400,
+		Proto:      "HTTP/1.1",
+		Body:       io.NopCloser(strings.NewReader("{\"code\": 3, \"message\": \"version is not offered\"}")),
+		Header:     make(http.Header),
 	}
-	return nil, buildFakeResponse(), fmt.Errorf("could not find cluster")
 }
 
 // Plan  - DEDICATED: A paid plan that offers dedicated hardware in any location.  - CUSTOM: A plan option that is used for clusters whose machine configs are not  supported in self-service. All INVOICE clusters are under this plan option.  - SERVERLESS: A paid plan that runs on shared hardware and caps the users' maximum monthly spending to a user-specified (possibly 0) amount.
@@ -238,7 +764,30 @@ type ClusterStateType string
 
 // List of ClusterStateType.
 const (
-	CLUSTERSTATETYPE_CREATED ClusterStateType = "CREATED"
+	CLUSTERSTATETYPE_CREATING  ClusterStateType = "CREATING"
+	CLUSTERSTATETYPE_CREATED   ClusterStateType = "CREATED"
+	CLUSTERSTATETYPE_SUSPENDED ClusterStateType = "SUSPENDED"
+)
+
+// BackupStateType  - IN_PROGRESS: The backup is being taken.  - COMPLETED: The backup can be restored.  - FAILED: The backup could not be taken.
+type BackupStateType string
+
+// List of BackupStateType.
+const (
+	BACKUPSTATETYPE_IN_PROGRESS BackupStateType = "IN_PROGRESS"
+	BACKUPSTATETYPE_COMPLETED   BackupStateType = "COMPLETED"
+	BACKUPSTATETYPE_FAILED      BackupStateType = "FAILED"
+)
+
+// UpgradeStatusType  - FINALIZED: The cluster runs its version and no upgrade is running.  - UPGRADE_RUNNING: The cluster is being upgraded to the target version.  - ROLLBACK_RUNNING: The upgrade to the target version is being rolled back.  - ROLLED_BACK: The upgrade to the target version was rolled back, the cluster runs its previous version.
+type UpgradeStatusType string
+
+// List of UpgradeStatusType.
+const (
+	UPGRADESTATUSTYPE_FINALIZED        UpgradeStatusType = "FINALIZED"
+	UPGRADESTATUSTYPE_UPGRADE_RUNNING  UpgradeStatusType = "UPGRADE_RUNNING"
+	UPGRADESTATUSTYPE_ROLLBACK_RUNNING UpgradeStatusType = "ROLLBACK_RUNNING"
+	UPGRADESTATUSTYPE_ROLLED_BACK      UpgradeStatusType = "ROLLED_BACK"
 )
 
 // ClusterStatusType the model 'ClusterStatusType'.
@@ -0,0 +1,163 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func newTestAPIClient() *FakeAPIClient {
+	return &FakeAPIClient{FakeClusters: newFakeClusters([]Cluster{cluster1})}
+}
+
+func TestCreateClusterIsReadAfterWrite(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	client := newTestAPIClient()
+
+	created, _, err := client.CreateCluster(ctx, &CreateClusterRequest{Name: "read-after-write", Provider: APICLOUDPROVIDER_AWS})
+	g.Expect(err).NotTo(HaveOccurred())
+	cluster, _, err := client.GetCluster(ctx, created.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cluster.Name).To(Equal("read-after-write"))
+
+	_, resp, err := client.CreateCluster(ctx, &CreateClusterRequest{Name: "read-after-write", Provider: APICLOUDPROVIDER_AWS})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+
+	_, _, err = client.DeleteCluster(ctx, created.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, resp, err = client.GetCluster(ctx, created.Id)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+}
+
+func TestBackupIDsAreNotReused(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	client := newTestAPIClient()
+
+	first, _, err := client.CreateBackup(ctx, cluster1.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	second, _, err := client.CreateBackup(ctx, cluster1.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, _, err = client.DeleteBackup(ctx, cluster1.Id, first.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	third, _, err := client.CreateBackup(ctx, cluster1.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(third.Id).NotTo(Equal(second.Id))
+
+	backups, _, err := client.ListBackups(ctx, cluster1.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(backups.Backups).To(HaveLen(2))
+	g.Expect([]string{backups.Backups[0].Id, backups.Backups[1].Id}).To(ConsistOf(second.Id, third.Id))
+
+	// the sequence is kept with the snapshot of the store, and derived from the backup IDs of the states without one
+	state := client.snapshot()
+	g.Expect(state.BackupSequence).To(Equal(3))
+	state.BackupSequence = 0
+	RestoreTenants(map[string]TenantState{t.Name(): state})
+	restored := NewFakeAPIClientForTenant(t.Name())
+	fourth, _, err := restored.CreateBackup(ctx, cluster1.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fourth.Id).NotTo(BeElementOf(second.Id, third.Id))
+}
+
+func TestListClustersReturnsCopy(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	client := newTestAPIClient()
+
+	clusters, _, err := client.ListClusters(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	clusters.Clusters[0].Name = "changed"
+	clusters.Clusters[0].Regions[0].Name = "changed"
+
+	cluster, _, err := client.GetCluster(ctx, cluster1.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cluster.Name).To(Equal(cluster1.Name))
+	g.Expect(cluster.Regions[0].Name).To(Equal(cluster1.Regions[0].Name))
+}
+
+func TestConcurrentClusterAccess(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	client := newTestAPIClient()
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var created []string
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// every name is created twice, only one of the creations succeeds
+			cluster, _, err := client.CreateCluster(ctx, &CreateClusterRequest{Name: fmt.Sprintf("concurrent-%d", i/2), Provider: APICLOUDPROVIDER_AWS})
+			if err != nil {
+				return
+			}
+			mutex.Lock()
+			created = append(created, cluster.Id)
+			mutex.Unlock()
+			_, _, _ = client.ListClusters(ctx)
+			_, _, _ = client.SuspendCluster(ctx, cluster.Id)
+			_, _, _ = client.UpgradeCluster(ctx, cluster.Id, "v16.1")
+			_, _, _ = client.GetCluster(ctx, cluster.Id)
+			_, _, _ = client.CreateBackup(ctx, cluster.Id)
+			_, _, _ = client.ListBackups(ctx, cluster.Id)
+		}(i)
+	}
+	wg.Wait()
+	g.Expect(created).To(HaveLen(10))
+
+	for i, clusterID := range created {
+		wg.Add(1)
+		go func(i int, clusterID string) {
+			defer wg.Done()
+			_, _, _ = client.ListClusters(ctx)
+			if i%2 == 0 {
+				_, _, _ = client.DeleteCluster(ctx, clusterID)
+			}
+		}(i, clusterID)
+	}
+	wg.Wait()
+
+	clusters, _, err := client.ListClusters(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(clusters.Clusters).To(HaveLen(1 + 10 - 5))
+}
+
+func TestReadAfterWriteDelay(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	client := newTestAPIClient()
+	client.SetReadAfterWriteDelay(100 * time.Millisecond)
+
+	created, _, err := client.CreateCluster(ctx, &CreateClusterRequest{Name: "delayed", Provider: APICLOUDPROVIDER_AWS})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, _, err = client.GetCluster(ctx, created.Id)
+	g.Expect(err).To(HaveOccurred())
+	// the provider knows about the cluster before it can be read
+	_, resp, err := client.CreateCluster(ctx, &CreateClusterRequest{Name: "delayed", Provider: APICLOUDPROVIDER_AWS})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+
+	g.Eventually(func() error {
+		_, _, err := client.GetCluster(ctx, created.Id)
+		return err
+	}).Should(Succeed())
+
+	_, _, err = client.DeleteCluster(ctx, created.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, _, err = client.GetCluster(ctx, created.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	client.SetReadAfterWriteDelay(0)
+	_, _, err = client.GetCluster(ctx, created.Id)
+	g.Expect(err).To(HaveOccurred())
+}
@@ -6,10 +6,14 @@ import (
 	"fmt"
 	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
 	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	"golang.org/x/time/rate"
 	v1 "k8s.io/api/core/v1"
 	"net/http"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
 	"strconv"
+	"sync"
+	"time"
 )
 
 var _ Service = &FakeAPIClient{}
@@ -20,15 +24,113 @@ type DBaaSProviderService interface {
 	DiscoverClusters(ctx context.Context, cloudService Service) ([]dbaasv1beta1.DatabaseService, error)
 	CreateCluster(ctx context.Context, cloudService Service, instance *v1beta1.ProviderInstance) (*Cluster, error)
 	GetCluster(ctx context.Context, cloudService Service, clusterID string) (*Cluster, error)
+	SuspendCluster(ctx context.Context, cloudService Service, clusterID string) (*Cluster, error)
+	ResumeCluster(ctx context.Context, cloudService Service, clusterID string) (*Cluster, error)
+	ListVersions(ctx context.Context, cloudService Service) ([]ClusterVersion, error)
+	UpgradeCluster(ctx context.Context, cloudService Service, clusterID, version string) (*Cluster, error)
+	RollbackClusterUpgrade(ctx context.Context, cloudService Service, clusterID string) (*Cluster, error)
+	CreateBackup(ctx context.Context, cloudService Service, clusterID string) (*Backup, error)
+	ListBackups(ctx context.Context, cloudService Service, clusterID string) ([]Backup, error)
+	GetBackup(ctx context.Context, cloudService Service, clusterID, backupID string) (*Backup, error)
+	DeleteBackup(ctx context.Context, cloudService Service, clusterID, backupID string) error
+	RestoreBackup(ctx context.Context, cloudService Service, clusterID, backupID, clusterName string) (*Cluster, error)
+	SetSqlUserPassword(ctx context.Context, cloudService Service, clusterID, user, password string) error
 }
 
 type FakeProviderService struct {
 	client.Client
+	// Faults are injected into all the cloud services, instead of the faults set by inventory annotations.
+	Faults *FaultInjector
+	// FaultInjection enables the faults set by the FaultsAnnotation of the inventories, in dev clusters.
+	// The annotations are ignored if it is false.
+	FaultInjection bool
+	// RateLimiter limits the rate of the calls of all the cloud services to the provider API, if it is set.
+	RateLimiter *rate.Limiter
+	// ServerURL is the URL of a provider API, like the mock provider server. The fake cluster store is used if it is empty.
+	ServerURL string
+	// APIReader reads the credentials secrets directly from the API server, so that the secrets are not cached.
+	// The client is used if it is not set.
+	APIReader client.Reader
+
+	faultsMutex      sync.Mutex
+	annotationFaults map[client.ObjectKey]*annotationFaults
+}
+
+// annotationFaults is the injector of the faults set by the annotation value of an inventory.
+type annotationFaults struct {
+	value    string
+	injector *FaultInjector
 }
 
 func (s *FakeProviderService) CreateCloudService(ctx context.Context, selector client.ObjectKey) (Service, error) {
-	s.retrieveClientCredential(ctx, selector)
-	return NewFakeAPIClient(), nil
+	cred, err := s.retrieveClientCredential(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+	faults, err := s.faultInjector(ctx, selector)
+	if err != nil {
+		return nil, err
+	}
+	var service Service = NewFakeAPIClientForTenant(cred.CredentialField1)
+	if len(s.ServerURL) != 0 {
+		service = NewClient(s.ServerURL, cred.CredentialField1)
+	}
+	if s.RateLimiter != nil {
+		service = NewRateLimitedService(service, s.RateLimiter)
+	}
+	if faults != nil {
+		return NewFaultyService(service, faults), nil
+	}
+	return service, nil
+}
+
+// faultInjector returns the injector of the faults for the credentials secret, or nil if no faults are injected.
+// The faults of an inventory annotation are injected into all the calls made with its credentials secret, for
+// every inventory sharing the secret; if several inventories of the secret are annotated, the faults of the first
+// one by name are injected. The injector of an annotation is kept while the annotation value is unchanged, so that
+// the calls of a fault that fails a number of times are counted across cloud services.
+func (s *FakeProviderService) faultInjector(ctx context.Context, selector client.ObjectKey) (*FaultInjector, error) {
+	if s.Faults != nil {
+		return s.Faults, nil
+	}
+	if !s.FaultInjection {
+		return nil, nil
+	}
+
+	var inventoryList v1beta1.ProviderInventoryList
+	if err := s.List(ctx, &inventoryList, client.InNamespace(selector.Namespace)); err != nil {
+		return nil, err
+	}
+	sort.Slice(inventoryList.Items, func(i, j int) bool {
+		return inventoryList.Items[i].Name < inventoryList.Items[j].Name
+	})
+	for _, inventory := range inventoryList.Items {
+		value, ok := inventory.Annotations[FaultsAnnotation]
+		if !ok || inventory.Spec.CredentialsRef == nil || inventory.Spec.CredentialsRef.Name != selector.Name {
+			continue
+		}
+
+		s.faultsMutex.Lock()
+		defer s.faultsMutex.Unlock()
+		key := client.ObjectKeyFromObject(&inventory)
+		if cached, ok := s.annotationFaults[key]; ok && cached.value == value {
+			return cached.injector, nil
+		}
+		faults, err := ParseFaults(value)
+		if err != nil {
+			return nil, err
+		}
+		injector := NewFaultInjector()
+		for method, fault := range faults {
+			injector.SetFault(method, fault)
+		}
+		if s.annotationFaults == nil {
+			s.annotationFaults = map[client.ObjectKey]*annotationFaults{}
+		}
+		s.annotationFaults[key] = &annotationFaults{value: value, injector: injector}
+		return injector, nil
+	}
+	return nil, nil
 }
 
 func (s *FakeProviderService) DiscoverClusters(ctx context.Context, cloudService Service) ([]dbaasv1beta1.DatabaseService, error) {
@@ -53,8 +155,12 @@ func (s *FakeProviderService) DiscoverClusters(ctx context.Context, cloudService
 }
 
 func (s *FakeProviderService) retrieveClientCredential(ctx context.Context, selector client.ObjectKey) (*credential, error) {
+	var reader client.Reader = s.Client
+	if s.APIReader != nil {
+		reader = s.APIReader
+	}
 	secret := &v1.Secret{}
-	if err := s.Get(ctx, selector, secret); err != nil {
+	if err := reader.Get(ctx, selector, secret); err != nil {
 		return nil, err
 	}
 	cred := &credential{
@@ -83,9 +189,27 @@ func (s *FakeProviderService) CreateCluster(ctx context.Context, cloudService Se
 		return nil, err
 	}
 
+	source, err := GetCloneSource(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	version := getClusterParameter(instance, v1beta1.ProvisioningVersion)
+	if len(version) > 0 {
+		versions, err := s.ListVersions(ctx, cloudService)
+		if err != nil {
+			return nil, err
+		}
+		if !hasVersion(versions, version) {
+			return nil, fmt.Errorf("version %v is not offered by the provider", version)
+		}
+	}
+
 	clusterDetails := &CreateClusterRequest{
 		Name:     clusterName,
 		Provider: ApiCloudProvider(cloudProvider),
+		Version:  version,
+		Source:   source,
 	}
 
 	cluster, _, err := cloudService.CreateCluster(ctx, clusterDetails)
@@ -93,6 +217,55 @@ func (s *FakeProviderService) CreateCluster(ctx context.Context, cloudService Se
 	return cluster, err
 }
 
+// GetCloneSource returns the source to create the cluster from, or nil if the cluster is created empty.
+func GetCloneSource(instance *v1beta1.ProviderInstance) (*ClusterSource, error) {
+	sourceID := getClusterParameter(instance, v1beta1.ProvisioningSourceInstanceID)
+	backupID := getClusterParameter(instance, v1beta1.ProvisioningSourceBackupID)
+	pointInTime := getClusterParameter(instance, v1beta1.ProvisioningSourcePointInTime)
+	if len(sourceID) == 0 {
+		if len(backupID) > 0 || len(pointInTime) > 0 {
+			return nil, fmt.Errorf("parameter %v is required to clone from a backup or a point in time", v1beta1.ProvisioningSourceInstanceID)
+		}
+		return nil, nil
+	}
+	if len(backupID) > 0 && len(pointInTime) > 0 {
+		return nil, fmt.Errorf("parameters %v and %v cannot be used together", v1beta1.ProvisioningSourceBackupID, v1beta1.ProvisioningSourcePointInTime)
+	}
+
+	source := &ClusterSource{
+		ClusterId: sourceID,
+		BackupId:  backupID,
+	}
+	if len(pointInTime) > 0 {
+		t, err := time.Parse(time.RFC3339, pointInTime)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %v must be an RFC 3339 timestamp: %w", v1beta1.ProvisioningSourcePointInTime, err)
+		}
+		source.PointInTime = &t
+	}
+	return source, nil
+}
+
+// ValidateCloneSource checks that the backup to clone from is one of the completed backups of the source cluster,
+// and that the point in time to clone from is not in the future.
+func ValidateCloneSource(source *ClusterSource, backups []Backup, now time.Time) error {
+	if len(source.BackupId) > 0 {
+		for _, backup := range backups {
+			if backup.Id == source.BackupId {
+				if backup.State != BACKUPSTATETYPE_COMPLETED {
+					return fmt.Errorf("source backup %v is %v, only completed backups can be cloned", source.BackupId, backup.State)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("source backup %v not found for instance %v", source.BackupId, source.ClusterId)
+	}
+	if source.PointInTime != nil && source.PointInTime.After(now) {
+		return fmt.Errorf("parameter %v cannot be in the future", v1beta1.ProvisioningSourcePointInTime)
+	}
+	return nil
+}
+
 func getClusterParameter(providerInstance *v1beta1.ProviderInstance, key dbaasv1beta1.ProvisioningParameterType) string {
 	if len(providerInstance.Spec.ProvisioningParameters) == 0 {
 		return ""
@@ -112,10 +285,190 @@ func (s *FakeProviderService) GetCluster(ctx context.Context, cloudService Servi
 	return cluster, nil
 }
 
+func (s *FakeProviderService) ListVersions(ctx context.Context, cloudService Service) ([]ClusterVersion, error) {
+	versions, _, err := cloudService.ListVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return versions.Versions, nil
+}
+
+func (s *FakeProviderService) UpgradeCluster(ctx context.Context, cloudService Service, clusterID, version string) (*Cluster, error) {
+	cluster, _, err := cloudService.UpgradeCluster(ctx, clusterID, version)
+	if err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+func (s *FakeProviderService) RollbackClusterUpgrade(ctx context.Context, cloudService Service, clusterID string) (*Cluster, error) {
+	cluster, _, err := cloudService.RollbackClusterUpgrade(ctx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+// ValidateVersionUpgrade checks that the provider offers the target version, and that the upgrade
+// from the current version is either a minor upgrade or an upgrade to the next major version.
+// Downgrades are not supported.
+func ValidateVersionUpgrade(versions []ClusterVersion, current, target string) error {
+	if !hasVersion(versions, target) {
+		return fmt.Errorf("version %v is not offered by the provider", target)
+	}
+	currentMajor, currentMinor, err := parseVersion(current)
+	if err != nil {
+		return err
+	}
+	targetMajor, targetMinor, err := parseVersion(target)
+	if err != nil {
+		return err
+	}
+	switch {
+	case targetMajor < currentMajor || (targetMajor == currentMajor && targetMinor < currentMinor):
+		return fmt.Errorf("cannot downgrade from version %v to %v", current, target)
+	case targetMajor > currentMajor+1:
+		return fmt.Errorf("cannot upgrade from version %v to %v, major versions must be upgraded one at a time", current, target)
+	}
+	return nil
+}
+
+// parseVersion parses a version in the v<major>.<minor> format.
+func parseVersion(version string) (int, int, error) {
+	var major, minor int
+	if _, err := fmt.Sscanf(version, "v%d.%d", &major, &minor); err != nil {
+		return 0, 0, fmt.Errorf("invalid version %v: %w", version, err)
+	}
+	return major, minor, nil
+}
+
+func hasVersion(versions []ClusterVersion, version string) bool {
+	for _, v := range versions {
+		if v.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *FakeProviderService) SuspendCluster(ctx context.Context, cloudService Service, clusterID string) (*Cluster, error) {
+	cluster, _, err := cloudService.SuspendCluster(ctx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+func (s *FakeProviderService) ResumeCluster(ctx context.Context, cloudService Service, clusterID string) (*Cluster, error) {
+	cluster, _, err := cloudService.ResumeCluster(ctx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+func (s *FakeProviderService) CreateBackup(ctx context.Context, cloudService Service, clusterID string) (*Backup, error) {
+	backup, _, err := cloudService.CreateBackup(ctx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+func (s *FakeProviderService) ListBackups(ctx context.Context, cloudService Service, clusterID string) ([]Backup, error) {
+	backups, _, err := cloudService.ListBackups(ctx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	if backups == nil {
+		return nil, nil
+	}
+	return backups.Backups, nil
+}
+
+func (s *FakeProviderService) GetBackup(ctx context.Context, cloudService Service, clusterID, backupID string) (*Backup, error) {
+	backup, _, err := cloudService.GetBackup(ctx, clusterID, backupID)
+	if err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+// DeleteBackup deletes the backup, a backup already gone from the provider is not an error.
+func (s *FakeProviderService) DeleteBackup(ctx context.Context, cloudService Service, clusterID, backupID string) error {
+	_, resp, err := cloudService.DeleteBackup(ctx, clusterID, backupID)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// RestoreBackup restores the backup to a new cluster of the name. If a cluster of the name already exists,
+// it is returned, so that a restore requested again does not fail.
+func (s *FakeProviderService) RestoreBackup(ctx context.Context, cloudService Service, clusterID, backupID, clusterName string) (*Cluster, error) {
+	if len(clusterName) == 0 {
+		return nil, errors.New("cluster name is required to restore a backup")
+	}
+	restoreDetails := &RestoreBackupRequest{
+		ClusterId: clusterID,
+		BackupId:  backupID,
+		Name:      clusterName,
+	}
+
+	cluster, resp, err := cloudService.RestoreBackup(ctx, restoreDetails)
+	if err != nil && resp != nil && resp.StatusCode == http.StatusConflict {
+		// the restore was requested before, the caller checks when the cluster was created
+		if existing, findErr := findClusterByName(ctx, cloudService, clusterName); findErr == nil && existing != nil {
+			return existing, nil
+		}
+	}
+	return cluster, err
+}
+
+// findClusterByName returns the cluster of the name, or nil if there is none.
+func findClusterByName(ctx context.Context, cloudService Service, name string) (*Cluster, error) {
+	clusters, _, err := cloudService.ListClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range clusters.Clusters {
+		if clusters.Clusters[i].Name == name {
+			return &clusters.Clusters[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// SetSqlUserPassword sets the password of the SQL user of the cluster, creating the user if it does not exist.
+func (s *FakeProviderService) SetSqlUserPassword(ctx context.Context, cloudService Service, clusterID, user, password string) error {
+	_, resp, err := cloudService.ResetSqlUserPassword(ctx, clusterID, user, password)
+	if err != nil && resp != nil && resp.StatusCode == http.StatusNotFound {
+		// the user was not created yet, creating it fails as well if the cluster is missing
+		_, _, err = cloudService.CreateSqlUser(ctx, clusterID, SqlUser{Name: user, Password: password})
+	}
+	return err
+}
+
 type Service interface {
 	ListClusters(ctx context.Context) (*ListClustersResponse, *http.Response, error)
 	CreateCluster(ctx context.Context, createClusterRequest *CreateClusterRequest) (*Cluster, *http.Response, error)
 	GetCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error)
+	DeleteCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error)
+	SuspendCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error)
+	ResumeCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error)
+	ListVersions(ctx context.Context) (*ListVersionsResponse, *http.Response, error)
+	UpgradeCluster(ctx context.Context, clusterID, version string) (*Cluster, *http.Response, error)
+	RollbackClusterUpgrade(ctx context.Context, clusterID string) (*Cluster, *http.Response, error)
+	CreateBackup(ctx context.Context, clusterID string) (*Backup, *http.Response, error)
+	ListBackups(ctx context.Context, clusterID string) (*ListBackupsResponse, *http.Response, error)
+	GetBackup(ctx context.Context, clusterID, backupID string) (*Backup, *http.Response, error)
+	DeleteBackup(ctx context.Context, clusterID, backupID string) (*Backup, *http.Response, error)
+	RestoreBackup(ctx context.Context, restoreBackupRequest *RestoreBackupRequest) (*Cluster, *http.Response, error)
+	CreateSqlUser(ctx context.Context, clusterID string, user SqlUser) (*SqlUser, *http.Response, error)
+	ResetSqlUserPassword(ctx context.Context, clusterID, name, password string) (*SqlUser, *http.Response, error)
 }
 
 // Client manages communication with the provider Cloud API v2022-03-31.
@@ -144,8 +497,18 @@ func PopulateInstanceInfo(cluster *Cluster) map[string]string {
 		"cloudProvider":   string(cluster.CloudProvider),
 		"plan":            string(cluster.Plan),
 		"state":           string(cluster.State),
-		"createAt":        cluster.CreatedAt.String(),
-		"updateAt":        cluster.UpdatedAt.String(),
+		"upgradeStatus":   string(cluster.UpgradeStatus),
+	}
+	// the provider does not return the timestamps of every cluster
+	if cluster.CreatedAt != nil {
+		data["createAt"] = cluster.CreatedAt.String()
+	}
+	if cluster.UpdatedAt != nil {
+		data["updateAt"] = cluster.UpdatedAt.String()
+	}
+	if len(cluster.TargetVersion) > 0 {
+		data["targetVersion"] = cluster.TargetVersion
+		data["upgradeProgress"] = strconv.Itoa(int(cluster.UpgradeProgress))
 	}
 	for i := range cluster.Regions {
 		key := fmt.Sprintf("regions.%v.name", strconv.Itoa(i+1))
@@ -160,4 +523,21 @@ func PopulateInstanceInfo(cluster *Cluster) map[string]string {
 type CreateClusterRequest struct {
 	Name     string           `json:"name"`
 	Provider ApiCloudProvider `json:"provider"`
+	Version  string           `json:"version,omitempty"`
+	Source   *ClusterSource   `json:"source,omitempty"`
+}
+
+// ClusterSource creates the cluster as a clone of another cluster, from one of its backups
+// or at a point in time, instead of empty.
+type ClusterSource struct {
+	ClusterId   string     `json:"cluster_id"`
+	BackupId    string     `json:"backup_id,omitempty"`
+	PointInTime *time.Time `json:"point_in_time,omitempty"`
+}
+
+// RestoreBackupRequest restores a backup to a new cluster.
+type RestoreBackupRequest struct {
+	ClusterId string `json:"cluster_id"`
+	BackupId  string `json:"backup_id"`
+	Name      string `json:"name"`
 }
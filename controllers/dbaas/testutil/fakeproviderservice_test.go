@@ -0,0 +1,339 @@
+package testutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+)
+
+func TestCreateCloudServiceReadsCredentialsWithAPIReader(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	g.Expect(v1beta1.AddToScheme(scheme)).To(Succeed())
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"},
+		Data: map[string][]byte{
+			"CredentialField1": []byte("api-reader-tenant"),
+			"CredentialField2": []byte("secret"),
+		},
+	}
+	// the cached client does not have the credentials secret
+	service := &FakeProviderService{
+		Client:    fake.NewClientBuilder().WithScheme(scheme).Build(),
+		APIReader: fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build(),
+	}
+
+	cloudService, err := service.CreateCloudService(ctx, client.ObjectKeyFromObject(secret))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cloudService).NotTo(BeNil())
+
+	service.APIReader = nil
+	_, err = service.CreateCloudService(ctx, client.ObjectKeyFromObject(secret))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestCreateCloudServiceInjectsAnnotationFaults(t *testing.T) {
+	ctx := context.Background()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"},
+		Data: map[string][]byte{
+			"CredentialField1": []byte(t.Name()),
+			"CredentialField2": []byte("secret"),
+		},
+	}
+	newInventory := func(name, faults string) *v1beta1.ProviderInventory {
+		inventory := &v1beta1.ProviderInventory{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: dbaasv1beta1.DBaaSInventorySpec{
+				CredentialsRef: &dbaasv1beta1.LocalObjectReference{Name: secret.Name},
+			},
+		}
+		if len(faults) != 0 {
+			inventory.Annotations = map[string]string{FaultsAnnotation: faults}
+		}
+		return inventory
+	}
+	tests := []struct {
+		name           string
+		faultInjection bool
+		inventories    []client.Object
+		wantStatus     int
+	}{
+		{
+			name:        "fault injection disabled",
+			inventories: []client.Object{newInventory("inventory", `{"ListClusters": {"failTimes": 1, "statusCode": 503}}`)},
+		},
+		{
+			name:           "no annotation",
+			faultInjection: true,
+			inventories:    []client.Object{newInventory("inventory", "")},
+		},
+		{
+			name:           "annotation",
+			faultInjection: true,
+			inventories:    []client.Object{newInventory("inventory", `{"ListClusters": {"failTimes": 1, "statusCode": 503}}`)},
+			wantStatus:     503,
+		},
+		{
+			name:           "first annotated inventory of the secret",
+			faultInjection: true,
+			inventories: []client.Object{
+				newInventory("inventory-c", `{"ListClusters": {"failTimes": 1, "statusCode": 500}}`),
+				newInventory("inventory-b", `{"ListClusters": {"failTimes": 1, "statusCode": 429}}`),
+				newInventory("inventory-a", ""),
+			},
+			wantStatus: 429,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			scheme := runtime.NewScheme()
+			g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			g.Expect(v1beta1.AddToScheme(scheme)).To(Succeed())
+			service := &FakeProviderService{
+				Client:         fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).WithObjects(tc.inventories...).Build(),
+				FaultInjection: tc.faultInjection,
+			}
+
+			cloudService, err := service.CreateCloudService(ctx, client.ObjectKeyFromObject(secret))
+			g.Expect(err).NotTo(HaveOccurred())
+			_, resp, err := cloudService.ListClusters(ctx)
+			if tc.wantStatus == 0 {
+				g.Expect(cloudService).To(BeAssignableToTypeOf(&FakeAPIClient{}))
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(resp.StatusCode).To(Equal(tc.wantStatus))
+		})
+	}
+}
+
+func TestSetSqlUserPasswordCreatesMissingUser(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	cloudService := NewFakeAPIClientForTenant(t.Name())
+	cluster, _, err := cloudService.CreateCluster(ctx, &CreateClusterRequest{Name: "sql-user-password", Provider: APICLOUDPROVIDER_AWS})
+	g.Expect(err).NotTo(HaveOccurred())
+	service := &FakeProviderService{}
+
+	g.Expect(service.SetSqlUserPassword(ctx, cloudService, cluster.Id, "user1", "first")).To(Succeed())
+	g.Expect(service.SetSqlUserPassword(ctx, cloudService, cluster.Id, "user1", "second")).To(Succeed())
+	g.Expect(cloudService.users[cluster.Id]).To(Equal([]SqlUser{{Name: "user1", Password: "second"}}))
+
+	g.Expect(service.SetSqlUserPassword(ctx, cloudService, "missing", "user1", "first")).NotTo(Succeed())
+}
+
+func TestGetCloneSource(t *testing.T) {
+	pointInTime := time.Date(2023, time.March, 15, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		params  map[dbaasv1beta1.ProvisioningParameterType]string
+		want    *ClusterSource
+		wantErr bool
+	}{
+		{
+			name: "no source",
+		},
+		{
+			name:   "instance",
+			params: map[dbaasv1beta1.ProvisioningParameterType]string{v1beta1.ProvisioningSourceInstanceID: "source"},
+			want:   &ClusterSource{ClusterId: "source"},
+		},
+		{
+			name: "backup",
+			params: map[dbaasv1beta1.ProvisioningParameterType]string{
+				v1beta1.ProvisioningSourceInstanceID: "source",
+				v1beta1.ProvisioningSourceBackupID:   "backup",
+			},
+			want: &ClusterSource{ClusterId: "source", BackupId: "backup"},
+		},
+		{
+			name: "point in time",
+			params: map[dbaasv1beta1.ProvisioningParameterType]string{
+				v1beta1.ProvisioningSourceInstanceID:  "source",
+				v1beta1.ProvisioningSourcePointInTime: "2023-03-15T13:30:00+01:00",
+			},
+			want: &ClusterSource{ClusterId: "source", PointInTime: &pointInTime},
+		},
+		{
+			name:    "backup without instance",
+			params:  map[dbaasv1beta1.ProvisioningParameterType]string{v1beta1.ProvisioningSourceBackupID: "backup"},
+			wantErr: true,
+		},
+		{
+			name: "backup and point in time",
+			params: map[dbaasv1beta1.ProvisioningParameterType]string{
+				v1beta1.ProvisioningSourceInstanceID:  "source",
+				v1beta1.ProvisioningSourceBackupID:    "backup",
+				v1beta1.ProvisioningSourcePointInTime: "2023-03-15T12:30:00Z",
+			},
+			wantErr: true,
+		},
+		{
+			name: "malformed point in time",
+			params: map[dbaasv1beta1.ProvisioningParameterType]string{
+				v1beta1.ProvisioningSourceInstanceID:  "source",
+				v1beta1.ProvisioningSourcePointInTime: "yesterday",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			instance := &v1beta1.ProviderInstance{Spec: v1beta1.ProviderInstanceSpec{DBaaSInstanceSpec: dbaasv1beta1.DBaaSInstanceSpec{ProvisioningParameters: tc.params}}}
+			source, err := GetCloneSource(instance)
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			if tc.want == nil {
+				g.Expect(source).To(BeNil())
+				return
+			}
+			g.Expect(source.ClusterId).To(Equal(tc.want.ClusterId))
+			g.Expect(source.BackupId).To(Equal(tc.want.BackupId))
+			if tc.want.PointInTime == nil {
+				g.Expect(source.PointInTime).To(BeNil())
+			} else {
+				g.Expect(source.PointInTime.Equal(*tc.want.PointInTime)).To(BeTrue())
+			}
+		})
+	}
+}
+
+func TestValidateCloneSource(t *testing.T) {
+	now := time.Date(2023, time.March, 15, 12, 30, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	backups := []Backup{
+		{Id: "completed", ClusterId: "source", State: BACKUPSTATETYPE_COMPLETED},
+		{Id: "in-progress", ClusterId: "source", State: BACKUPSTATETYPE_IN_PROGRESS},
+		{Id: "failed", ClusterId: "source", State: BACKUPSTATETYPE_FAILED},
+	}
+	tests := []struct {
+		name    string
+		source  ClusterSource
+		wantErr bool
+	}{
+		{
+			name:   "instance",
+			source: ClusterSource{ClusterId: "source"},
+		},
+		{
+			name:   "completed backup",
+			source: ClusterSource{ClusterId: "source", BackupId: "completed"},
+		},
+		{
+			name:    "backup in progress",
+			source:  ClusterSource{ClusterId: "source", BackupId: "in-progress"},
+			wantErr: true,
+		},
+		{
+			name:    "failed backup",
+			source:  ClusterSource{ClusterId: "source", BackupId: "failed"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown backup",
+			source:  ClusterSource{ClusterId: "source", BackupId: "unknown"},
+			wantErr: true,
+		},
+		{
+			name:   "past point in time",
+			source: ClusterSource{ClusterId: "source", PointInTime: &past},
+		},
+		{
+			name:    "future point in time",
+			source:  ClusterSource{ClusterId: "source", PointInTime: &future},
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := ValidateCloneSource(&tc.source, backups, now)
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestValidateVersionUpgrade(t *testing.T) {
+	versions := []ClusterVersion{{Version: "v14.9"}, {Version: "v15.3"}, {Version: "v15.4"}, {Version: "v16.1"}, {Version: "v17.0"}}
+	tests := []struct {
+		name    string
+		current string
+		target  string
+		wantErr bool
+	}{
+		{name: "minor upgrade", current: "v15.3", target: "v15.4"},
+		{name: "next major version", current: "v15.4", target: "v16.1"},
+		{name: "same version", current: "v15.4", target: "v15.4"},
+		{name: "not offered", current: "v15.3", target: "v15.5", wantErr: true},
+		{name: "minor downgrade", current: "v15.4", target: "v15.3", wantErr: true},
+		{name: "major downgrade", current: "v15.3", target: "v14.9", wantErr: true},
+		{name: "skipped major version", current: "v15.4", target: "v17.0", wantErr: true},
+		{name: "malformed current version", current: "15", target: "v15.4", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := ValidateVersionUpgrade(versions, tc.current, tc.target)
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version   string
+		wantMajor int
+		wantMinor int
+		wantErr   bool
+	}{
+		{version: "v15.4", wantMajor: 15, wantMinor: 4},
+		{version: "v9.12", wantMajor: 9, wantMinor: 12},
+		{version: "v15", wantErr: true},
+		{version: "15.4", wantErr: true},
+		{version: "v15.x", wantErr: true},
+		{version: "", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.version, func(t *testing.T) {
+			g := NewWithT(t)
+			major, minor, err := parseVersion(tc.version)
+			if tc.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(major).To(Equal(tc.wantMajor))
+			g.Expect(minor).To(Equal(tc.wantMinor))
+		})
+	}
+}
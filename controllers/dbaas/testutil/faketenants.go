@@ -0,0 +1,170 @@
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FixturesEnvVar names a JSON file with the clusters the fake provider stores of the tenants are seeded with.
+// The file maps a tenant, the CredentialField1 of its credentials, to its clusters; the "*" entry seeds
+// the tenants without an entry of their own.
+const FixturesEnvVar = "FAKE_PROVIDER_FIXTURES"
+
+// defaultFixturesTenant is the fixtures entry of the tenants without an entry of their own.
+const defaultFixturesTenant = "*"
+
+// tenants holds a separate cluster store per tenant of the fake provider.
+var tenants = struct {
+	sync.Mutex
+	stores            map[string]*FakeClusters
+	fixtures          map[string][]Cluster
+	provisioningDelay time.Duration
+}{
+	stores:   map[string]*FakeClusters{},
+	fixtures: map[string][]Cluster{defaultFixturesTenant: {cluster1, cluster2, cluster3}},
+}
+
+// NewFakeAPIClientForTenant returns a client of the cluster store of the tenant, which is seeded
+// from the fixtures when the tenant is first used.
+func NewFakeAPIClientForTenant(tenant string) *FakeAPIClient {
+	tenants.Lock()
+	defer tenants.Unlock()
+	store, ok := tenants.stores[tenant]
+	if !ok {
+		fixtures, ok := tenants.fixtures[tenant]
+		if !ok {
+			fixtures = tenants.fixtures[defaultFixturesTenant]
+		}
+		store = newFakeClusters(fixtures)
+		store.provisioningDelay = tenants.provisioningDelay
+		tenants.stores[tenant] = store
+	}
+	return &FakeAPIClient{FakeClusters: store}
+}
+
+// SetFixtures replaces the fixtures of the tenants, and resets the stores of all tenants to them.
+func SetFixtures(fixtures map[string][]Cluster) {
+	tenants.Lock()
+	defer tenants.Unlock()
+	tenants.fixtures = fixtures
+	tenants.stores = map[string]*FakeClusters{}
+}
+
+// LoadFixtures sets the fixtures of the tenants from a JSON file in the FixturesEnvVar format.
+func LoadFixtures(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	fixtures := map[string][]Cluster{}
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return fmt.Errorf("invalid fake provider fixtures %v: %w", path, err)
+	}
+	for tenant, clusters := range fixtures {
+		for i := range clusters {
+			if len(clusters[i].Id) == 0 {
+				return fmt.Errorf("invalid fake provider fixtures %v: cluster %d of tenant %q has no id", path, i, tenant)
+			}
+			if clusters[i].CreatedAt == nil {
+				clusters[i].CreatedAt = &aDate
+			}
+			if clusters[i].UpdatedAt == nil {
+				clusters[i].UpdatedAt = clusters[i].CreatedAt
+			}
+		}
+	}
+	SetFixtures(fixtures)
+	return nil
+}
+
+// TenantState is the state of the cluster store of a tenant.
+type TenantState struct {
+	Clusters []Cluster            `json:"clusters"`
+	Backups  []Backup             `json:"backups,omitempty"`
+	Users    map[string][]SqlUser `json:"users,omitempty"`
+	// BackupSequence is the number of backups created in the store
+	BackupSequence int `json:"backupSequence,omitempty"`
+}
+
+// SnapshotTenants returns the state of the stores of all tenants.
+func SnapshotTenants() map[string]TenantState {
+	tenants.Lock()
+	defer tenants.Unlock()
+	state := map[string]TenantState{}
+	for tenant, store := range tenants.stores {
+		state[tenant] = store.snapshot()
+	}
+	return state
+}
+
+// RestoreTenants replaces the stores of the tenants in the state with stores of the state.
+func RestoreTenants(state map[string]TenantState) {
+	tenants.Lock()
+	defer tenants.Unlock()
+	for tenant, tenantState := range state {
+		store := newFakeClusters(tenantState.Clusters)
+		store.backups = append(store.backups, tenantState.Backups...)
+		store.backupSequence = backupSequence(tenantState)
+		for clusterID, users := range tenantState.Users {
+			store.users[clusterID] = append([]SqlUser{}, users...)
+		}
+		store.provisioningDelay = tenants.provisioningDelay
+		tenants.stores[tenant] = store
+	}
+}
+
+// SetProvisioningDelay makes the new clusters of all tenants stay in the CREATING state for the delay.
+func SetProvisioningDelay(delay time.Duration) {
+	tenants.Lock()
+	defer tenants.Unlock()
+	tenants.provisioningDelay = delay
+	for _, store := range tenants.stores {
+		store.SetProvisioningDelay(delay)
+	}
+}
+
+// snapshot returns the state of the store, including the writes that are not yet visible.
+func (f *FakeClusters) snapshot() TenantState {
+	f.clusterMutex.Lock()
+	defer f.clusterMutex.Unlock()
+	state := TenantState{Clusters: []Cluster{}, Users: map[string][]SqlUser{}}
+	for _, c := range f.clusters {
+		if latest := f.latestCluster(c.Id); latest != nil {
+			state.Clusters = append(state.Clusters, *latest)
+		}
+	}
+	for _, write := range f.pending {
+		if write.cluster != nil && f.indexOfCluster(write.cluster.Id) < 0 {
+			if latest := f.latestCluster(write.cluster.Id); latest != nil {
+				state.Clusters = append(state.Clusters, *latest)
+			}
+		}
+	}
+	state.Backups = append(state.Backups, f.backups...)
+	state.BackupSequence = f.backupSequence
+	for clusterID, users := range f.users {
+		state.Users[clusterID] = append([]SqlUser{}, users...)
+	}
+	return state
+}
+
+// backupSequence returns the backup sequence of the state, at least the highest sequence number of its backup IDs,
+// so that the states saved without a sequence do not reuse the IDs of their backups.
+func backupSequence(state TenantState) int {
+	sequence := state.BackupSequence
+	for _, backup := range state.Backups {
+		i := strings.LastIndex(backup.Id, "-backup-")
+		if i < 0 {
+			continue
+		}
+		if n, err := strconv.Atoi(backup.Id[i+len("-backup-"):]); err == nil && n > sequence {
+			sequence = n
+		}
+	}
+	return sequence
+}
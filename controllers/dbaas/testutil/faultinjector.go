@@ -0,0 +1,391 @@
+package testutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FaultsAnnotation on a ProviderInventory injects faults into the calls of the fake provider API made with
+// the inventory credentials. Its value is a JSON object of Fault specs keyed by Service method name, for example
+// {"CreateCluster": {"failTimes": 2, "statusCode": 503}, "GetCluster": {"latency": "2s", "errorRate": 0.1}}.
+const FaultsAnnotation = "dbaas.redhat.com/fake-faults"
+
+// Fault is a failure injected into the calls of a Service method.
+type Fault struct {
+	// The probability in [0, 1] that a call fails.
+	ErrorRate float64 `json:"errorRate,omitempty"`
+	// The number of first calls that fail, the calls after them succeed.
+	FailTimes int `json:"failTimes,omitempty"`
+	// The HTTP status of the failed calls, 500 if not set.
+	StatusCode int `json:"statusCode,omitempty"`
+	// The delay of every call, a call fails with the context error if its context ends before.
+	Latency Duration `json:"latency,omitempty"`
+	// Makes a failed call take effect at the provider before failing, like a call whose response is lost.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// Duration is a time.Duration in the time.ParseDuration format in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// FaultInjector holds the faults injected into the calls of the Service methods, and counts the calls.
+type FaultInjector struct {
+	mutex  sync.Mutex
+	faults map[string]Fault
+	calls  map[string]int
+	rand   *rand.Rand
+}
+
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{
+		faults: map[string]Fault{},
+		calls:  map[string]int{},
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// ParseFaults parses the value of the FaultsAnnotation.
+func ParseFaults(value string) (map[string]Fault, error) {
+	faults := map[string]Fault{}
+	if err := json.Unmarshal([]byte(value), &faults); err != nil {
+		return nil, fmt.Errorf("invalid %v annotation: %w", FaultsAnnotation, err)
+	}
+	for method, fault := range faults {
+		if !faultMethods[method] {
+			return nil, fmt.Errorf("invalid %v annotation: unknown method %q", FaultsAnnotation, method)
+		}
+		if fault.ErrorRate < 0 || fault.ErrorRate > 1 {
+			return nil, fmt.Errorf("invalid %v annotation: errorRate of %v is not in [0, 1]", FaultsAnnotation, method)
+		}
+	}
+	return faults, nil
+}
+
+// SetFault injects the fault into the calls of the method, and resets the call count of the method.
+func (i *FaultInjector) SetFault(method string, fault Fault) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.faults[method] = fault
+	delete(i.calls, method)
+}
+
+// ClearFaults removes all faults and call counts.
+func (i *FaultInjector) ClearFaults() {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.faults = map[string]Fault{}
+	i.calls = map[string]int{}
+}
+
+// Calls returns the number of calls of the method since its fault was set.
+func (i *FaultInjector) Calls(method string) int {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.calls[method]
+}
+
+// injectedFault is the failure of a single call.
+type injectedFault struct {
+	partial  bool
+	response *http.Response
+	err      error
+}
+
+// inject waits the latency of the fault of the method, and returns the failure of the call, or nil if the call succeeds.
+func (i *FaultInjector) inject(ctx context.Context, method string) *injectedFault {
+	if i == nil {
+		return nil
+	}
+	i.mutex.Lock()
+	fault, ok := i.faults[method]
+	i.calls[method]++
+	calls := i.calls[method]
+	fail := ok && (calls <= fault.FailTimes || (fault.ErrorRate > 0 && i.rand.Float64() < fault.ErrorRate))
+	i.mutex.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if fault.Latency > 0 {
+		timer := time.NewTimer(time.Duration(fault.Latency))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return &injectedFault{response: buildFaultResponse(http.StatusGatewayTimeout), err: ctx.Err()}
+		}
+	}
+	if !fail {
+		return nil
+	}
+
+	statusCode := fault.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusInternalServerError
+	}
+	return &injectedFault{
+		partial:  fault.Partial,
+		response: buildFaultResponse(statusCode),
+		err:      fmt.Errorf("{\"code\": 14, \"message\": \"injected fault: %d %s\"}", statusCode, http.StatusText(statusCode)),
+	}
+}
+
+func buildFaultResponse(statusCode int) *http.Response {
+	resp := &http.Response{
+		Status:     fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode: statusCode,
+		Proto:      "HTTP/1.1",
+		Body:       io.NopCloser(strings.NewReader(fmt.Sprintf("{\"code\": 14, \"message\": \"injected fault: %d\"}", statusCode))),
+		Header:     make(http.Header),
+	}
+	if statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable {
+		resp.Header.Set("Retry-After", strconv.Itoa(1))
+	}
+	return resp
+}
+
+// faultMethods are the Service methods faults can be injected into.
+var faultMethods = map[string]bool{
+	"ListClusters":           true,
+	"CreateCluster":          true,
+	"GetCluster":             true,
+	"DeleteCluster":          true,
+	"SuspendCluster":         true,
+	"ResumeCluster":          true,
+	"ListVersions":           true,
+	"UpgradeCluster":         true,
+	"RollbackClusterUpgrade": true,
+	"CreateBackup":           true,
+	"ListBackups":            true,
+	"GetBackup":              true,
+	"DeleteBackup":           true,
+	"RestoreBackup":          true,
+	"CreateSqlUser":          true,
+	"ResetSqlUserPassword":   true,
+}
+
+// NewFaultyService returns a Service that injects the faults of the injector into the calls of service.
+func NewFaultyService(service Service, faults *FaultInjector) Service {
+	return &faultyService{Service: service, faults: faults}
+}
+
+type faultyService struct {
+	Service
+	faults *FaultInjector
+}
+
+func (s *faultyService) ListClusters(ctx context.Context) (*ListClustersResponse, *http.Response, error) {
+	fault := s.faults.inject(ctx, "ListClusters")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	clusters, resp, err := s.Service.ListClusters(ctx)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return clusters, resp, err
+}
+
+func (s *faultyService) CreateCluster(ctx context.Context, createClusterRequest *CreateClusterRequest) (*Cluster, *http.Response, error) {
+	fault := s.faults.inject(ctx, "CreateCluster")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	cluster, resp, err := s.Service.CreateCluster(ctx, createClusterRequest)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return cluster, resp, err
+}
+
+func (s *faultyService) GetCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	fault := s.faults.inject(ctx, "GetCluster")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	cluster, resp, err := s.Service.GetCluster(ctx, clusterID)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return cluster, resp, err
+}
+
+func (s *faultyService) DeleteCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	fault := s.faults.inject(ctx, "DeleteCluster")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	cluster, resp, err := s.Service.DeleteCluster(ctx, clusterID)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return cluster, resp, err
+}
+
+func (s *faultyService) SuspendCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	fault := s.faults.inject(ctx, "SuspendCluster")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	cluster, resp, err := s.Service.SuspendCluster(ctx, clusterID)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return cluster, resp, err
+}
+
+func (s *faultyService) ResumeCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	fault := s.faults.inject(ctx, "ResumeCluster")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	cluster, resp, err := s.Service.ResumeCluster(ctx, clusterID)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return cluster, resp, err
+}
+
+func (s *faultyService) ListVersions(ctx context.Context) (*ListVersionsResponse, *http.Response, error) {
+	fault := s.faults.inject(ctx, "ListVersions")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	versions, resp, err := s.Service.ListVersions(ctx)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return versions, resp, err
+}
+
+func (s *faultyService) UpgradeCluster(ctx context.Context, clusterID, version string) (*Cluster, *http.Response, error) {
+	fault := s.faults.inject(ctx, "UpgradeCluster")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	cluster, resp, err := s.Service.UpgradeCluster(ctx, clusterID, version)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return cluster, resp, err
+}
+
+func (s *faultyService) RollbackClusterUpgrade(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	fault := s.faults.inject(ctx, "RollbackClusterUpgrade")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	cluster, resp, err := s.Service.RollbackClusterUpgrade(ctx, clusterID)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return cluster, resp, err
+}
+
+func (s *faultyService) CreateBackup(ctx context.Context, clusterID string) (*Backup, *http.Response, error) {
+	fault := s.faults.inject(ctx, "CreateBackup")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	backup, resp, err := s.Service.CreateBackup(ctx, clusterID)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return backup, resp, err
+}
+
+func (s *faultyService) ListBackups(ctx context.Context, clusterID string) (*ListBackupsResponse, *http.Response, error) {
+	fault := s.faults.inject(ctx, "ListBackups")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	backups, resp, err := s.Service.ListBackups(ctx, clusterID)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return backups, resp, err
+}
+
+func (s *faultyService) GetBackup(ctx context.Context, clusterID, backupID string) (*Backup, *http.Response, error) {
+	fault := s.faults.inject(ctx, "GetBackup")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	backup, resp, err := s.Service.GetBackup(ctx, clusterID, backupID)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return backup, resp, err
+}
+
+func (s *faultyService) DeleteBackup(ctx context.Context, clusterID, backupID string) (*Backup, *http.Response, error) {
+	fault := s.faults.inject(ctx, "DeleteBackup")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	backup, resp, err := s.Service.DeleteBackup(ctx, clusterID, backupID)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return backup, resp, err
+}
+
+func (s *faultyService) CreateSqlUser(ctx context.Context, clusterID string, user SqlUser) (*SqlUser, *http.Response, error) {
+	fault := s.faults.inject(ctx, "CreateSqlUser")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	created, resp, err := s.Service.CreateSqlUser(ctx, clusterID, user)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return created, resp, err
+}
+
+func (s *faultyService) ResetSqlUserPassword(ctx context.Context, clusterID, name, password string) (*SqlUser, *http.Response, error) {
+	fault := s.faults.inject(ctx, "ResetSqlUserPassword")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	user, resp, err := s.Service.ResetSqlUserPassword(ctx, clusterID, name, password)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return user, resp, err
+}
+
+func (s *faultyService) RestoreBackup(ctx context.Context, restoreBackupRequest *RestoreBackupRequest) (*Cluster, *http.Response, error) {
+	fault := s.faults.inject(ctx, "RestoreBackup")
+	if fault != nil && !fault.partial {
+		return nil, fault.response, fault.err
+	}
+	cluster, resp, err := s.Service.RestoreBackup(ctx, restoreBackupRequest)
+	if fault != nil && err == nil {
+		return nil, fault.response, fault.err
+	}
+	return cluster, resp, err
+}
@@ -0,0 +1,92 @@
+package testutil
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFaultFailTimesThenSucceed(t *testing.T) {
+	g := NewWithT(t)
+	faults := NewFaultInjector()
+	faults.SetFault("ListVersions", Fault{FailTimes: 2, StatusCode: http.StatusServiceUnavailable})
+	service := NewFaultyService(NewFakeAPIClient(), faults)
+
+	for i := 0; i < 2; i++ {
+		_, resp, err := service.ListVersions(context.Background())
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+		g.Expect(resp.Header.Get("Retry-After")).NotTo(BeEmpty())
+	}
+	versions, _, err := service.ListVersions(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(versions.Versions).NotTo(BeEmpty())
+	g.Expect(faults.Calls("ListVersions")).To(Equal(3))
+}
+
+func TestFaultErrorRate(t *testing.T) {
+	g := NewWithT(t)
+	faults := NewFaultInjector()
+	faults.SetFault("ListVersions", Fault{ErrorRate: 1, StatusCode: http.StatusTooManyRequests})
+	service := NewFaultyService(NewFakeAPIClient(), faults)
+
+	_, resp, err := service.ListVersions(context.Background())
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+
+	faults.ClearFaults()
+	_, _, err = service.ListVersions(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestFaultLatencyTimeout(t *testing.T) {
+	g := NewWithT(t)
+	faults := NewFaultInjector()
+	faults.SetFault("ListVersions", Fault{Latency: Duration(time.Minute)})
+	service := NewFaultyService(NewFakeAPIClient(), faults)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, resp, err := service.ListVersions(ctx)
+	g.Expect(err).To(MatchError(context.DeadlineExceeded))
+	g.Expect(resp.StatusCode).To(Equal(http.StatusGatewayTimeout))
+}
+
+func TestFaultPartial(t *testing.T) {
+	g := NewWithT(t)
+	faults := NewFaultInjector()
+	faults.SetFault("CreateCluster", Fault{FailTimes: 1, Partial: true})
+	service := NewFaultyService(NewFakeAPIClient(), faults)
+
+	request := &CreateClusterRequest{Name: "fault-partial-test", Provider: APICLOUDPROVIDER_AWS}
+	_, resp, err := service.CreateCluster(context.Background(), request)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+
+	// the cluster was created even though the call failed
+	g.Eventually(func() error {
+		_, _, err := service.GetCluster(context.Background(), "a-cluster-instance-id-fault-partial-test")
+		return err
+	}).Should(Succeed())
+}
+
+func TestParseFaults(t *testing.T) {
+	g := NewWithT(t)
+
+	faults, err := ParseFaults(`{"CreateCluster": {"failTimes": 2, "statusCode": 503}, "GetCluster": {"latency": "2s", "errorRate": 0.1}}`)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(faults).To(Equal(map[string]Fault{
+		"CreateCluster": {FailTimes: 2, StatusCode: 503},
+		"GetCluster":    {Latency: Duration(2 * time.Second), ErrorRate: 0.1},
+	}))
+
+	_, err = ParseFaults(`{"CreateClusters": {"failTimes": 2}}`)
+	g.Expect(err).To(HaveOccurred())
+	_, err = ParseFaults(`{"GetCluster": {"errorRate": 2}}`)
+	g.Expect(err).To(HaveOccurred())
+	_, err = ParseFaults(`{"GetCluster": {"latency": "soon"}}`)
+	g.Expect(err).To(HaveOccurred())
+}
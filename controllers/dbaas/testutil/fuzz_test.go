@@ -0,0 +1,159 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+)
+
+func FuzzPopulateInstanceInfo(f *testing.F) {
+	seed, _ := json.Marshal(cluster1)
+	f.Add(seed)
+	f.Add([]byte(`{}`))
+	f.Add([]byte(`{"regions": [{"name": "region-1"}, {"name": "region-2", "sql_dns": "dns"}], "target_version": "v16.1"}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var cluster Cluster
+		if err := json.Unmarshal(data, &cluster); err != nil {
+			return
+		}
+		info := PopulateInstanceInfo(&cluster)
+		if info["numOfRegions"] != fmt.Sprint(len(cluster.Regions)) {
+			t.Errorf("numOfRegions is %q for %d regions", info["numOfRegions"], len(cluster.Regions))
+		}
+		for i, region := range cluster.Regions {
+			if name := info[fmt.Sprintf("regions.%d.name", i+1)]; name != region.Name {
+				t.Errorf("the name of region %d is %q instead of %q", i+1, name, region.Name)
+			}
+		}
+		if info["Version"] != cluster.Version || info["state"] != string(cluster.State) {
+			t.Errorf("the instance info %v does not match the cluster %+v", info, cluster)
+		}
+		if _, ok := info["targetVersion"]; ok != (len(cluster.TargetVersion) > 0) {
+			t.Errorf("the instance info %v reports the target version %q", info, cluster.TargetVersion)
+		}
+	})
+}
+
+func FuzzProvisioningParameters(f *testing.F) {
+	f.Add("cluster", "AWS", "v15.4", "", "", "")
+	f.Add("clone", "GCP", "", "a-cluster-instance-1-id", "backup-1", "")
+	f.Add("clone", "", "", "a-cluster-instance-1-id", "", "2022-12-14T14:38:46Z")
+	f.Add("", "", "", "", "backup-1", "not a time")
+
+	f.Fuzz(func(t *testing.T, name, provider, version, sourceID, backupID, pointInTime string) {
+		params := map[dbaasv1beta1.ProvisioningParameterType]string{}
+		for key, value := range map[dbaasv1beta1.ProvisioningParameterType]string{
+			dbaasv1beta1.ProvisioningName:          name,
+			dbaasv1beta1.ProvisioningCloudProvider: provider,
+			v1beta1.ProvisioningVersion:            version,
+			v1beta1.ProvisioningSourceInstanceID:   sourceID,
+			v1beta1.ProvisioningSourceBackupID:     backupID,
+			v1beta1.ProvisioningSourcePointInTime:  pointInTime,
+		} {
+			if len(value) > 0 {
+				params[key] = value
+			}
+		}
+		instance := &v1beta1.ProviderInstance{Spec: v1beta1.ProviderInstanceSpec{DBaaSInstanceSpec: dbaasv1beta1.DBaaSInstanceSpec{ProvisioningParameters: params}}}
+
+		if got := getClusterParameter(instance, dbaasv1beta1.ProvisioningName); got != name {
+			t.Errorf("the name parameter is %q instead of %q", got, name)
+		}
+		source, err := GetCloneSource(instance)
+		if err != nil {
+			return
+		}
+		if len(sourceID) == 0 {
+			if source != nil {
+				t.Errorf("a clone source %+v without a source instance", source)
+			}
+			return
+		}
+		if source.ClusterId != sourceID || source.BackupId != backupID {
+			t.Errorf("the clone source %+v does not match the parameters", source)
+		}
+		if (source.PointInTime != nil) != (len(pointInTime) > 0) {
+			t.Errorf("the clone source point in time %v does not match the parameter %q", source.PointInTime, pointInTime)
+		}
+	})
+}
+
+// roundTripFunc is a transport answering the requests with a function.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func FuzzClientResponseDecoding(f *testing.F) {
+	seed, _ := json.Marshal(cluster1)
+	f.Add(200, seed)
+	f.Add(404, []byte(`{"code": 5, "message": "code = NotFound"}`))
+	f.Add(200, []byte(`{"clusters": [{"id": "1"}, null]}`))
+	f.Add(500, []byte{})
+
+	f.Fuzz(func(t *testing.T, statusCode int, body []byte) {
+		if statusCode < 100 || statusCode > 999 {
+			return
+		}
+		client := NewClient("http://provider.invalid", "fuzz")
+		client.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: statusCode, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(body)), Request: req}, nil
+		}))
+
+		cluster, resp, err := client.GetCluster(context.Background(), "cluster")
+		if resp == nil || resp.StatusCode != statusCode {
+			t.Fatalf("the response %v does not have the status code %d", resp, statusCode)
+		}
+		if statusCode >= http.StatusMultipleChoices && err == nil {
+			t.Errorf("no error for the status code %d", statusCode)
+		}
+		if (err == nil) == (cluster == nil) {
+			t.Errorf("the cluster %v and the error %v are both set or both unset", cluster, err)
+		}
+		clusters, _, err := client.ListClusters(context.Background())
+		if err == nil && clusters == nil {
+			t.Errorf("no clusters and no error")
+		}
+	})
+}
+
+func FuzzMockProviderRequests(f *testing.F) {
+	f.Add("POST", "clusters", `{"name": "fuzz", "provider": "AWS"}`)
+	f.Add("GET", "clusters/a-cluster-instance-1-id", "")
+	f.Add("POST", "clusters/a-cluster-instance-1-id/upgrade", `{"version": "v16.1"}`)
+	f.Add("POST", "clusters/a-cluster-instance-1-id/backups/1/restore", `{"name": 1}`)
+	f.Add("DELETE", "clusters/a-cluster-instance-1-id/sql-users/user1", "")
+	f.Add("GET", "", "")
+
+	provider := &MockProvider{}
+	f.Fuzz(func(t *testing.T, method, path, body string) {
+		req, err := http.NewRequest(method, "http://provider.invalid/api/v1/"+path, strings.NewReader(body))
+		if err != nil {
+			return
+		}
+		req.Header.Set("Authorization", "Bearer fuzz-mock-provider")
+		recorder := httptest.NewRecorder()
+		provider.ServeHTTP(recorder, req)
+
+		if recorder.Code >= http.StatusBadRequest {
+			var errorBody mockError
+			if err := json.Unmarshal(recorder.Body.Bytes(), &errorBody); err != nil || len(errorBody.Message) == 0 {
+				t.Errorf("the error response %d has the body %q", recorder.Code, recorder.Body.String())
+			}
+		} else if !json.Valid(recorder.Body.Bytes()) {
+			t.Errorf("the response %d has the body %q", recorder.Code, recorder.Body.String())
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+var _ Service = &Client{}
+
+// NewClient returns a client of the provider REST API at serverURL, authenticated with the API key.
+// Every request is recorded as a span by the global tracer provider.
+func NewClient(serverURL, apiKey string) *Client {
+	return &Client{
+		cfg: &Configuration{
+			ServerURL:     strings.TrimSuffix(serverURL, "/"),
+			DefaultHeader: map[string]string{},
+			UserAgent:     "provider-operator-example",
+			HTTPClient:    &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+			apiKey:        apiKey,
+		},
+	}
+}
+
+// do sends the request with the JSON of body, if any, and decodes the JSON response into result.
+// A response with an error status is returned with the error of its body, with its secrets redacted.
+func (c *Client) do(ctx context.Context, method, path string, body, result interface{}) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.cfg.ServerURL+"/api/v1/"+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.cfg.UserAgent)
+	for key, value := range c.cfg.DefaultHeader {
+		req.Header.Set(key, value)
+	}
+	if len(c.cfg.apiKey) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.apiKey)
+	}
+
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, err
+	}
+	// the body was read, keep it readable for the caller
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		// the error is logged, leave out the secrets the provider may echo
+		return resp, fmt.Errorf("%s", Redact(string(bytes.TrimSpace(data))))
+	}
+	if result != nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			return resp, fmt.Errorf("invalid response of %v %v: %w", method, path, err)
+		}
+	}
+	return resp, nil
+}
+
+func clusterPath(clusterID string, elems ...string) string {
+	return strings.Join(append([]string{"clusters", url.PathEscape(clusterID)}, elems...), "/")
+}
+
+func backupPath(clusterID, backupID string, elems ...string) string {
+	return clusterPath(clusterID, append([]string{"backups", url.PathEscape(backupID)}, elems...)...)
+}
+
+func (c *Client) ListClusters(ctx context.Context) (*ListClustersResponse, *http.Response, error) {
+	clusters := &ListClustersResponse{}
+	resp, err := c.do(ctx, http.MethodGet, "clusters", nil, clusters)
+	if err != nil {
+		return nil, resp, err
+	}
+	return clusters, resp, nil
+}
+
+func (c *Client) CreateCluster(ctx context.Context, createClusterRequest *CreateClusterRequest) (*Cluster, *http.Response, error) {
+	return c.doCluster(ctx, http.MethodPost, "clusters", createClusterRequest)
+}
+
+func (c *Client) GetCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	return c.doCluster(ctx, http.MethodGet, clusterPath(clusterID), nil)
+}
+
+func (c *Client) DeleteCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	return c.doCluster(ctx, http.MethodDelete, clusterPath(clusterID), nil)
+}
+
+func (c *Client) SuspendCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	return c.doCluster(ctx, http.MethodPost, clusterPath(clusterID, "suspend"), nil)
+}
+
+func (c *Client) ResumeCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	return c.doCluster(ctx, http.MethodPost, clusterPath(clusterID, "resume"), nil)
+}
+
+func (c *Client) ListVersions(ctx context.Context) (*ListVersionsResponse, *http.Response, error) {
+	versions := &ListVersionsResponse{}
+	resp, err := c.do(ctx, http.MethodGet, "versions", nil, versions)
+	if err != nil {
+		return nil, resp, err
+	}
+	return versions, resp, nil
+}
+
+func (c *Client) UpgradeCluster(ctx context.Context, clusterID, version string) (*Cluster, *http.Response, error) {
+	return c.doCluster(ctx, http.MethodPost, clusterPath(clusterID, "upgrade"), &UpgradeClusterRequest{Version: version})
+}
+
+func (c *Client) RollbackClusterUpgrade(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	return c.doCluster(ctx, http.MethodPost, clusterPath(clusterID, "rollback"), nil)
+}
+
+func (c *Client) CreateBackup(ctx context.Context, clusterID string) (*Backup, *http.Response, error) {
+	return c.doBackup(ctx, http.MethodPost, clusterPath(clusterID, "backups"), nil)
+}
+
+func (c *Client) ListBackups(ctx context.Context, clusterID string) (*ListBackupsResponse, *http.Response, error) {
+	backups := &ListBackupsResponse{}
+	resp, err := c.do(ctx, http.MethodGet, clusterPath(clusterID, "backups"), nil, backups)
+	if err != nil {
+		return nil, resp, err
+	}
+	return backups, resp, nil
+}
+
+func (c *Client) GetBackup(ctx context.Context, clusterID, backupID string) (*Backup, *http.Response, error) {
+	return c.doBackup(ctx, http.MethodGet, backupPath(clusterID, backupID), nil)
+}
+
+func (c *Client) DeleteBackup(ctx context.Context, clusterID, backupID string) (*Backup, *http.Response, error) {
+	return c.doBackup(ctx, http.MethodDelete, backupPath(clusterID, backupID), nil)
+}
+
+func (c *Client) RestoreBackup(ctx context.Context, restoreBackupRequest *RestoreBackupRequest) (*Cluster, *http.Response, error) {
+	return c.doCluster(ctx, http.MethodPost, backupPath(restoreBackupRequest.ClusterId, restoreBackupRequest.BackupId, "restore"), restoreBackupRequest)
+}
+
+func (c *Client) CreateSqlUser(ctx context.Context, clusterID string, user SqlUser) (*SqlUser, *http.Response, error) {
+	return c.doSqlUser(ctx, http.MethodPost, clusterPath(clusterID, "sql-users"), &user)
+}
+
+func (c *Client) ResetSqlUserPassword(ctx context.Context, clusterID, name, password string) (*SqlUser, *http.Response, error) {
+	return c.doSqlUser(ctx, http.MethodPost, clusterPath(clusterID, "sql-users", url.PathEscape(name), "reset-password"),
+		&ResetSqlUserPasswordRequest{Password: password})
+}
+
+func (c *Client) doCluster(ctx context.Context, method, path string, body interface{}) (*Cluster, *http.Response, error) {
+	cluster := &Cluster{}
+	resp, err := c.do(ctx, method, path, body, cluster)
+	if err != nil {
+		return nil, resp, err
+	}
+	return cluster, resp, nil
+}
+
+func (c *Client) doBackup(ctx context.Context, method, path string, body interface{}) (*Backup, *http.Response, error) {
+	backup := &Backup{}
+	resp, err := c.do(ctx, method, path, body, backup)
+	if err != nil {
+		return nil, resp, err
+	}
+	return backup, resp, nil
+}
+
+func (c *Client) doSqlUser(ctx context.Context, method, path string, body interface{}) (*SqlUser, *http.Response, error) {
+	user := &SqlUser{}
+	resp, err := c.do(ctx, method, path, body, user)
+	if err != nil {
+		return nil, resp, err
+	}
+	return user, resp, nil
+}
+
+// UpgradeClusterRequest upgrades a cluster to another version.
+type UpgradeClusterRequest struct {
+	Version string `json:"version"`
+}
@@ -0,0 +1,252 @@
+package testutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// MockProvider serves the provider REST API from the fake cluster stores of the tenants.
+// The tenant of a request is its bearer API key. The state of the stores is saved to a file
+// after every request that changes it, and loaded from the file when the provider is created.
+type MockProvider struct {
+	stateFile string
+	// saveMutex orders the saves of the state file
+	saveMutex sync.Mutex
+}
+
+// mockError is the body of an error response.
+type mockError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewMockProvider returns a mock provider that keeps its state in stateFile,
+// or only in memory if stateFile is empty.
+func NewMockProvider(stateFile string) (*MockProvider, error) {
+	p := &MockProvider{stateFile: stateFile}
+	if len(stateFile) == 0 {
+		return p, nil
+	}
+	data, err := os.ReadFile(stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := map[string]TenantState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	RestoreTenants(state)
+	return p, nil
+}
+
+// Save writes the state of the stores to the state file.
+func (p *MockProvider) Save() error {
+	if len(p.stateFile) == 0 {
+		return nil
+	}
+	p.saveMutex.Lock()
+	defer p.saveMutex.Unlock()
+	data, err := json.MarshalIndent(SnapshotTenants(), "", "  ")
+	if err != nil {
+		return err
+	}
+	// replace the file at once, so that a stopped server does not leave a partial state
+	tmpFile := p.stateFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, p.stateFile)
+}
+
+func (p *MockProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path, ok := cutPrefix(r.URL.Path, "/api/v1/")
+	if !ok {
+		writeMockError(w, http.StatusNotFound, errors.New("code = NotFound"))
+		return
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	// the versions do not depend on the account
+	if r.Method == http.MethodGet && path == "versions" {
+		versions, resp, err := NewFakeAPIClient().ListVersions(r.Context())
+		writeMockResponse(w, versions, resp, err)
+		return
+	}
+
+	apiKey, ok := cutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || len(apiKey) == 0 {
+		writeMockError(w, http.StatusUnauthorized, errors.New("code = Unauthenticated"))
+		return
+	}
+	client := NewFakeAPIClientForTenant(apiKey)
+
+	result, resp, err := p.route(r, client, segments)
+	if errors.Is(err, errMockRoute) {
+		writeMockError(w, http.StatusNotFound, errors.New("code = NotFound"))
+		return
+	}
+	if errors.Is(err, errMockRequest) {
+		writeMockError(w, http.StatusBadRequest, err)
+		return
+	}
+	if r.Method != http.MethodGet {
+		if saveErr := p.Save(); saveErr != nil {
+			writeMockError(w, http.StatusInternalServerError, saveErr)
+			return
+		}
+	}
+	writeMockResponse(w, result, resp, err)
+}
+
+var (
+	errMockRoute   = errors.New("no route")
+	errMockRequest = errors.New("invalid request body")
+)
+
+// route calls the method of the fake API client for the request.
+func (p *MockProvider) route(r *http.Request, client *FakeAPIClient, segments []string) (interface{}, *http.Response, error) {
+	ctx := r.Context()
+	if segments[0] != "clusters" {
+		return nil, nil, errMockRoute
+	}
+	switch len(segments) {
+	case 1:
+		switch r.Method {
+		case http.MethodGet:
+			return client.ListClusters(ctx)
+		case http.MethodPost:
+			request := &CreateClusterRequest{}
+			if err := decodeMockRequest(r, request); err != nil {
+				return nil, nil, err
+			}
+			return client.CreateCluster(ctx, request)
+		}
+	case 2:
+		clusterID := segments[1]
+		switch r.Method {
+		case http.MethodGet:
+			return client.GetCluster(ctx, clusterID)
+		case http.MethodDelete:
+			return client.DeleteCluster(ctx, clusterID)
+		}
+	case 3:
+		clusterID := segments[1]
+		switch r.Method + " " + segments[2] {
+		case "POST suspend":
+			return client.SuspendCluster(ctx, clusterID)
+		case "POST resume":
+			return client.ResumeCluster(ctx, clusterID)
+		case "POST upgrade":
+			request := &UpgradeClusterRequest{}
+			if err := decodeMockRequest(r, request); err != nil {
+				return nil, nil, err
+			}
+			return client.UpgradeCluster(ctx, clusterID, request.Version)
+		case "POST rollback":
+			return client.RollbackClusterUpgrade(ctx, clusterID)
+		case "GET backups":
+			return client.ListBackups(ctx, clusterID)
+		case "POST backups":
+			return client.CreateBackup(ctx, clusterID)
+		case "GET sql-users":
+			return client.ListSqlUsers(ctx, clusterID)
+		case "POST sql-users":
+			user := SqlUser{}
+			if err := decodeMockRequest(r, &user); err != nil {
+				return nil, nil, err
+			}
+			return client.CreateSqlUser(ctx, clusterID, user)
+		}
+	case 4:
+		clusterID := segments[1]
+		switch r.Method + " " + segments[2] {
+		case "GET backups":
+			return client.GetBackup(ctx, clusterID, segments[3])
+		case "DELETE backups":
+			return client.DeleteBackup(ctx, clusterID, segments[3])
+		case "DELETE sql-users":
+			return client.DeleteSqlUser(ctx, clusterID, segments[3])
+		}
+	case 5:
+		if r.Method == http.MethodPost && segments[2] == "sql-users" && segments[4] == "reset-password" {
+			request := &ResetSqlUserPasswordRequest{}
+			if err := decodeMockRequest(r, request); err != nil {
+				return nil, nil, err
+			}
+			return client.ResetSqlUserPassword(ctx, segments[1], segments[3], request.Password)
+		}
+		if r.Method == http.MethodPost && segments[2] == "backups" && segments[4] == "restore" {
+			request := &RestoreBackupRequest{}
+			if err := decodeMockRequest(r, request); err != nil {
+				return nil, nil, err
+			}
+			request.ClusterId = segments[1]
+			request.BackupId = segments[3]
+			return client.RestoreBackup(ctx, request)
+		}
+	}
+	return nil, nil, errMockRoute
+}
+
+func decodeMockRequest(r *http.Request, request interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		return fmt.Errorf("%w: %v", errMockRequest, err)
+	}
+	return nil
+}
+
+// writeMockResponse writes the result of a fake API client call, or its error with the status code of its response.
+func writeMockResponse(w http.ResponseWriter, result interface{}, resp *http.Response, err error) {
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if resp != nil && resp.StatusCode >= http.StatusBadRequest {
+			statusCode = resp.StatusCode
+		}
+		writeMockError(w, statusCode, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// writeMockError writes the error as the body of the provider API, keeping the errors that already have that body.
+func writeMockError(w http.ResponseWriter, statusCode int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	body := mockError{}
+	if json.Unmarshal([]byte(err.Error()), &body) != nil || len(body.Message) == 0 {
+		body = mockError{Code: mockErrorCode(statusCode), Message: err.Error()}
+	}
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// mockErrorCode returns the provider error code of an HTTP status code.
+func mockErrorCode(statusCode int) int {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return 3
+	case http.StatusNotFound:
+		return 5
+	case http.StatusConflict:
+		return 6
+	case http.StatusUnauthorized:
+		return 16
+	default:
+		return 2
+	}
+}
+
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
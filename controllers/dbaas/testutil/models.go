@@ -9,6 +9,11 @@ type SqlUser struct {
 	Password string `json:"password,omitempty"`
 }
 
+// ResetSqlUserPasswordRequest sets a new password of a SQL user.
+type ResetSqlUserPasswordRequest struct {
+	Password string `json:"password"`
+}
+
 type credential struct {
 	CredentialField1 string
 	CredentialField2 string
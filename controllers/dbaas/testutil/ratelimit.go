@@ -0,0 +1,152 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+// NewRateLimitedService returns a Service that waits for the limiter before every call of service.
+// A limiter shared by all the cloud services limits the rate of all the calls to the provider API.
+// A call whose context ends before the limiter allows it fails with a 429 response.
+func NewRateLimitedService(service Service, limiter *rate.Limiter) Service {
+	return &rateLimitedService{Service: service, limiter: limiter}
+}
+
+type rateLimitedService struct {
+	Service
+	limiter *rate.Limiter
+}
+
+func (s *rateLimitedService) wait(ctx context.Context) (*http.Response, error) {
+	if err := s.limiter.Wait(ctx); err != nil {
+		return buildRateLimitedResponse(), fmt.Errorf("provider API rate limit: %w", err)
+	}
+	return nil, nil
+}
+
+func buildRateLimitedResponse() *http.Response {
+	return &http.Response{
+		Status:     "429 Too Many Requests",
+		StatusCode: http.StatusTooManyRequests,
+		Proto:      "HTTP/1.1",
+		Body:       io.NopCloser(strings.NewReader("{\"code\": 8, \"message\": \"code = ResourceExhausted\"}")),
+		Header:     make(http.Header),
+	}
+}
+
+func (s *rateLimitedService) ListClusters(ctx context.Context) (*ListClustersResponse, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.ListClusters(ctx)
+}
+
+func (s *rateLimitedService) CreateCluster(ctx context.Context, createClusterRequest *CreateClusterRequest) (*Cluster, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.CreateCluster(ctx, createClusterRequest)
+}
+
+func (s *rateLimitedService) GetCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.GetCluster(ctx, clusterID)
+}
+
+func (s *rateLimitedService) DeleteCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.DeleteCluster(ctx, clusterID)
+}
+
+func (s *rateLimitedService) SuspendCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.SuspendCluster(ctx, clusterID)
+}
+
+func (s *rateLimitedService) ResumeCluster(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.ResumeCluster(ctx, clusterID)
+}
+
+func (s *rateLimitedService) ListVersions(ctx context.Context) (*ListVersionsResponse, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.ListVersions(ctx)
+}
+
+func (s *rateLimitedService) UpgradeCluster(ctx context.Context, clusterID, version string) (*Cluster, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.UpgradeCluster(ctx, clusterID, version)
+}
+
+func (s *rateLimitedService) RollbackClusterUpgrade(ctx context.Context, clusterID string) (*Cluster, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.RollbackClusterUpgrade(ctx, clusterID)
+}
+
+func (s *rateLimitedService) CreateBackup(ctx context.Context, clusterID string) (*Backup, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.CreateBackup(ctx, clusterID)
+}
+
+func (s *rateLimitedService) ListBackups(ctx context.Context, clusterID string) (*ListBackupsResponse, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.ListBackups(ctx, clusterID)
+}
+
+func (s *rateLimitedService) GetBackup(ctx context.Context, clusterID, backupID string) (*Backup, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.GetBackup(ctx, clusterID, backupID)
+}
+
+func (s *rateLimitedService) DeleteBackup(ctx context.Context, clusterID, backupID string) (*Backup, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.DeleteBackup(ctx, clusterID, backupID)
+}
+
+func (s *rateLimitedService) CreateSqlUser(ctx context.Context, clusterID string, user SqlUser) (*SqlUser, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.CreateSqlUser(ctx, clusterID, user)
+}
+
+func (s *rateLimitedService) ResetSqlUserPassword(ctx context.Context, clusterID, name, password string) (*SqlUser, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.ResetSqlUserPassword(ctx, clusterID, name, password)
+}
+
+func (s *rateLimitedService) RestoreBackup(ctx context.Context, restoreBackupRequest *RestoreBackupRequest) (*Cluster, *http.Response, error) {
+	if resp, err := s.wait(ctx); err != nil {
+		return nil, resp, err
+	}
+	return s.Service.RestoreBackup(ctx, restoreBackupRequest)
+}
@@ -0,0 +1,30 @@
+package testutil
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimitedService(t *testing.T) {
+	g := NewWithT(t)
+	limiter := rate.NewLimiter(rate.Every(time.Minute), 2)
+	first := NewRateLimitedService(NewFakeAPIClient(), limiter)
+	second := NewRateLimitedService(NewFakeAPIClient(), limiter)
+
+	_, _, err := first.ListVersions(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	_, _, err = second.GetCluster(context.Background(), cluster1.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// the services share the limiter, the burst is used up
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, resp, err := first.ListClusters(ctx)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+}
@@ -0,0 +1,255 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the credentials in the recorded interactions and in the logs.
+const Redacted = "REDACTED"
+
+// redactedHeaders are the headers whose values are not recorded.
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// unrecordedHeaders are the headers that change on every response, left out of the fixtures so that they stay stable.
+var unrecordedHeaders = []string{"Date"}
+
+// redactedFields are the JSON body fields whose values are not recorded.
+var redactedFields = map[string]bool{"password": true, "apiKey": true, "api_key": true, "token": true, "secret": true}
+
+// Interaction is a recorded request of the provider API and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request of an interaction. The path includes the query of the request.
+type RecordedRequest struct {
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the response of an interaction.
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// SetTransport makes the client send its requests with the transport, like a recording or a replaying transport.
+func (c *Client) SetTransport(transport http.RoundTripper) {
+	c.cfg.HTTPClient = &http.Client{Transport: transport}
+}
+
+// RecordingTransport sends the requests with another transport and records the interactions,
+// with the credentials redacted, to be saved as a fixture file.
+type RecordingTransport struct {
+	next         http.RoundTripper
+	mutex        sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecordingTransport returns a transport recording the requests sent with next,
+// or with the default transport if next is nil.
+func NewRecordingTransport(next http.RoundTripper) *RecordingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &RecordingTransport{next: next}
+}
+
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.interactions = append(t.interactions, Interaction{
+		Request: RecordedRequest{
+			Method:  req.Method,
+			Path:    req.URL.RequestURI(),
+			Headers: redactHeaders(req.Header),
+			Body:    redactBody(reqBody),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    redactHeaders(resp.Header, unrecordedHeaders...),
+			Body:       redactBody(respBody),
+		},
+	})
+	return resp, nil
+}
+
+// Interactions returns the interactions recorded so far.
+func (t *RecordingTransport) Interactions() []Interaction {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]Interaction{}, t.interactions...)
+}
+
+// Save writes the recorded interactions to the fixture file.
+func (t *RecordingTransport) Save(path string) error {
+	data, err := json.MarshalIndent(t.Interactions(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// ReplayTransport answers the requests with the responses of a fixture file, in the recorded order.
+// A request that differs from the next recorded request of its method and path, or that was not
+// recorded, fails, so that changes of the request shapes are caught.
+type ReplayTransport struct {
+	mutex        sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayTransport returns a transport replaying the interactions of the fixture file.
+func NewReplayTransport(path string) (*ReplayTransport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var interactions []Interaction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("invalid fixture file %v: %w", path, err)
+	}
+	return &ReplayTransport{interactions: interactions, used: make([]bool, len(interactions))}, nil
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	path := req.URL.RequestURI()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for i, interaction := range t.interactions {
+		if t.used[i] || interaction.Request.Method != req.Method || interaction.Request.Path != path {
+			continue
+		}
+		if !sameBody(interaction.Request.Body, redactBody(reqBody)) {
+			return nil, fmt.Errorf("the body of %v %v does not match the fixture: got %s, recorded %s",
+				req.Method, path, redactBody(reqBody), interaction.Request.Body)
+		}
+		t.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.Response.Headers.Clone(),
+			Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded interaction for %v %v", req.Method, path)
+}
+
+// Unused returns the recorded interactions that were not replayed.
+func (t *ReplayTransport) Unused() []Interaction {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var unused []Interaction
+	for i, interaction := range t.interactions {
+		if !t.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// readBody reads the body and replaces it with a reader of the same content.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	if err != nil {
+		return nil, err
+	}
+	_ = (*body).Close()
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+func redactHeaders(headers http.Header, unrecorded ...string) http.Header {
+	if len(headers) == 0 {
+		return nil
+	}
+	redactedCopy := headers.Clone()
+	for _, header := range unrecorded {
+		redactedCopy.Del(header)
+	}
+	for _, header := range redactedHeaders {
+		if _, ok := redactedCopy[http.CanonicalHeaderKey(header)]; ok {
+			redactedCopy.Set(header, Redacted)
+		}
+	}
+	return redactedCopy
+}
+
+// redactBody replaces the values of the credential fields of a JSON body. Other bodies are kept as they are.
+func redactBody(body []byte) string {
+	var value interface{}
+	if len(bytes.TrimSpace(body)) == 0 || json.Unmarshal(body, &value) != nil {
+		return string(body)
+	}
+	data, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return string(body)
+	}
+	return string(data)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if redactedFields[key] {
+				v[key] = Redacted
+			} else {
+				v[key] = redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	}
+	return value
+}
+
+// sameBody compares two bodies, as JSON values if both are JSON.
+func sameBody(recorded, body string) bool {
+	var recordedValue, value interface{}
+	if json.Unmarshal([]byte(recorded), &recordedValue) != nil || json.Unmarshal([]byte(body), &value) != nil {
+		return recorded == body
+	}
+	recordedData, _ := json.Marshal(recordedValue)
+	data, _ := json.Marshal(value)
+	return bytes.Equal(recordedData, data)
+}
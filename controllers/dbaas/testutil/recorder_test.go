@@ -0,0 +1,128 @@
+package testutil
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+var updateFixtures = flag.Bool("update", false, "record the provider API fixtures from the mock provider")
+
+const providerAPIFixture = "testdata/provider-api.json"
+
+// exerciseProviderAPI makes the calls recorded in the provider API fixture and checks their results.
+func exerciseProviderAPI(g *WithT, client *Client) {
+	ctx := context.Background()
+
+	versions, _, err := client.ListVersions(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(versions.Versions).NotTo(BeEmpty())
+
+	created, _, err := client.CreateCluster(ctx, &CreateClusterRequest{Name: "fixture-cluster", Provider: APICLOUDPROVIDER_GCP, Version: versions.Versions[0].Version})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(created.Id).To(Equal("a-cluster-instance-id-fixture-cluster"))
+	g.Expect(created.CloudProvider).To(Equal(APICLOUDPROVIDER_GCP))
+
+	_, resp, err := client.CreateCluster(ctx, &CreateClusterRequest{Name: "fixture-cluster", Provider: APICLOUDPROVIDER_GCP})
+	g.Expect(err).To(MatchError(ContainSubstring("AlreadyExists")))
+	g.Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+
+	cluster, _, err := client.GetCluster(ctx, created.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cluster.Name).To(Equal("fixture-cluster"))
+	g.Expect(cluster.Regions).NotTo(BeEmpty())
+	g.Expect(cluster.CreatedAt).NotTo(BeNil())
+
+	clusters, _, err := client.ListClusters(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(clusters.Clusters).To(ContainElement(HaveField("Id", created.Id)))
+
+	backup, _, err := client.CreateBackup(ctx, created.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(backup.ClusterId).To(Equal(created.Id))
+
+	suspended, _, err := client.SuspendCluster(ctx, created.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(suspended.State).To(Equal(CLUSTERSTATETYPE_SUSPENDED))
+
+	_, _, err = client.DeleteCluster(ctx, created.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, resp, err = client.GetCluster(ctx, created.Id)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+}
+
+// TestProviderAPIFixture replays the provider API fixture. Run it with -update to record the fixture
+// again from the mock provider.
+func TestProviderAPIFixture(t *testing.T) {
+	g := NewWithT(t)
+
+	if *updateFixtures {
+		RestoreTenants(map[string]TenantState{"fixtures-api-key": {}})
+		server := httptest.NewServer(&MockProvider{})
+		defer server.Close()
+		recorder := NewRecordingTransport(nil)
+		client := NewClient(server.URL, "fixtures-api-key")
+		client.SetTransport(recorder)
+		exerciseProviderAPI(g, client)
+		g.Expect(recorder.Save(providerAPIFixture)).To(Succeed())
+	}
+
+	replay, err := NewReplayTransport(providerAPIFixture)
+	g.Expect(err).NotTo(HaveOccurred())
+	client := NewClient("http://provider.invalid", "fixtures-api-key")
+	client.SetTransport(replay)
+	exerciseProviderAPI(g, client)
+	g.Expect(replay.Unused()).To(BeEmpty())
+}
+
+func TestRecordingRedactsCredentials(t *testing.T) {
+	g := NewWithT(t)
+	server := httptest.NewServer(&MockProvider{})
+	defer server.Close()
+	recorder := NewRecordingTransport(nil)
+
+	client := NewClient(server.URL, "redacted-api-key")
+	client.SetTransport(recorder)
+	created, _, err := client.CreateCluster(context.Background(), &CreateClusterRequest{Name: "redacted", Provider: APICLOUDPROVIDER_AWS})
+	g.Expect(err).NotTo(HaveOccurred())
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/"+clusterPath(created.Id, "sql-users"),
+		strings.NewReader(`{"name": "user1", "password": "redacted-password"}`))
+	g.Expect(err).NotTo(HaveOccurred())
+	request.Header.Set("Authorization", "Bearer redacted-api-key")
+	resp, err := (&http.Client{Transport: recorder}).Do(request)
+	g.Expect(err).NotTo(HaveOccurred())
+	resp.Body.Close()
+
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	g.Expect(recorder.Save(path)).To(Succeed())
+	data, err := os.ReadFile(path)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(string(data)).NotTo(ContainSubstring("redacted-api-key"))
+	g.Expect(string(data)).NotTo(ContainSubstring("redacted-password"))
+
+	interactions := recorder.Interactions()
+	g.Expect(interactions).To(HaveLen(2))
+	g.Expect(interactions[1].Request.Headers.Get("Authorization")).To(Equal(Redacted))
+	g.Expect(interactions[1].Request.Body).To(MatchJSON(`{"name": "user1", "password": "REDACTED"}`))
+}
+
+func TestReplayDetectsChangedRequests(t *testing.T) {
+	g := NewWithT(t)
+	replay, err := NewReplayTransport(providerAPIFixture)
+	g.Expect(err).NotTo(HaveOccurred())
+	client := NewClient("http://provider.invalid", "fixtures-api-key")
+	client.SetTransport(replay)
+
+	_, _, err = client.CreateCluster(context.Background(), &CreateClusterRequest{Name: "another-cluster", Provider: APICLOUDPROVIDER_GCP})
+	g.Expect(err).To(MatchError(ContainSubstring("does not match the fixture")))
+	_, _, err = client.GetBackup(context.Background(), "a-cluster-instance-id-fixture-cluster", "missing")
+	g.Expect(err).To(MatchError(ContainSubstring("no recorded interaction")))
+}
@@ -0,0 +1,288 @@
+// Package servicetest is a conformance test suite for implementations of the provider testutil.Service.
+//
+// Run it from a test of the implementation, with a factory returning a service of an account
+// that is not used by other tests:
+//
+//	func TestConformance(t *testing.T) {
+//		servicetest.Run(t, func(t *testing.T) testutil.Service {
+//			return NewService(accountFor(t))
+//		})
+//	}
+package servicetest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+)
+
+// Factory returns the service under test. Every call returns a service of an account
+// whose clusters are not changed by other calls.
+type Factory func(t *testing.T) testutil.Service
+
+// Timeout is how long the suite waits for a change to become visible, for providers that are eventually consistent.
+var Timeout = 5 * time.Second
+
+// Run runs the conformance tests against the services of the factory.
+func Run(t *testing.T, newService Factory) {
+	t.Run("ListVersions", func(t *testing.T) { testListVersions(t, newService(t)) })
+	t.Run("ClusterLifecycle", func(t *testing.T) { testClusterLifecycle(t, newService(t)) })
+	t.Run("AlreadyExists", func(t *testing.T) { testAlreadyExists(t, newService(t)) })
+	t.Run("NotFound", func(t *testing.T) { testNotFound(t, newService(t)) })
+	t.Run("Idempotency", func(t *testing.T) { testIdempotency(t, newService(t)) })
+	t.Run("BackupLifecycle", func(t *testing.T) { testBackupLifecycle(t, newService(t)) })
+	t.Run("ConcurrentCreate", func(t *testing.T) { testConcurrentCreate(t, newService(t)) })
+	t.Run("SqlUsers", func(t *testing.T) { testSqlUsers(t, newService(t)) })
+}
+
+// statusCode returns the status code of a response, or 0 if there is no response.
+func statusCode(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+func createCluster(g *WithT, service testutil.Service, name string) *testutil.Cluster {
+	cluster, resp, err := service.CreateCluster(context.Background(), &testutil.CreateClusterRequest{Name: name, Provider: testutil.APICLOUDPROVIDER_AWS})
+	g.Expect(err).NotTo(HaveOccurred(), "create cluster %v", name)
+	g.Expect(statusCode(resp)).To(Equal(http.StatusOK))
+	g.Expect(cluster.Id).NotTo(BeEmpty())
+	g.Expect(cluster.Name).To(Equal(name))
+	// wait for the cluster to be readable
+	g.Eventually(func() error {
+		_, _, err := service.GetCluster(context.Background(), cluster.Id)
+		return err
+	}, Timeout).Should(Succeed())
+	return cluster
+}
+
+func clusterIDs(g *WithT, service testutil.Service) []string {
+	clusters, _, err := service.ListClusters(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	var ids []string
+	for _, cluster := range clusters.Clusters {
+		ids = append(ids, cluster.Id)
+	}
+	return ids
+}
+
+func testListVersions(t *testing.T, service testutil.Service) {
+	g := NewWithT(t)
+	versions, resp, err := service.ListVersions(context.Background())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(statusCode(resp)).To(Equal(http.StatusOK))
+	g.Expect(versions.Versions).NotTo(BeEmpty())
+	for _, version := range versions.Versions {
+		g.Expect(version.Version).NotTo(BeEmpty())
+	}
+}
+
+func testClusterLifecycle(t *testing.T, service testutil.Service) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	created := createCluster(g, service, "conformance-lifecycle")
+	cluster, _, err := service.GetCluster(ctx, created.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(cluster.Id).To(Equal(created.Id))
+	g.Expect(cluster.Name).To(Equal(created.Name))
+	g.Eventually(func() []string { return clusterIDs(g, service) }, Timeout).Should(ContainElement(created.Id))
+
+	_, resp, err := service.DeleteCluster(ctx, created.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(statusCode(resp)).To(Equal(http.StatusOK))
+	g.Eventually(func() int {
+		_, resp, _ := service.GetCluster(ctx, created.Id)
+		return statusCode(resp)
+	}, Timeout).Should(Equal(http.StatusNotFound))
+	g.Eventually(func() []string { return clusterIDs(g, service) }, Timeout).ShouldNot(ContainElement(created.Id))
+}
+
+func testAlreadyExists(t *testing.T, service testutil.Service) {
+	g := NewWithT(t)
+	createCluster(g, service, "conformance-already-exists")
+
+	_, resp, err := service.CreateCluster(context.Background(), &testutil.CreateClusterRequest{Name: "conformance-already-exists", Provider: testutil.APICLOUDPROVIDER_AWS})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(statusCode(resp)).To(Equal(http.StatusConflict))
+}
+
+func testNotFound(t *testing.T, service testutil.Service) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	const missing = "conformance-missing-cluster"
+
+	calls := map[string]func() (*http.Response, error){
+		"GetCluster": func() (*http.Response, error) {
+			_, resp, err := service.GetCluster(ctx, missing)
+			return resp, err
+		},
+		"DeleteCluster": func() (*http.Response, error) {
+			_, resp, err := service.DeleteCluster(ctx, missing)
+			return resp, err
+		},
+		"SuspendCluster": func() (*http.Response, error) {
+			_, resp, err := service.SuspendCluster(ctx, missing)
+			return resp, err
+		},
+		"ResumeCluster": func() (*http.Response, error) {
+			_, resp, err := service.ResumeCluster(ctx, missing)
+			return resp, err
+		},
+		"CreateBackup": func() (*http.Response, error) {
+			_, resp, err := service.CreateBackup(ctx, missing)
+			return resp, err
+		},
+		"GetBackup": func() (*http.Response, error) {
+			_, resp, err := service.GetBackup(ctx, missing, "conformance-missing-backup")
+			return resp, err
+		},
+		"DeleteBackup": func() (*http.Response, error) {
+			_, resp, err := service.DeleteBackup(ctx, missing, "conformance-missing-backup")
+			return resp, err
+		},
+	}
+	for method, call := range calls {
+		resp, err := call()
+		g.Expect(err).To(HaveOccurred(), method)
+		g.Expect(statusCode(resp)).To(Equal(http.StatusNotFound), method)
+	}
+}
+
+func testIdempotency(t *testing.T, service testutil.Service) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	created := createCluster(g, service, "conformance-idempotency")
+
+	for i := 0; i < 2; i++ {
+		cluster, _, err := service.SuspendCluster(ctx, created.Id)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(cluster.State).To(Equal(testutil.CLUSTERSTATETYPE_SUSPENDED))
+	}
+	for i := 0; i < 2; i++ {
+		cluster, _, err := service.ResumeCluster(ctx, created.Id)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(cluster.State).To(Equal(testutil.CLUSTERSTATETYPE_CREATED))
+	}
+
+	_, _, err := service.DeleteCluster(ctx, created.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	// deleting again is reported as NotFound, which callers treat as deleted
+	_, resp, err := service.DeleteCluster(ctx, created.Id)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(statusCode(resp)).To(Equal(http.StatusNotFound))
+}
+
+func testBackupLifecycle(t *testing.T, service testutil.Service) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	created := createCluster(g, service, "conformance-backup")
+
+	backup, _, err := service.CreateBackup(ctx, created.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(backup.Id).NotTo(BeEmpty())
+	g.Expect(backup.ClusterId).To(Equal(created.Id))
+
+	backups, _, err := service.ListBackups(ctx, created.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(backups.Backups).To(ContainElement(HaveField("Id", backup.Id)))
+	g.Eventually(func() testutil.BackupStateType {
+		b, _, err := service.GetBackup(ctx, created.Id, backup.Id)
+		g.Expect(err).NotTo(HaveOccurred())
+		return b.State
+	}, Timeout).Should(Equal(testutil.BACKUPSTATETYPE_COMPLETED))
+
+	_, _, err = service.DeleteBackup(ctx, created.Id, backup.Id)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, resp, err := service.GetBackup(ctx, created.Id, backup.Id)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(statusCode(resp)).To(Equal(http.StatusNotFound))
+}
+
+func testSqlUsers(t *testing.T, service testutil.Service) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	created := createCluster(g, service, "conformance-sql-users")
+
+	_, resp, err := service.ResetSqlUserPassword(ctx, created.Id, "conformance-user", "first")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(statusCode(resp)).To(Equal(http.StatusNotFound))
+
+	user, _, err := service.CreateSqlUser(ctx, created.Id, testutil.SqlUser{Name: "conformance-user", Password: "first"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(user.Name).To(Equal("conformance-user"))
+	g.Expect(user.Password).To(BeEmpty())
+	_, resp, err = service.CreateSqlUser(ctx, created.Id, testutil.SqlUser{Name: "conformance-user", Password: "first"})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(statusCode(resp)).To(Equal(http.StatusConflict))
+
+	user, _, err = service.ResetSqlUserPassword(ctx, created.Id, "conformance-user", "second")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(user.Name).To(Equal("conformance-user"))
+	g.Expect(user.Password).To(BeEmpty())
+	_, resp, err = service.ResetSqlUserPassword(ctx, created.Id, "conformance-user", "")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(statusCode(resp)).To(Equal(http.StatusBadRequest))
+
+	_, resp, err = service.ResetSqlUserPassword(ctx, "missing", "conformance-user", "second")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(statusCode(resp)).To(Equal(http.StatusNotFound))
+}
+
+func testConcurrentCreate(t *testing.T, service testutil.Service) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	const calls = 10
+
+	var wg sync.WaitGroup
+	statusCodes := make([]int, calls)
+	ids := make([]string, calls)
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// every second call creates the same cluster
+			name := fmt.Sprintf("conformance-concurrent-%d", i)
+			if i%2 == 0 {
+				name = "conformance-concurrent"
+			}
+			cluster, resp, err := service.CreateCluster(ctx, &testutil.CreateClusterRequest{Name: name, Provider: testutil.APICLOUDPROVIDER_AWS})
+			statusCodes[i] = statusCode(resp)
+			if err == nil {
+				ids[i] = cluster.Id
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var created, conflicts int
+	for i := 0; i < calls; i += 2 {
+		switch statusCodes[i] {
+		case http.StatusOK:
+			created++
+		case http.StatusConflict:
+			conflicts++
+		}
+	}
+	g.Expect(created).To(Equal(1), "exactly one creation of the same cluster succeeds")
+	g.Expect(conflicts).To(Equal(calls/2 - 1))
+
+	var expected []string
+	for i, id := range ids {
+		if i%2 == 1 {
+			g.Expect(statusCodes[i]).To(Equal(http.StatusOK))
+		}
+		if len(id) != 0 {
+			expected = append(expected, id)
+		}
+	}
+	g.Eventually(func() []string { return clusterIDs(g, service) }, Timeout).Should(ContainElements(expected))
+}
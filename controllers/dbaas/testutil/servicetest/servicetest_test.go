@@ -0,0 +1,41 @@
+package servicetest_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil/servicetest"
+)
+
+func TestFakeAPIClient(t *testing.T) {
+	servicetest.Run(t, func(t *testing.T) testutil.Service {
+		return testutil.NewFakeAPIClientForTenant(t.Name())
+	})
+}
+
+func TestFakeAPIClientReadAfterWriteDelay(t *testing.T) {
+	servicetest.Run(t, func(t *testing.T) testutil.Service {
+		client := testutil.NewFakeAPIClientForTenant(t.Name())
+		client.SetReadAfterWriteDelay(50 * time.Millisecond)
+		return client
+	})
+}
+
+func TestHTTPClient(t *testing.T) {
+	server := httptest.NewServer(&testutil.MockProvider{})
+	defer server.Close()
+	servicetest.Run(t, func(t *testing.T) testutil.Service {
+		return testutil.NewClient(server.URL, t.Name())
+	})
+}
+
+func TestRateLimitedFakeAPIClient(t *testing.T) {
+	limiter := rate.NewLimiter(1000, 100)
+	servicetest.Run(t, func(t *testing.T) testutil.Service {
+		return testutil.NewRateLimitedService(testutil.NewFakeAPIClientForTenant(t.Name()), limiter)
+	})
+}
@@ -0,0 +1,164 @@
+package testutil
+
+import (
+	"context"
+
+	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
+	"github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TracerName is the name of the tracer of the provider calls.
+const TracerName = "github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+
+// The attributes of the provider spans.
+const (
+	ClusterIDKey = attribute.Key("dbaas.cluster.id")
+	BackupIDKey  = attribute.Key("dbaas.backup.id")
+	NamespaceKey = attribute.Key("k8s.namespace.name")
+	NameKey      = attribute.Key("dbaas.resource.name")
+)
+
+// NewTracedProviderService returns a DBaaSProviderService recording a span for every provider method of service,
+// a child of the span of the context, with the cluster and backup IDs of the call as attributes.
+// The spans are recorded by the global tracer provider.
+func NewTracedProviderService(service DBaaSProviderService) DBaaSProviderService {
+	return &tracedProviderService{DBaaSProviderService: service}
+}
+
+type tracedProviderService struct {
+	DBaaSProviderService
+}
+
+func startProviderSpan(ctx context.Context, method string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, "DBaaSProviderService."+method,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+// endProviderSpan ends the span of a call, with the error of the call.
+func endProviderSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (s *tracedProviderService) CreateCloudService(ctx context.Context, selector client.ObjectKey) (Service, error) {
+	ctx, span := startProviderSpan(ctx, "CreateCloudService", NamespaceKey.String(selector.Namespace))
+	service, err := s.DBaaSProviderService.CreateCloudService(ctx, selector)
+	endProviderSpan(span, err)
+	return service, err
+}
+
+func (s *tracedProviderService) DiscoverClusters(ctx context.Context, cloudService Service) ([]dbaasv1beta1.DatabaseService, error) {
+	ctx, span := startProviderSpan(ctx, "DiscoverClusters")
+	services, err := s.DBaaSProviderService.DiscoverClusters(ctx, cloudService)
+	span.SetAttributes(attribute.Int("dbaas.clusters", len(services)))
+	endProviderSpan(span, err)
+	return services, err
+}
+
+func (s *tracedProviderService) CreateCluster(ctx context.Context, cloudService Service, instance *v1beta1.ProviderInstance) (*Cluster, error) {
+	ctx, span := startProviderSpan(ctx, "CreateCluster", NamespaceKey.String(instance.Namespace), NameKey.String(instance.Name))
+	cluster, err := s.DBaaSProviderService.CreateCluster(ctx, cloudService, instance)
+	if cluster != nil {
+		span.SetAttributes(ClusterIDKey.String(cluster.Id))
+	}
+	endProviderSpan(span, err)
+	return cluster, err
+}
+
+func (s *tracedProviderService) GetCluster(ctx context.Context, cloudService Service, clusterID string) (*Cluster, error) {
+	ctx, span := startProviderSpan(ctx, "GetCluster", ClusterIDKey.String(clusterID))
+	cluster, err := s.DBaaSProviderService.GetCluster(ctx, cloudService, clusterID)
+	endProviderSpan(span, err)
+	return cluster, err
+}
+
+func (s *tracedProviderService) SuspendCluster(ctx context.Context, cloudService Service, clusterID string) (*Cluster, error) {
+	ctx, span := startProviderSpan(ctx, "SuspendCluster", ClusterIDKey.String(clusterID))
+	cluster, err := s.DBaaSProviderService.SuspendCluster(ctx, cloudService, clusterID)
+	endProviderSpan(span, err)
+	return cluster, err
+}
+
+func (s *tracedProviderService) ResumeCluster(ctx context.Context, cloudService Service, clusterID string) (*Cluster, error) {
+	ctx, span := startProviderSpan(ctx, "ResumeCluster", ClusterIDKey.String(clusterID))
+	cluster, err := s.DBaaSProviderService.ResumeCluster(ctx, cloudService, clusterID)
+	endProviderSpan(span, err)
+	return cluster, err
+}
+
+func (s *tracedProviderService) ListVersions(ctx context.Context, cloudService Service) ([]ClusterVersion, error) {
+	ctx, span := startProviderSpan(ctx, "ListVersions")
+	versions, err := s.DBaaSProviderService.ListVersions(ctx, cloudService)
+	endProviderSpan(span, err)
+	return versions, err
+}
+
+func (s *tracedProviderService) UpgradeCluster(ctx context.Context, cloudService Service, clusterID, version string) (*Cluster, error) {
+	ctx, span := startProviderSpan(ctx, "UpgradeCluster", ClusterIDKey.String(clusterID), attribute.String("dbaas.version", version))
+	cluster, err := s.DBaaSProviderService.UpgradeCluster(ctx, cloudService, clusterID, version)
+	endProviderSpan(span, err)
+	return cluster, err
+}
+
+func (s *tracedProviderService) RollbackClusterUpgrade(ctx context.Context, cloudService Service, clusterID string) (*Cluster, error) {
+	ctx, span := startProviderSpan(ctx, "RollbackClusterUpgrade", ClusterIDKey.String(clusterID))
+	cluster, err := s.DBaaSProviderService.RollbackClusterUpgrade(ctx, cloudService, clusterID)
+	endProviderSpan(span, err)
+	return cluster, err
+}
+
+func (s *tracedProviderService) CreateBackup(ctx context.Context, cloudService Service, clusterID string) (*Backup, error) {
+	ctx, span := startProviderSpan(ctx, "CreateBackup", ClusterIDKey.String(clusterID))
+	backup, err := s.DBaaSProviderService.CreateBackup(ctx, cloudService, clusterID)
+	if backup != nil {
+		span.SetAttributes(BackupIDKey.String(backup.Id))
+	}
+	endProviderSpan(span, err)
+	return backup, err
+}
+
+func (s *tracedProviderService) ListBackups(ctx context.Context, cloudService Service, clusterID string) ([]Backup, error) {
+	ctx, span := startProviderSpan(ctx, "ListBackups", ClusterIDKey.String(clusterID))
+	backups, err := s.DBaaSProviderService.ListBackups(ctx, cloudService, clusterID)
+	endProviderSpan(span, err)
+	return backups, err
+}
+
+func (s *tracedProviderService) GetBackup(ctx context.Context, cloudService Service, clusterID, backupID string) (*Backup, error) {
+	ctx, span := startProviderSpan(ctx, "GetBackup", ClusterIDKey.String(clusterID), BackupIDKey.String(backupID))
+	backup, err := s.DBaaSProviderService.GetBackup(ctx, cloudService, clusterID, backupID)
+	endProviderSpan(span, err)
+	return backup, err
+}
+
+func (s *tracedProviderService) DeleteBackup(ctx context.Context, cloudService Service, clusterID, backupID string) error {
+	ctx, span := startProviderSpan(ctx, "DeleteBackup", ClusterIDKey.String(clusterID), BackupIDKey.String(backupID))
+	err := s.DBaaSProviderService.DeleteBackup(ctx, cloudService, clusterID, backupID)
+	endProviderSpan(span, err)
+	return err
+}
+
+func (s *tracedProviderService) SetSqlUserPassword(ctx context.Context, cloudService Service, clusterID, user, password string) error {
+	ctx, span := startProviderSpan(ctx, "SetSqlUserPassword", ClusterIDKey.String(clusterID), attribute.String("dbaas.sql_user", user))
+	err := s.DBaaSProviderService.SetSqlUserPassword(ctx, cloudService, clusterID, user, password)
+	endProviderSpan(span, err)
+	return err
+}
+
+func (s *tracedProviderService) RestoreBackup(ctx context.Context, cloudService Service, clusterID, backupID, clusterName string) (*Cluster, error) {
+	ctx, span := startProviderSpan(ctx, "RestoreBackup", ClusterIDKey.String(clusterID), BackupIDKey.String(backupID))
+	cluster, err := s.DBaaSProviderService.RestoreBackup(ctx, cloudService, clusterID, backupID, clusterName)
+	if cluster != nil {
+		span.SetAttributes(attribute.String("dbaas.restored_cluster.id", cluster.Id))
+	}
+	endProviderSpan(span, err)
+	return cluster, err
+}
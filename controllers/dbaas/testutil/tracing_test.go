@@ -0,0 +1,47 @@
+package testutil
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedProviderService(t *testing.T) {
+	g := NewWithT(t)
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	mockProvider, err := NewMockProvider("")
+	g.Expect(err).NotTo(HaveOccurred())
+	server := httptest.NewServer(mockProvider)
+	defer server.Close()
+	cloudService := NewClient(server.URL, "tracing-api-key")
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "reconcile")
+	service := NewTracedProviderService(&FakeProviderService{})
+	_, err = service.GetCluster(ctx, cloudService, "missing-cluster")
+	g.Expect(err).To(HaveOccurred())
+	parent.End()
+
+	spans := recorder.Ended()
+	g.Expect(spans).To(HaveLen(3))
+	httpSpan, providerSpan := spans[0], spans[1]
+
+	g.Expect(providerSpan.Name()).To(Equal("DBaaSProviderService.GetCluster"))
+	g.Expect(providerSpan.Parent().SpanID()).To(Equal(parent.SpanContext().SpanID()))
+	g.Expect(providerSpan.Attributes()).To(ContainElement(ClusterIDKey.String("missing-cluster")))
+	g.Expect(providerSpan.Status().Description).NotTo(BeEmpty())
+
+	// the request of the provider API is a child of the provider call
+	g.Expect(httpSpan.Parent().SpanID()).To(Equal(providerSpan.SpanContext().SpanID()))
+	g.Expect(httpSpan.Attributes()).To(ContainElement(attribute.Int("http.status_code", 404)))
+}
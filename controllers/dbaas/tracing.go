@@ -0,0 +1,107 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
+)
+
+// tracerName is the name of the tracer of the reconciles
+const tracerName = "github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas"
+
+// TracingOptions configure the export of the spans of the operator.
+type TracingOptions struct {
+	// Endpoint is the host and port of the OTLP HTTP collector, the spans are not exported if it is empty
+	Endpoint string
+	// Insecure sends the spans without TLS
+	Insecure bool
+	// SamplingRatio is the ratio of the traces that are sampled, all the traces if it is zero
+	SamplingRatio float64
+}
+
+// SetupTracing exports the spans of the reconciles, the provider calls and the provider API requests with OTLP,
+// with the global tracer provider. The returned function flushes the spans and stops the export.
+func SetupTracing(ctx context.Context, options TracingOptions) (func(context.Context) error, error) {
+	if len(options.Endpoint) == 0 {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporterOptions := []otlptracehttp.Option{otlptracehttp.WithEndpoint(options.Endpoint)}
+	if options.Insecure {
+		exporterOptions = append(exporterOptions, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, exporterOptions...)
+	if err != nil {
+		return nil, err
+	}
+	sampler := sdktrace.AlwaysSample()
+	if options.SamplingRatio > 0 {
+		sampler = sdktrace.TraceIDRatioBased(options.SamplingRatio)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName("provider-operator-example"))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// tracedReconciler records a span for every reconcile, with the name and namespace of the reconciled object.
+type tracedReconciler struct {
+	kind string
+	reconcile.Reconciler
+}
+
+// traceReconciles returns the reconciler recording a span for every reconcile of an object of the kind.
+func traceReconciles(kind string, r reconcile.Reconciler) reconcile.Reconciler {
+	return &tracedReconciler{kind: kind, Reconciler: r}
+}
+
+func (r *tracedReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, r.kind+".Reconcile", trace.WithAttributes(
+		attribute.String("dbaas.resource.kind", r.kind),
+		testutil.NamespaceKey.String(req.Namespace),
+		testutil.NameKey.String(req.Name),
+	))
+	defer span.End()
+
+	result, err := r.Reconciler.Reconcile(ctx, req)
+	span.SetAttributes(
+		attribute.Bool("dbaas.reconcile.requeue", result.Requeue),
+		attribute.String("dbaas.reconcile.requeue_after", result.RequeueAfter.String()),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return result, err
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dbaas
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracev1 "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// collector is a local OTLP HTTP collector keeping the received spans.
+type collector struct {
+	mutex sync.Mutex
+	spans []*tracev1.Span
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || r.URL.Path != "/v1/traces" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	request := &collectortrace.ExportTraceServiceRequest{}
+	if err := proto.Unmarshal(body, request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, resourceSpans := range request.ResourceSpans {
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			c.spans = append(c.spans, scopeSpans.Spans...)
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	data, _ := proto.Marshal(&collectortrace.ExportTraceServiceResponse{})
+	_, _ = w.Write(data)
+}
+
+func TestSetupTracingExportsReconcileSpans(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	received := &collector{}
+	server := httptest.NewServer(received)
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	shutdown, err := SetupTracing(ctx, TracingOptions{Endpoint: serverURL.Host, Insecure: true})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	reconciler := traceReconciles("ProviderInstance", reconcile.Func(func(context.Context, ctrl.Request) (ctrl.Result, error) {
+		return ctrl.Result{}, errors.New("cluster is not ready")
+	}))
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "tenant", Name: "instance"}})
+	g.Expect(err).To(HaveOccurred())
+	// the shutdown flushes the spans to the collector
+	g.Expect(shutdown(ctx)).To(Succeed())
+
+	received.mutex.Lock()
+	defer received.mutex.Unlock()
+	g.Expect(received.spans).To(HaveLen(1))
+	span := received.spans[0]
+	g.Expect(span.Name).To(Equal("ProviderInstance.Reconcile"))
+	g.Expect(span.Status.Code).To(Equal(tracev1.Status_STATUS_CODE_ERROR))
+	attributes := map[string]string{}
+	for _, attribute := range span.Attributes {
+		attributes[attribute.Key] = attribute.Value.GetStringValue()
+	}
+	g.Expect(attributes).To(HaveKeyWithValue("k8s.namespace.name", "tenant"))
+	g.Expect(attributes).To(HaveKeyWithValue("dbaas.resource.name", "instance"))
+}
+
+func TestSetupTracingDisabled(t *testing.T) {
+	g := NewWithT(t)
+	previous := otel.GetTracerProvider()
+
+	shutdown, err := SetupTracing(context.Background(), TracingOptions{})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(shutdown(context.Background())).To(Succeed())
+	g.Expect(otel.GetTracerProvider()).To(BeIdenticalTo(previous))
+}
@@ -17,12 +17,16 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	dbaasv1beta1 "github.com/RHEcosystemAppEng/dbaas-operator/api/v1beta1"
 	"github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas/testutil"
 
+	"golang.org/x/time/rate"
 	"k8s.io/client-go/kubernetes"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -35,6 +39,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	configv1alpha1 "github.com/RHEcosystemAppEng/provider-operator-example/apis/config/v1alpha1"
 	provider1beta1 "github.com/RHEcosystemAppEng/provider-operator-example/apis/dbaas/v1beta1"
 	dbaascontrollers "github.com/RHEcosystemAppEng/provider-operator-example/controllers/dbaas"
 	//+kubebuilder:scaffold:imports
@@ -50,34 +55,71 @@ func init() {
 
 	utilruntime.Must(dbaasv1beta1.AddToScheme(scheme))
 	utilruntime.Must(provider1beta1.AddToScheme(scheme))
+	utilruntime.Must(configv1alpha1.AddToScheme(scheme))
 
 	//+kubebuilder:scaffold:scheme
 }
 
 func main() {
+	var configFile string
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var providerURL string
+	var watchNamespaces string
+	var tracingOptions dbaascontrollers.TracingOptions
+	var providerAPIQPS float64
+	var providerAPIBurst int
+	var providerUnreachableTimeout time.Duration
+	var providerFaultInjection bool
+	var inventoryOptions, instanceOptions, connectionOptions dbaascontrollers.ControllerOptions
+	flag.StringVar(&configFile, "config", "",
+		"The operator config file, a ProviderOperatorConfig. The flags set on the command line override its settings.")
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&providerURL, "provider-url", "",
+		"The URL of the provider API, like the mock provider server. The in-memory fake provider is used if it is empty.")
+	flag.Float64Var(&providerAPIQPS, "provider-api-qps", 0,
+		"The number of provider API calls per second of all the controllers. The calls are not limited if it is 0.")
+	flag.IntVar(&providerAPIBurst, "provider-api-burst", 10,
+		"The number of provider API calls above the rate allowed at once.")
+	flag.DurationVar(&providerUnreachableTimeout, "provider-unreachable-timeout", dbaascontrollers.DefaultUnreachableTimeout,
+		"How long the provider API can be unreachable before the operator is not ready.")
+	flag.BoolVar(&providerFaultInjection, "provider-fault-injection", false,
+		"Inject the faults of the dbaas.redhat.com/fake-faults annotation of the inventories into the provider API calls, in dev clusters only.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"The comma-separated namespaces the operator watches, with the install namespace. All the namespaces are watched if it is empty.")
+	flag.StringVar(&tracingOptions.Endpoint, "tracing-endpoint", "",
+		"The host and port of the OTLP HTTP collector the spans are exported to. The spans are not exported if it is empty.")
+	flag.BoolVar(&tracingOptions.Insecure, "tracing-insecure", false,
+		"Export the spans to the collector without TLS.")
+	inventoryOptions.BindFlags(flag.CommandLine, "inventory")
+	instanceOptions.BindFlags(flag.CommandLine, "instance")
+	connectionOptions.BindFlags(flag.CommandLine, "connection")
 	opts := zap.Options{
 		Development: true,
 	}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	// the secrets of the messages, the errors and the values are redacted from all the logs
+	ctrl.SetLogger(dbaascontrollers.NewRedactingLogger(zap.New(zap.UseFlagOptions(&opts))))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "425ea28f.redhat.com",
+	setFlags := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+	flagSet := func(name string) bool {
+		return setFlags[name]
+	}
+
+	var err error
+	operatorConfig := configv1alpha1.ProviderOperatorConfig{}
+	options := ctrl.Options{
+		Scheme: scheme,
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -89,7 +131,82 @@ func main() {
 		// if you are doing or is intended to do any operation such as perform cleanups
 		// after the manager stops then its usage might be unsafe.
 		// LeaderElectionReleaseOnCancel: true,
-	})
+	}
+	if len(configFile) != 0 {
+		options, err = options.AndFrom(ctrl.ConfigFile().AtPath(configFile).OfKind(&operatorConfig))
+		if err != nil {
+			setupLog.Error(err, "unable to load the config file", "file", configFile)
+			os.Exit(1)
+		}
+	}
+	// without a config file, or when they are set on the command line, the flags set the manager options
+	if len(options.MetricsBindAddress) == 0 || flagSet("metrics-bind-address") {
+		options.MetricsBindAddress = metricsAddr
+	}
+	if len(options.HealthProbeBindAddress) == 0 || flagSet("health-probe-bind-address") {
+		options.HealthProbeBindAddress = probeAddr
+	}
+	if len(configFile) == 0 || flagSet("leader-elect") {
+		options.LeaderElection = enableLeaderElection
+	}
+	if options.Port == 0 {
+		options.Port = 9443
+	}
+	if len(options.LeaderElectionID) == 0 {
+		options.LeaderElectionID = "425ea28f.redhat.com"
+	}
+	namespaces := operatorConfig.WatchNamespaces
+	if flagSet("watch-namespaces") {
+		namespaces = nil
+		for _, ns := range strings.Split(watchNamespaces, ",") {
+			if ns = strings.TrimSpace(ns); len(ns) != 0 {
+				namespaces = append(namespaces, ns)
+			}
+		}
+	}
+	installNamespace := operatorConfig.Registration.InstallNamespace
+	if len(installNamespace) == 0 {
+		installNamespace = os.Getenv(dbaascontrollers.InstallNamespaceEnvVar)
+	}
+	options.NewCache = dbaascontrollers.NewCacheFunc(namespaces, installNamespace)
+
+	// the operator settings of the config file, unless set on the command line
+	if len(operatorConfig.Provider.ServerURL) != 0 && !flagSet("provider-url") {
+		providerURL = operatorConfig.Provider.ServerURL
+	}
+	if operatorConfig.Provider.APIQPS > 0 && !flagSet("provider-api-qps") {
+		providerAPIQPS = operatorConfig.Provider.APIQPS
+	}
+	if operatorConfig.Provider.APIBurst > 0 && !flagSet("provider-api-burst") {
+		providerAPIBurst = operatorConfig.Provider.APIBurst
+	}
+	if operatorConfig.Provider.UnreachableTimeout != nil && !flagSet("provider-unreachable-timeout") {
+		providerUnreachableTimeout = operatorConfig.Provider.UnreachableTimeout.Duration
+	}
+	if operatorConfig.Provider.FaultInjection && !flagSet("provider-fault-injection") {
+		providerFaultInjection = true
+	}
+	if len(operatorConfig.Tracing.Endpoint) != 0 && !flagSet("tracing-endpoint") {
+		tracingOptions.Endpoint = operatorConfig.Tracing.Endpoint
+	}
+	if operatorConfig.Tracing.Insecure && !flagSet("tracing-insecure") {
+		tracingOptions.Insecure = true
+	}
+	tracingOptions.SamplingRatio = operatorConfig.Tracing.SamplingRatio
+	inventoryOptions.ApplyConfig(operatorConfig.Controllers["inventory"], "inventory", flagSet)
+	instanceOptions.ApplyConfig(operatorConfig.Controllers["instance"], "instance", flagSet)
+	connectionOptions.ApplyConfig(operatorConfig.Controllers["connection"], "connection", flagSet)
+	if operatorConfig.ProviderSyncPeriod != nil {
+		dbaascontrollers.SetSyncPeriod(operatorConfig.ProviderSyncPeriod.Duration)
+	}
+
+	shutdownTracing, err := dbaascontrollers.SetupTracing(context.Background(), tracingOptions)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
@@ -102,54 +219,148 @@ func main() {
 		setupLog.Error(err, "unable to create clientset")
 		os.Exit(1)
 	}
+	// the calls of all the controllers share the provider API rate limit
+	var providerAPILimiter *rate.Limiter
+	if providerAPIQPS > 0 {
+		providerAPILimiter = rate.NewLimiter(rate.Limit(providerAPIQPS), providerAPIBurst)
+	}
+	var cloudService testutil.Service = testutil.NewFakeAPIClient()
+	if len(providerURL) != 0 {
+		cloudService = testutil.NewClient(providerURL, "")
+	}
+	if providerAPILimiter != nil {
+		cloudService = testutil.NewRateLimitedService(cloudService, providerAPILimiter)
+	}
 	if err = (&dbaascontrollers.DBaaSProviderReconciler{
-		Client:    mgr.GetClient(),
-		Scheme:    mgr.GetScheme(),
-		Clientset: clientSet,
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		Clientset:    clientSet,
+		CloudService: cloudService,
+		// INSTALL_NAMESPACE and OPERATOR_CONDITION_NAME are used if the config file does not set them
+		InstallNamespace:      installNamespace,
+		OperatorConditionName: operatorConfig.Registration.OperatorConditionName,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DBaaSProvider")
 		os.Exit(1)
 	}
 
+	fixturesFile := operatorConfig.Provider.FixturesFile
+	if path, ok := os.LookupEnv(testutil.FixturesEnvVar); ok && len(fixturesFile) == 0 {
+		fixturesFile = path
+	}
+	if len(fixturesFile) != 0 {
+		if err := testutil.LoadFixtures(fixturesFile); err != nil {
+			setupLog.Error(err, "unable to load the fake provider fixtures")
+			os.Exit(1)
+		}
+	}
 	fakeService := &testutil.FakeProviderService{
-		Client: mgr.GetClient(),
+		Client:         mgr.GetClient(),
+		APIReader:      mgr.GetAPIReader(),
+		ServerURL:      providerURL,
+		RateLimiter:    providerAPILimiter,
+		FaultInjection: providerFaultInjection,
+	}
+
+	var providerService testutil.DBaaSProviderService = fakeService
+	if len(tracingOptions.Endpoint) != 0 {
+		providerService = testutil.NewTracedProviderService(fakeService)
+	}
+
+	providerHealth := &dbaascontrollers.ProviderHealth{
+		CloudService:       cloudService,
+		RESTMapper:         mgr.GetRESTMapper(),
+		UnreachableTimeout: providerUnreachableTimeout,
 	}
 
 	if err = (&dbaascontrollers.ProviderInventoryReconciler{
-		DBaaSProviderService: fakeService,
+		DBaaSProviderService: providerService,
 		Scheme:               mgr.GetScheme(),
+		Options:              inventoryOptions,
+		Health:               providerHealth,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ProviderInventory")
 		os.Exit(1)
 	}
 	if err = (&dbaascontrollers.ProviderConnectionReconciler{
-		DBaaSProviderService: fakeService,
+		DBaaSProviderService: providerService,
 		Scheme:               mgr.GetScheme(),
+		Options:              connectionOptions,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ProviderConnection")
 		os.Exit(1)
 	}
 	if err = (&dbaascontrollers.ProviderInstanceReconciler{
-		DBaaSProviderService: fakeService,
+		DBaaSProviderService: providerService,
 		Scheme:               mgr.GetScheme(),
+		Options:              instanceOptions,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ProviderInstance")
 		os.Exit(1)
 	}
+	if err = (&dbaascontrollers.ProviderBackupReconciler{
+		DBaaSProviderService: providerService,
+		Scheme:               mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ProviderBackup")
+		os.Exit(1)
+	}
+	if err = (&dbaascontrollers.ProviderRestoreReconciler{
+		DBaaSProviderService: providerService,
+		Scheme:               mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ProviderRestore")
+		os.Exit(1)
+	}
+	if err = (&dbaascontrollers.ProviderBackupScheduleReconciler{
+		DBaaSProviderService: providerService,
+		Scheme:               mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ProviderBackupSchedule")
+		os.Exit(1)
+	}
+	if err = (&dbaascontrollers.ProviderQuotaReconciler{
+		DBaaSProviderService: providerService,
+		Scheme:               mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ProviderQuota")
+		os.Exit(1)
+	}
+	// the webhooks need a serving certificate, see config/certmanager, or the one OLM mounts for the bundle
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		if err = (&dbaascontrollers.QuotaValidator{
+			Client: mgr.GetClient(),
+		}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ProviderQuota")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+	if err := mgr.AddReadyzCheck("registration", providerHealth.RegistrationCheck); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("provider-backend", providerHealth.BackendCheck); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	// the last sync of every inventory, served with the metrics
+	if err := mgr.AddMetricsExtraHandler("/debug/provider", providerHealth); err != nil {
+		setupLog.Error(err, "unable to set up the provider debug endpoint")
+		os.Exit(1)
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		setupLog.Error(err, "unable to export the last spans")
+	}
 }